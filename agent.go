@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// agentRecords are the names reported to the controller, the agent's
+// own 'records' are used if none are set
+func agentRecords() []string {
+	if len(config.Agent.Records) > 0 {
+		return config.Agent.Records
+	}
+	return configuredRecords()
+}
+
+// reportToController sends the detected addresses to a controller running
+// the dyndns2 server, which holds the only token and applies them with its
+// next run, through the same gates as its own changes
+func reportToController(ipv4, ipv6 string) error {
+	names := agentRecords()
+	if len(names) == 0 {
+		return fmt.Errorf("agent: no records to report")
+	}
+
+	query := url.Values{}
+	query.Set("hostname", strings.Join(names, ","))
+	// the controller keeps the records of a family that wasn't detected
+	query.Set("myip", strings.Trim(strings.ReplaceAll(ipv4+","+ipv6, keepAddress, ""), ","))
+	req, err := http.NewRequestWithContext(runContext, "GET", strings.TrimRight(config.Agent.Controller, "/")+"/nic/update?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(config.Agent.User, config.Agent.Password)
+	req.Header.Set("User-Agent", "hetzner-dns-update-agent/"+instanceName())
+
+	client := &http.Client{Transport: apiClient().Transport, Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return fmt.Errorf("agent: controller replied %s", resp.Status)
+	}
+
+	// one reply line per hostname, in the order sent
+	replies := strings.Split(strings.TrimSpace(string(body)), "\n")
+	var failed []string
+	for i, reply := range replies {
+		code := strings.Fields(reply + " ")[0]
+		name := "?"
+		if i < len(names) {
+			name = names[i]
+		}
+		switch code {
+		case "good":
+			log.Printf("agent: controller accepted %s for %s\n", strings.TrimPrefix(reply, "good "), name)
+		case "nochg":
+		default:
+			failed = append(failed, name+": "+reply)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("agent: controller refused %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Annotation is an operator's note on why a record is managed
+type Annotation struct {
+	Time time.Time `json:"time"`
+	Note string    `json:"note"`
+	By   string    `json:"by,omitempty"`
+}
+
+// annotations are kept under their own key like the disabled records, so
+// 'annotate' works while the daemon is running
+func annotationsKey() string {
+	return stateKey() + ":annotations"
+}
+
+func loadAnnotations() (map[string][]Annotation, error) {
+	annotations := make(map[string][]Annotation)
+	if state == nil {
+		return annotations, nil
+	}
+	data, err := state.load(annotationsKey())
+	if err != nil || data == nil {
+		return annotations, err
+	}
+	err = json.Unmarshal(data, &annotations)
+	return annotations, err
+}
+
+// recordNotes returns the annotations for the live state
+func recordNotes() map[string][]Annotation {
+	annotations, err := loadAnnotations()
+	if err != nil {
+		log.Println("error loading annotations:", err)
+	}
+	return annotations
+}
+
+// notesText lists the annotations of a record for a notification
+func notesText(notes []Annotation) string {
+	var b strings.Builder
+	for _, note := range notes {
+		fmt.Fprintf(&b, "note (%s", note.Time.Local().Format("2006-01-02"))
+		if note.By != "" {
+			fmt.Fprintf(&b, ", %s", note.By)
+		}
+		fmt.Fprintf(&b, "): %s\r\n", note.Note)
+	}
+	return b.String()
+}
+
+// runAnnotate handles 'annotate <record> <note>', without a note it lists
+// the notes of the record, without arguments those of all records
+func runAnnotate(args []string) error {
+	if state == nil {
+		return fmt.Errorf("no state store")
+	}
+	annotations, err := loadAnnotations()
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		var names []string
+		for name := range annotations {
+			if len(args) == 0 || name == args[0] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name)
+			fmt.Print(strings.ReplaceAll(notesText(annotations[name]), "\r\n", "\n"))
+		}
+		return nil
+	}
+
+	name, note := args[0], strings.TrimSpace(strings.Join(args[1:], " "))
+	if note == "" {
+		return fmt.Errorf("empty note for '%s'", name)
+	}
+	annotations[name] = append(annotations[name], Annotation{Time: clock.Now(), Note: note, By: os.Getenv("USER")})
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	if err := state.save(annotationsKey(), data); err != nil {
+		return err
+	}
+	log.Printf("record '%s' annotated: %s\n", name, note)
+	fmt.Println("annotated", name)
+	return nil
+}
@@ -0,0 +1,136 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	maxCandidateSize = 1 << 20
+	validateTimeout  = 10 * time.Second
+)
+
+func serveControlAPI(reconcile chan<- struct{}) {
+	if config.ControlAPI.Token == "" {
+		log.Println("control API disabled: 'token' is not set")
+		return
+	}
+	log.Println("control API listening on", config.ControlAPI.Listen)
+	err := http.ListenAndServe(config.ControlAPI.Listen, requireToken(controlAPIMux(reconcile)))
+	if err != nil {
+		log.Println("error running control API:", err)
+	}
+}
+
+func controlAPIMux(reconcile chan<- struct{}) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, live.status())
+	})
+	mux.HandleFunc("GET /records", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, live.recordList())
+	})
+	mux.HandleFunc("POST /reconcile", func(w http.ResponseWriter, r *http.Request) {
+		requestReconcile(reconcile)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "reconcile scheduled"})
+	})
+	mux.HandleFunc("POST /freeze", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Record   string `json:"record"`
+			Duration string `json:"duration"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Record == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected {\"record\": ..., \"duration\": ...}"})
+			return
+		}
+		// a zero time would unfreeze the record, that is POST /unfreeze
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid duration '%s', expected e.g. \"2h\"", req.Duration)})
+			return
+		}
+		until := clock.Now().Add(duration)
+		live.freeze(req.Record, until)
+		log.Printf("control API: record '%s' frozen until %s\n", req.Record, until.Format(time.RFC3339))
+		writeJSON(w, http.StatusOK, map[string]any{"record": req.Record, "frozen_until": until})
+	})
+	mux.HandleFunc("POST /unfreeze", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Record string `json:"record"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Record == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected {\"record\": ...}"})
+			return
+		}
+		live.freeze(req.Record, time.Time{})
+		log.Printf("control API: record '%s' unfrozen\n", req.Record)
+		writeJSON(w, http.StatusOK, map[string]any{"record": req.Record})
+	})
+	mux.HandleFunc("POST /validate", func(w http.ResponseWriter, r *http.Request) {
+		candidate, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCandidateSize))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		result, err := validateInProcess(r.Context(), candidate)
+		if err != nil {
+			log.Println("control API: error validating config:", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+	return mux
+}
+
+// validateInProcess checks a candidate config with 'validate' in a child
+// process, the config of the daemon is left alone
+func validateInProcess(ctx context.Context, candidate []byte) (ValidationResult, error) {
+	var result ValidationResult
+	exe, err := os.Executable()
+	if err != nil {
+		return result, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe, "validate", "-")
+	cmd.Stdin = bytes.NewReader(candidate)
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) {
+		return result, err
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return result, fmt.Errorf("unexpected output of validate: %.80s", out)
+	}
+	return result, nil
+}
+
+func requireToken(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + config.ControlAPI.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
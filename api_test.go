@@ -0,0 +1,43 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreezeNeedsDuration(t *testing.T) {
+	m := useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	t.Cleanup(func() { live.freeze("home.example.com", time.Time{}) })
+	mux := controlAPIMux(make(chan struct{}, 1))
+	post := func(path, body string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return w.Code
+	}
+
+	for _, body := range []string{`{"record": "home.example.com"}`, `{"record": "home.example.com", "duration": "0s"}`,
+		`{"record": "home.example.com", "duration": "-1h"}`} {
+		if code := post("/freeze", body); code != http.StatusBadRequest {
+			t.Errorf("%s answered %d, want 400", body, code)
+		}
+	}
+	if live.isFrozen("home.example.com") {
+		t.Fatal("record frozen without a duration")
+	}
+
+	if code := post("/freeze", `{"record": "home.example.com", "duration": "2h"}`); code != http.StatusOK {
+		t.Fatalf("freeze answered %d", code)
+	}
+	m.Advance(time.Hour)
+	if !live.isFrozen("home.example.com") {
+		t.Error("record not frozen for the duration")
+	}
+	if code := post("/unfreeze", `{"record": "home.example.com"}`); code != http.StatusOK || live.isFrozen("home.example.com") {
+		t.Errorf("unfreeze answered %d, record frozen: %v", code, live.isFrozen("home.example.com"))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// cachedResponse is a Hetzner API GET response with its validators
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// apiCache keeps the zone and record lists between the runs of the
+// daemon, they are revalidated with If-None-Match or If-Modified-Since
+var apiCache struct {
+	mu        sync.Mutex
+	enabled   bool
+	responses map[string]cachedResponse
+	hits      int
+}
+
+func enableAPICache() {
+	apiCache.mu.Lock()
+	defer apiCache.mu.Unlock()
+	apiCache.enabled = true
+	apiCache.responses = make(map[string]cachedResponse)
+}
+
+func apiCacheEnabled() bool {
+	apiCache.mu.Lock()
+	defer apiCache.mu.Unlock()
+	return apiCache.enabled
+}
+
+// apiCacheHits returns and resets the number of responses answered
+// from the cache since the last call
+func apiCacheHits() int {
+	apiCache.mu.Lock()
+	defer apiCache.mu.Unlock()
+	hits := apiCache.hits
+	apiCache.hits = 0
+	return hits
+}
+
+// cacheTransport sends conditional GET requests to the Hetzner API and
+// answers a 304 with the cached body, responses without ETag or
+// Last-Modified are not cached
+type cacheTransport struct {
+	next http.RoundTripper
+}
+
+func (t cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	api, _ := url.Parse(hetznerAPI)
+	if req.Method != "GET" || req.URL.Host != api.Host {
+		return t.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	apiCache.mu.Lock()
+	cached, ok := apiCache.responses[key]
+	apiCache.mu.Unlock()
+	if ok {
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if ok && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		apiCache.mu.Lock()
+		apiCache.hits++
+		apiCache.mu.Unlock()
+		return cachedHTTPResponse(req, cached), nil
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		apiCache.mu.Lock()
+		delete(apiCache.responses, key)
+		apiCache.mu.Unlock()
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	cached = cachedResponse{etag: etag, lastModified: lastModified, header: resp.Header.Clone(), body: body}
+	apiCache.mu.Lock()
+	apiCache.responses[key] = cached
+	apiCache.mu.Unlock()
+	return cachedHTTPResponse(req, cached), nil
+}
+
+func cachedHTTPResponse(req *http.Request, cached cachedResponse) *http.Response {
+	header := cached.header.Clone()
+	header.Set("Content-Length", strconv.Itoa(len(cached.body)))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.body)),
+		ContentLength: int64(len(cached.body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// approved plans are kept under their own key, like the disabled records,
+// so the daemon never overwrites an approval given meanwhile
+func approvedKey() string {
+	return stateKey() + ":approved"
+}
+
+func needsApproval(change Change) bool {
+	if (change.Action == "delete" || change.Action == "dedupe") && config.Approval.Deletes {
+		return true
+	}
+	return matchesAny(change.FullDomain, config.Approval.Records)
+}
+
+// planID identifies a set of changes, it stays the same as long as the
+// planned changes don't change
+func planID(changes []Change) string {
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.diff().String())
+	}
+	slices.Sort(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// approvalSignature signs a plan ID for the approval link of the web UI
+func approvalSignature(id string) string {
+	mac := hmac.New(sha256.New, []byte(config.Approval.Secret))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func loadApproved() (map[string]time.Time, error) {
+	approved := make(map[string]time.Time)
+	if state == nil {
+		return approved, nil
+	}
+	data, err := state.load(approvedKey())
+	if err != nil || data == nil {
+		return approved, err
+	}
+	err = json.Unmarshal(data, &approved)
+	return approved, err
+}
+
+func approvePlan(id string) error {
+	if state == nil {
+		return fmt.Errorf("no state store")
+	}
+	approved, err := loadApproved()
+	if err != nil {
+		return err
+	}
+	approved[id] = clock.Now()
+	// approvals of plans that were never applied don't pile up
+	for other, since := range approved {
+		if elapsed(since) > 7*24*time.Hour {
+			delete(approved, other)
+		}
+	}
+	log.Printf("plan %s approved\n", id)
+	return saveApproved(approved)
+}
+
+func saveApproved(approved map[string]time.Time) error {
+	data, err := json.Marshal(approved)
+	if err != nil {
+		return err
+	}
+	return state.save(approvedKey(), data)
+}
+
+// holdForApproval returns the changes that may be applied, the changes
+// requiring approval are held back until their plan ID is approved via
+// 'approve <plan-id>' or the signed link; the ID of an approved plan is
+// returned for useApproval
+func holdForApproval(changes []Change) ([]Change, string) {
+	var free, held []Change
+	for _, change := range changes {
+		if needsApproval(change) {
+			held = append(held, change)
+		} else {
+			free = append(free, change)
+		}
+	}
+	if len(held) == 0 {
+		live.setPendingApproval("")
+		return free, ""
+	}
+
+	id := planID(held)
+	approved, err := loadApproved()
+	if err != nil {
+		logAndMail("error loading approvals: " + err.Error())
+	}
+	if _, ok := approved[id]; ok {
+		log.Printf("plan %s is approved, applying %d held changes\n", id, len(held))
+		live.setPendingApproval("")
+		return append(free, held...), id
+	}
+
+	if live.setPendingApproval(id) {
+		lines := make([]string, len(held))
+		for i, change := range held {
+			lines[i] = "- " + change.diff().String()
+		}
+		message := fmt.Sprintf("%d changes require approval of plan %s:\n%s\n\nrun 'hetzner-dns-update approve %s'",
+			len(held), id, strings.Join(lines, "\n"), id)
+		if config.Approval.URL != "" && config.Approval.Secret != "" {
+			message += fmt.Sprintf(" or open %s/approve?plan=%s&sig=%s",
+				strings.TrimSuffix(config.Approval.URL, "/"), id, url.QueryEscape(approvalSignature(id)))
+		}
+		log.Println(message)
+		sendNotification("DNS Update: approval required for plan "+id, message)
+	}
+	return free, ""
+}
+
+// useApproval removes the approval of a plan once its changes were
+// applied, the same changes later need a new one; a plan held back by
+// another gate or failed keeps its approval
+func useApproval(id string) {
+	approved, err := loadApproved()
+	if err != nil {
+		logAndMail("error loading approvals: " + err.Error())
+		return
+	}
+	delete(approved, id)
+	if err := saveApproved(approved); err != nil {
+		logAndMail("error saving approvals: " + err.Error())
+	}
+}
+
+// runApprove handles 'approve <plan-id>'
+func runApprove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: approve <plan-id>")
+	}
+	if err := approvePlan(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("plan %s approved, it is applied with the next run\n", args[0])
+	return nil
+}
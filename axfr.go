@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// tsigKey signs zone transfers and dynamic updates
+type tsigKey struct {
+	name, secret, algorithm string
+}
+
+// sign adds a TSIG record to msg and returns the secret for the client,
+// nil if no key is set
+func (k tsigKey) sign(msg *dns.Msg) map[string]string {
+	if k.name == "" {
+		return nil
+	}
+	algorithm := k.algorithm
+	if algorithm == "" {
+		algorithm = "hmac-sha256"
+	}
+	name := dns.Fqdn(k.name)
+	msg.SetTsig(name, dns.Fqdn(algorithm), 300, 0)
+	return map[string]string{name: k.secret}
+}
+
+// serverAddress resolves a nameserver given as host or host:port
+func serverAddress(server string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "53"
+	}
+	addrs, err := resolveHost(host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(addrs[0], port), nil
+}
+
+// transferZone pulls a zone via AXFR
+func transferZone(server string, key tsigKey, zone string) ([]dns.RR, error) {
+	address, err := serverAddress(server)
+	if err != nil {
+		return nil, err
+	}
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone))
+	transfer := &dns.Transfer{TsigSecret: key.sign(msg)}
+	envelopes, err := transfer.In(msg, address)
+	if err != nil {
+		return nil, err
+	}
+	var records []dns.RR
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, envelope.Error
+		}
+		records = append(records, envelope.RR...)
+	}
+	return records, nil
+}
+
+// axfrRecords pulls a zone for the audit, signed with TSIG if a key is set
+func axfrRecords(zone string) ([]dns.RR, error) {
+	key := tsigKey{config.AXFR.TSIGName, config.AXFR.TSIGSecret, config.AXFR.TSIGAlgorithm}
+	return transferZone(config.AXFR.Server, key, zone)
+}
+
+// apiRecords returns the records of a zone as seen by the API, parsed like
+// a zone file so relative names compare equal to the AXFR view
+func apiRecords(zone string) ([]dns.RR, error) {
+	zoneID, err := findZoneID(zone)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	err = eachRecord(zoneID, func(rec Record) {
+		lines = append(lines, fmt.Sprintf("%s %d IN %s %s", rec.Name, rec.TTL, rec.Type, rec.Value))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dns.RR
+	parser := dns.NewZoneParser(strings.NewReader(strings.Join(lines, "\n")), dns.Fqdn(zone), "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		records = append(records, rr)
+	}
+	return records, parser.Err()
+}
+
+// axfrKeys normalizes records for the comparison, the SOA differs in the
+// serial while changes propagate and TTLs may be defaulted differently
+func axfrKeys(records []dns.RR) map[string]bool {
+	keys := make(map[string]bool)
+	for _, rr := range records {
+		if rr.Header().Rrtype == dns.TypeSOA {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		rr.Header().Name = strings.ToLower(rr.Header().Name)
+		keys[strings.Replace(rr.String(), "\t0\t", "\t", 1)] = true
+	}
+	return keys
+}
+
+// auditAXFR compares the API view of every configured zone with a zone
+// transfer and returns the number of inconsistencies
+func auditAXFR() int {
+	if config.AXFR.Server == "" {
+		return 0
+	}
+	inconsistent := 0
+	for _, zone := range configuredZones() {
+		transferred, err := axfrRecords(zone)
+		if err != nil {
+			fmt.Printf("error transferring zone '%s' from %s: %s\n", zone, config.AXFR.Server, err)
+			inconsistent++
+			continue
+		}
+		api, err := apiRecords(zone)
+		if err != nil {
+			fmt.Printf("error reading zone '%s' via API: %s\n", zone, err)
+			inconsistent++
+			continue
+		}
+
+		api_keys, axfr_keys := axfrKeys(api), axfrKeys(transferred)
+		var diff []string
+		for key := range api_keys {
+			if !axfr_keys[key] {
+				diff = append(diff, "- only in API:  "+key)
+			}
+		}
+		for key := range axfr_keys {
+			if !api_keys[key] {
+				diff = append(diff, "+ only in AXFR: "+key)
+			}
+		}
+		if len(diff) == 0 {
+			fmt.Printf("zone '%s': API and AXFR agree (%d records)\n", zone, len(api_keys))
+			continue
+		}
+		sort.Strings(diff)
+		fmt.Printf("zone '%s': %d differences between API and AXFR\n", zone, len(diff))
+		for _, line := range diff {
+			fmt.Println(line)
+		}
+		inconsistent += len(diff)
+	}
+	return inconsistent
+}
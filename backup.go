@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// zone backups are kept this many per zone by default
+const defaultBackupKeep = 10
+
+// backupZones exports the zones touched by the changes before a run with
+// deletes or more than 'backup.updates' updates is applied
+func backupZones(changes []Change) error {
+	if config.Backup.Dir == "" || !needsBackup(changes) {
+		return nil
+	}
+	if err := os.MkdirAll(config.Backup.Dir, 0700); err != nil {
+		return err
+	}
+	stamp := clock.Now().Format("20060102-150405")
+	for _, zone_changes := range groupByZone(changes) {
+		zone := zone_changes[0].Zone
+		zone_file, err := exportZone(zone_changes[0].ZoneID)
+		if err != nil {
+			return fmt.Errorf("export of '%s': %w", zone, err)
+		}
+		name := filepath.Join(config.Backup.Dir, fmt.Sprintf("%s-%s.zone", zone, stamp))
+		if err := os.WriteFile(name, []byte(zone_file), 0600); err != nil {
+			return err
+		}
+		log.Printf("backup: zone '%s' saved to %s\n", zone, name)
+		pruneBackups(zone)
+	}
+	return nil
+}
+
+func needsBackup(changes []Change) bool {
+	updates := 0
+	for _, change := range changes {
+		switch change.Action {
+		case "delete", "dedupe":
+			return true
+		case "update":
+			updates++
+		}
+	}
+	return config.Backup.Updates > 0 && updates > config.Backup.Updates
+}
+
+// pruneBackups keeps the newest 'backup.keep' files of a zone
+func pruneBackups(zone string) {
+	keep := config.Backup.Keep
+	if keep <= 0 {
+		keep = defaultBackupKeep
+	}
+	files, err := filepath.Glob(filepath.Join(config.Backup.Dir, zone+"-*.zone"))
+	if err != nil || len(files) <= keep {
+		return
+	}
+	// the timestamps sort like the file names
+	slices.Sort(files)
+	for _, file := range files[:len(files)-keep] {
+		if err := os.Remove(file); err != nil {
+			log.Println("error removing old backup:", err)
+		}
+	}
+}
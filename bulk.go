@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+)
+
+// bulkProvider creates or updates many records in one request, like the
+// bulk endpoints of the Hetzner API
+type bulkProvider interface {
+	CreateRecords(records []Record) ([]Record, error)
+	UpdateRecords(records []Record) ([]Record, error)
+}
+
+// applyBulk creates and updates the records of providers with bulk
+// endpoints in one request per action; it returns the changes left to be
+// applied one by one: deletes, protected records, single changes and the
+// ones the bulk request rejected. Nothing is bulked if 'batch' spreads
+// the changes out
+func applyBulk(changes []Change) []Change {
+	if config.Batch.Size > 0 {
+		return changes
+	}
+	var rest []Change
+	bulk := make(map[string][]Change)
+	for _, change := range changes {
+		_, ok := providerFor(change.ZoneID).(bulkProvider)
+		if !ok || (change.Action != "create" && change.Action != "update") || live.isProtected(change.FullDomain+"/"+change.Type) {
+			rest = append(rest, change)
+			continue
+		}
+		bulk[change.Action] = append(bulk[change.Action], change)
+	}
+
+	for _, action := range []string{"update", "create"} {
+		batch := bulk[action]
+		if len(batch) < 2 {
+			rest = append(rest, batch...)
+			continue
+		}
+		failed, err := bulkApply(action, batch)
+		if err != nil {
+			log.Printf("bulk %s of %d records failed, applying them one by one: %s\n", action, len(batch), err)
+			rest = append(rest, batch...)
+			continue
+		}
+		for _, change := range batch {
+			if slices.ContainsFunc(failed, func(rec Record) bool { return bulkMatches(rec, change) }) {
+				rest = append(rest, change)
+				continue
+			}
+			changeApplied(change)
+		}
+		if len(failed) > 0 {
+			log.Printf("bulk %s: %d of %d records rejected, applying them one by one\n", action, len(failed), len(batch))
+		}
+	}
+	return rest
+}
+
+// bulkApply sends the changes of one action to the provider of their zone
+// in one request, all of them are for the same provider
+func bulkApply(action string, changes []Change) ([]Record, error) {
+	if config.ReadOnly {
+		return nil, errReadOnly
+	}
+	var records []Record
+	for _, change := range changes {
+		records = append(records, Record{
+			ID:     change.RecordID,
+			ZoneID: change.ZoneID,
+			Type:   change.Type,
+			Name:   change.Name,
+			Value:  change.NewValue,
+			TTL:    int(change.TTL),
+		})
+	}
+	provider := providerFor(changes[0].ZoneID).(bulkProvider)
+	var failed []Record
+	retried := false
+	err := withRetry(fmt.Sprintf("bulk %s of %d records", action, len(records)), func() error {
+		var err error
+		if action == "create" {
+			if retried {
+				// the failed request may have created some of the records
+				records, err = missingRecords(records)
+				if err != nil || len(records) == 0 {
+					return err
+				}
+			}
+			retried = true
+			failed, err = provider.CreateRecords(records)
+		} else {
+			failed, err = provider.UpdateRecords(records)
+		}
+		return err
+	})
+	return failed, err
+}
+
+// bulkMatches reports whether a rejected record of a bulk request is the
+// one of the change, created records have no ID yet
+func bulkMatches(rec Record, change Change) bool {
+	if rec.ID != "" && change.RecordID != "" {
+		return rec.ID == change.RecordID
+	}
+	return rec.Name == change.Name && rec.Type == change.Type && rec.Value == change.NewValue
+}
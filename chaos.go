@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// chaos injects faults into Hetzner API calls to try retries, backoff and
+// alerting before trusting them in production, the flags are not listed
+// in -help
+var chaos struct {
+	latency       time.Duration
+	errorRate     float64
+	malformedRate float64
+}
+
+func registerChaosFlags() {
+	flag.DurationVar(&chaos.latency, "chaos-latency", 0, "delay every API call by up to this duration")
+	flag.Float64Var(&chaos.errorRate, "chaos-error-rate", 0, "answer this fraction of API calls with a 5xx status")
+	flag.Float64Var(&chaos.malformedRate, "chaos-malformed-rate", 0, "truncate the body of this fraction of API responses")
+
+}
+
+// hiddenFlag keeps the chaos flags out of -help
+func hiddenFlag(name string) bool {
+	return strings.HasPrefix(name, "chaos-")
+}
+
+func chaosEnabled() bool {
+	return chaos.latency > 0 || chaos.errorRate > 0 || chaos.malformedRate > 0
+}
+
+func validateChaos() error {
+	for name, rate := range map[string]float64{"chaos-error-rate": chaos.errorRate, "chaos-malformed-rate": chaos.malformedRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("-%s must be between 0 and 1, got %g", name, rate)
+		}
+	}
+	return nil
+}
+
+type chaosTransport struct {
+	next http.RoundTripper
+}
+
+func (t chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	api, _ := url.Parse(hetznerAPI)
+	if req.URL.Host != api.Host {
+		return t.next.RoundTrip(req)
+	}
+
+	if chaos.latency > 0 {
+		timer := clock.NewTimer(random.Duration(chaos.latency))
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if random.Float64() < chaos.errorRate {
+		log.Printf("chaos: failing %s %s\n", req.Method, req.URL.Path)
+		return &http.Response{
+			Status:     "503 Service Unavailable",
+			StatusCode: http.StatusServiceUnavailable,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"injected by -chaos-error-rate","code":503}}`)),
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || random.Float64() >= chaos.malformedRate {
+		return resp, err
+	}
+	log.Printf("chaos: truncating response of %s %s\n", req.Method, req.URL.Path)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body = body[:len(body)/2]
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
@@ -0,0 +1,231 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const telegramAPI = "https://api.telegram.org/bot"
+
+// chatCommand runs a command received via Telegram or Slack and returns the reply
+func chatCommand(text string, reconcile chan<- struct{}) string {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(text), "/"))
+	if len(fields) == 0 {
+		return chatHelp()
+	}
+	// Telegram adds the bot name in groups: /status@my_bot
+	command, _, _ := strings.Cut(strings.ToLower(fields[0]), "@")
+
+	switch command {
+	case "status":
+		status := live.status()
+		var b strings.Builder
+		fmt.Fprintf(&b, "IPv4: %s\nIPv6: %s\n", status.IPv4, status.IPv6)
+		fmt.Fprintf(&b, "last run: %s, %d errors, %d changes\n", status.LastRun.Format("2006-01-02 15:04:05"), status.Errors, status.Changes)
+		fmt.Fprintf(&b, "next run: %s", status.NextRun.Format("2006-01-02 15:04:05"))
+		if status.LastError != "" {
+			fmt.Fprintf(&b, "\nlast error: %s", status.LastError)
+		}
+		for name, until := range status.FrozenUntil {
+			fmt.Fprintf(&b, "\n%s frozen until %s", name, until.Format("2006-01-02 15:04"))
+		}
+		return b.String()
+	case "update":
+		requestReconcile(reconcile)
+		return "reconcile scheduled"
+	case "freeze":
+		if len(fields) != 3 {
+			return "usage: freeze <record> <duration>"
+		}
+		duration, err := parseSeconds(fields[2])
+		if err != nil || duration <= 0 {
+			return fmt.Sprintf("invalid duration '%s'", fields[2])
+		}
+		until := clock.Now().Add(time.Duration(duration) * time.Second)
+		live.freeze(fields[1], until)
+		log.Printf("chat: record '%s' frozen until %s\n", fields[1], until.Format(time.RFC3339))
+		return fmt.Sprintf("%s frozen until %s", fields[1], until.Format("2006-01-02 15:04"))
+	case "unfreeze":
+		if len(fields) != 2 {
+			return "usage: unfreeze <record>"
+		}
+		live.freeze(fields[1], time.Time{})
+		log.Printf("chat: record '%s' unfrozen\n", fields[1])
+		return fields[1] + " unfrozen"
+	}
+	return chatHelp()
+}
+
+func chatHelp() string {
+	return "commands: status, update, freeze <record> <duration>, unfreeze <record>"
+}
+
+// serveTelegram long-polls the bot API and answers messages of allowed users
+func serveTelegram(reconcile chan<- struct{}) {
+	if len(config.Telegram.AllowedUsers) == 0 {
+		log.Println("telegram bot disabled: 'allowed_users' is empty")
+		return
+	}
+	log.Println("telegram bot started")
+
+	client := &http.Client{Transport: apiClient().Transport, Timeout: 70 * time.Second}
+	offset := loadTelegramOffset()
+	for {
+		var updates struct {
+			OK     bool `json:"ok"`
+			Result []struct {
+				UpdateID int `json:"update_id"`
+				Message  *struct {
+					Text string `json:"text"`
+					From struct {
+						ID int64 `json:"id"`
+					} `json:"from"`
+					Chat struct {
+						ID int64 `json:"id"`
+					} `json:"chat"`
+				} `json:"message"`
+			} `json:"result"`
+		}
+		err := telegramCall(client, "getUpdates", map[string]any{"offset": offset, "timeout": 60}, &updates)
+		if err != nil {
+			log.Println("telegram: error fetching updates:", err)
+			clock.Sleep(30 * time.Second)
+			continue
+		}
+
+		// confirmed before running the commands, a restart doesn't run
+		// them twice
+		if n := len(updates.Result); n > 0 {
+			offset = updates.Result[n-1].UpdateID + 1
+			saveTelegramOffset(offset)
+		}
+		for _, update := range updates.Result {
+			message := update.Message
+			if message == nil || message.Text == "" {
+				continue
+			}
+			if !slices.Contains(config.Telegram.AllowedUsers, message.From.ID) {
+				log.Printf("telegram: ignoring command from user %d\n", message.From.ID)
+				continue
+			}
+			reply := chatCommand(message.Text, reconcile)
+			err := telegramCall(client, "sendMessage", map[string]any{"chat_id": message.Chat.ID, "text": reply}, nil)
+			if err != nil {
+				log.Println("telegram: error sending reply:", err)
+			}
+		}
+	}
+}
+
+// the update offset is kept under its own key like the disabled records,
+// Telegram would deliver the updates of the last 24 hours again otherwise
+func telegramOffsetKey() string {
+	return stateKey() + ":telegram_offset"
+}
+
+func loadTelegramOffset() int {
+	if state == nil {
+		return 0
+	}
+	data, err := state.load(telegramOffsetKey())
+	if err != nil {
+		log.Println("telegram: error loading update offset:", err)
+		return 0
+	}
+	offset, _ := strconv.Atoi(string(data))
+	return offset
+}
+
+func saveTelegramOffset(offset int) {
+	if state == nil {
+		return
+	}
+	if err := state.save(telegramOffsetKey(), []byte(strconv.Itoa(offset))); err != nil {
+		log.Println("telegram: error saving update offset:", err)
+	}
+}
+
+func telegramCall(client *http.Client, method string, payload map[string]any, result any) error {
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequestWithContext(runContext, "POST", telegramAPI+config.Telegram.Token+"/"+method, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// the URL contains the token
+		return fmt.Errorf("%s failed", method)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return &StatusError{Op: method, Code: resp.StatusCode, Status: resp.Status}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result)
+}
+
+// serveSlack answers slash commands, e.g. '/dns freeze vpn.example.com 2h'
+func serveSlack(reconcile chan<- struct{}) {
+	if config.Slack.SigningSecret == "" || len(config.Slack.AllowedUsers) == 0 {
+		log.Println("slack command disabled: 'signing_secret' and 'allowed_users' must be set")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /slack/command", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil || !slackSignatureValid(r.Header, body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		reply := "you are not allowed to use this command"
+		if slices.Contains(config.Slack.AllowedUsers, form.Get("user_id")) {
+			reply = chatCommand(form.Get("text"), reconcile)
+		} else {
+			log.Printf("slack: ignoring command from user %s\n", form.Get("user_id"))
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"response_type": "ephemeral", "text": reply})
+	})
+
+	log.Println("slack command listening on", config.Slack.Listen)
+	err := http.ListenAndServe(config.Slack.Listen, mux)
+	if err != nil {
+		log.Println("error running slack command endpoint:", err)
+	}
+}
+
+// slackSignatureValid checks the request signature and rejects requests
+// older than five minutes against replays
+func slackSignatureValid(header http.Header, body []byte) bool {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || elapsed(time.Unix(unix, 0)).Abs() > 5*time.Minute {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(config.Slack.SigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(header.Get("X-Slack-Signature")), []byte(expected)) == 1
+}
@@ -0,0 +1,52 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const checkMKService = "Hetzner_DNS_Update"
+
+func checkMKLine(start time.Time, records int) string {
+	status, summary := 0, fmt.Sprintf("%d records checked, %d changes", records, runChanges)
+	if runErrors > 0 {
+		status, summary = 2, fmt.Sprintf("%d errors, see log file", runErrors)
+	}
+	if id := live.runID(); id != "" {
+		summary += " (run " + id + ")"
+	}
+
+	var metrics []string
+	for _, m := range collectMetrics(start, records) {
+		if strings.HasSuffix(m.name, "_timestamp_seconds") {
+			continue
+		}
+		metrics = append(metrics, fmt.Sprintf("%s=%g", strings.TrimPrefix(m.name, "last_run_"), m.value))
+	}
+	return fmt.Sprintf("%d %s %s %s\n", status, checkMKService, strings.Join(metrics, "|"), summary)
+}
+
+// writeCheckMK spools the local check line, the file name prefix tells the
+// agent to discard the result once it is older than max_age seconds
+func writeCheckMK(start time.Time, records int) error {
+	if config.CheckMK.Spool == "" {
+		return nil
+	}
+	max_age := config.CheckMK.MaxAge
+	if max_age <= 0 {
+		max_age = 600
+	}
+	spool_file := filepath.Join(config.CheckMK.Spool, fmt.Sprintf("%d_hetzner_dns_update", max_age))
+	content := "<<<local>>>\n" + checkMKLine(start, records)
+
+	tmp_file := spool_file + ".tmp"
+	if err := os.WriteFile(tmp_file, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp_file, spool_file)
+}
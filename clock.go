@@ -0,0 +1,155 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for scheduling, cooldowns and grace
+// periods, so they can be driven by a manual clock instead of the wall
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Random is the source of jitter for the splay and injected faults
+type Random interface {
+	Duration(max time.Duration) time.Duration
+	Float64() float64
+}
+
+var (
+	clock  Clock  = systemClock{}
+	random Random = systemRandom{}
+)
+
+// elapsed is time.Since on the configured clock
+func elapsed(t time.Time) time.Duration {
+	return clock.Now().Sub(t)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                   { return time.Now() }
+func (systemClock) Sleep(d time.Duration)            { time.Sleep(d) }
+func (systemClock) NewTimer(d time.Duration) Timer   { return systemTimer{time.NewTimer(d)} }
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct{ t *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+func (t systemTimer) Stop() bool          { return t.t.Stop() }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.t.C }
+func (t systemTicker) Stop()               { t.t.Stop() }
+
+type systemRandom struct{}
+
+func (systemRandom) Duration(max time.Duration) time.Duration { return rand.N(max) }
+func (systemRandom) Float64() float64                         { return rand.Float64() }
+
+// manualClock only moves with Advance or Sleep, timers and tickers fire
+// when their deadline is passed
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*manualWaiter
+}
+
+type manualWaiter struct {
+	clock    *manualClock
+	c        chan time.Time
+	deadline time.Time
+	period   time.Duration
+}
+
+func newManualClock(now time.Time) *manualClock {
+	return &manualClock{now: now}
+}
+
+func (m *manualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *manualClock) Sleep(d time.Duration) {
+	m.Advance(d)
+}
+
+func (m *manualClock) NewTimer(d time.Duration) Timer {
+	return m.add(d, 0)
+}
+
+func (m *manualClock) NewTicker(d time.Duration) Ticker {
+	return manualTicker{m.add(d, d)}
+}
+
+func (m *manualClock) add(d, period time.Duration) *manualWaiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := &manualWaiter{clock: m, c: make(chan time.Time, 1), deadline: m.now.Add(d), period: period}
+	m.waiters = append(m.waiters, w)
+	return w
+}
+
+// Advance moves the clock forward and fires the timers and tickers that
+// are due, a ticker drops ticks like time.Ticker does
+func (m *manualClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+	m.waiters = slices.DeleteFunc(m.waiters, func(w *manualWaiter) bool {
+		if w.deadline.After(m.now) {
+			return false
+		}
+		select {
+		case w.c <- w.deadline:
+		default:
+		}
+		if w.period <= 0 {
+			return true
+		}
+		for !w.deadline.After(m.now) {
+			w.deadline = w.deadline.Add(w.period)
+		}
+		return false
+	})
+}
+
+func (w *manualWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *manualWaiter) Stop() bool {
+	m := w.clock
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.Index(m.waiters, w)
+	if i < 0 {
+		return false
+	}
+	m.waiters = slices.Delete(m.waiters, i, i+1)
+	return true
+}
+
+type manualTicker struct{ w *manualWaiter }
+
+func (t manualTicker) C() <-chan time.Time { return t.w.C() }
+func (t manualTicker) Stop()               { t.w.Stop() }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// noJitter makes the random delays of the tests predictable
+type noJitter struct{}
+
+func (noJitter) Duration(max time.Duration) time.Duration { return 0 }
+func (noJitter) Float64() float64                         { return 0 }
+
+// useManualClock replaces the clock and the jitter for one test
+func useManualClock(t *testing.T, now time.Time) *manualClock {
+	t.Helper()
+	saved_clock, saved_random := clock, random
+	m := newManualClock(now)
+	clock, random = m, noJitter{}
+	t.Cleanup(func() { clock, random = saved_clock, saved_random })
+	return m
+}
+
+// useConfig replaces the config for one test
+func useConfig(t *testing.T, c Config) {
+	t.Helper()
+	saved := config
+	config = c
+	t.Cleanup(func() { config = saved })
+}
+
+func TestManualClockTimers(t *testing.T) {
+	m := useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	timer := clock.NewTimer(time.Minute)
+	ticker := clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	m.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+	<-ticker.C()
+	m.Advance(time.Second)
+	if fired := <-timer.C(); !fired.Equal(m.Now()) {
+		t.Errorf("timer fired at %s, want %s", fired, m.Now())
+	}
+	if timer.Stop() {
+		t.Error("Stop of a fired timer reported true")
+	}
+}
@@ -0,0 +1,90 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+const cloudEventTypePrefix = "com.github.railduino.hetzner-dns-update.record."
+
+type cloudEvent struct {
+	SpecVersion     string     `json:"specversion"`
+	Type            string     `json:"type"`
+	Source          string     `json:"source"`
+	ID              string     `json:"id"`
+	Time            string     `json:"time"`
+	Subject         string     `json:"subject"`
+	DataContentType string     `json:"datacontenttype"`
+	RunID           string     `json:"runid,omitempty"`
+	Data            RecordDiff `json:"data"`
+}
+
+// sendCloudEvent posts a record change as CloudEvent (v1.0) in structured
+// or binary content mode, e.g. to a Knative broker or Argo Events webhook
+func sendCloudEvent(diff RecordDiff) {
+	if config.CloudEvents.URL == "" {
+		return
+	}
+	source := config.CloudEvents.Source
+	if source == "" {
+		source = "hetzner-dns-update/" + instanceName()
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	event := cloudEvent{
+		SpecVersion:     "1.0",
+		Type:            cloudEventTypePrefix + diff.Action + "d",
+		Source:          source,
+		ID:              hex.EncodeToString(id),
+		Time:            diff.Time.UTC().Format(time.RFC3339),
+		Subject:         diff.Record,
+		DataContentType: "application/json",
+		RunID:           diff.RunID,
+		Data:            diff,
+	}
+
+	var body []byte
+	var err error
+	if config.CloudEvents.Mode == "binary" {
+		body, err = json.Marshal(event.Data)
+	} else {
+		body, err = json.Marshal(event)
+	}
+	if err != nil {
+		log.Println("error encoding CloudEvent:", err)
+		return
+	}
+	req, _ := http.NewRequestWithContext(runContext, "POST", config.CloudEvents.URL, bytes.NewReader(body))
+	if config.CloudEvents.Mode == "binary" {
+		req.Header.Set("Content-Type", event.DataContentType)
+		req.Header.Set("ce-specversion", event.SpecVersion)
+		req.Header.Set("ce-type", event.Type)
+		req.Header.Set("ce-source", event.Source)
+		req.Header.Set("ce-id", event.ID)
+		req.Header.Set("ce-time", event.Time)
+		req.Header.Set("ce-subject", event.Subject)
+		if event.RunID != "" {
+			req.Header.Set("ce-runid", event.RunID)
+		}
+	} else {
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+	}
+
+	client := &http.Client{Transport: apiClient().Transport, Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Println("error sending CloudEvent:", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Println("error sending CloudEvent, status:", resp.Status)
+	}
+}
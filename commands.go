@@ -0,0 +1,255 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is a subcommand run instead of a reconcile, 'early' ones don't
+// need the config, the log or the state; run returns the exit code
+type command struct {
+	args  string
+	usage string
+	early bool
+	run   func(args []string, opts runOptions) int
+}
+
+// runCommands reconcile like the plain flags: 'update' is '-update',
+// 'check' is '-verbose' without changes; their flags may follow the name
+var runCommands = map[string]string{
+	"update": "apply the changes, same as -update",
+	"check":  "show the state of all records without changing them, same as -verbose",
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"version": {"", "print the version", true, runVersion},
+		"validate": {"<file|->", "check a config file and print the result as JSON", true, func(args []string, opts runOptions) int {
+			return exitCode(runValidate(args))
+		}},
+		"lint": {"", "warn about risky settings", false, func(args []string, opts runOptions) int {
+			return exitCode(runLint())
+		}},
+		"zones":   {"", "list the zones of the account", false, runZones},
+		"records": {"[zone...]", "list the records of the zones with managed records, or of the given ones", false, runRecords},
+		"plan": {"[-snapshot file]", "show all pending changes, exit code 2 if there are any", false, func(args []string, opts runOptions) int {
+			pending, err := runPlan(args, opts)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			if pending {
+				return 2
+			}
+			return 0
+		}},
+		"schedule": {"show [-n count]", "print the change windows, quiet hours and daemon runs with their next times", false, func(args []string, opts runOptions) int {
+			if err := runSchedule(args); err != nil {
+				fmt.Println("error:", err)
+				return 1
+			}
+			return 0
+		}},
+		"audit": {"", "check the records, delegation and secondaries without changing them", false, runAudit},
+		"discover": {"", "list the A/AAAA records of the zones that point to this host", false, func(args []string, opts runOptions) int {
+			ipv4, ipv6, err := getPublicIPs()
+			if err != nil {
+				fmt.Println("error getting current public IP:", err)
+				return 1
+			}
+			runDiscover(ipv4, ipv6)
+			return 0
+		}},
+		"import-records": {"-file <csv|json> [-dry-run]", "add the records of a file to the config", false, func(args []string, opts runOptions) int {
+			if err := runImportRecords(args); err != nil {
+				fmt.Println("error importing records:", err)
+				return 1
+			}
+			return 0
+		}},
+		"disable": {"<record...>", "stop managing the records until enabled again", false, func(args []string, opts runOptions) int {
+			return runToggle("disable", args)
+		}},
+		"enable": {"<record...>", "manage disabled records again", false, func(args []string, opts runOptions) int {
+			return runToggle("enable", args)
+		}},
+		"annotate": {"[record [note]]", "add a note on why a record is managed, or list the notes", false, func(args []string, opts runOptions) int {
+			if err := runAnnotate(args); err != nil {
+				fmt.Println("error annotating record:", err)
+				return 1
+			}
+			return 0
+		}},
+		"approve": {"<plan-id>", "approve the held back changes of a plan", false, func(args []string, opts runOptions) int {
+			if err := runApprove(args); err != nil {
+				fmt.Println("error approving plan:", err)
+				return 1
+			}
+			return 0
+		}},
+		"verify-history": {"", "verify the signatures of the change history", false, func(args []string, opts runOptions) int {
+			return exitCode(runVerifyHistory())
+		}},
+		"tui": {"", "show the live state in the terminal", false, func(args []string, opts runOptions) int {
+			if err := runTUI(opts); err != nil {
+				fmt.Println("error running tui:", err)
+				return 1
+			}
+			return 0
+		}},
+	}
+}
+
+func exitCode(ok bool) int {
+	if ok {
+		return 0
+	}
+	return 1
+}
+
+func runToggle(name string, records []string) int {
+	if err := runDisable(records, name == "disable"); err != nil {
+		fmt.Printf("error in %s: %s\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func runAudit(args []string, opts runOptions) int {
+	opts.update, opts.verbose = false, true
+	if err := loadDesiredState(); err != nil {
+		fmt.Println("error loading desired state:", err)
+		return 1
+	}
+	if config.DesiredState.File != "" {
+		fmt.Printf("desired state: %s at revision %s\n", config.DesiredState.File, desired.revision)
+	}
+	auditDelegation()
+	inconsistent := auditAXFR()
+	if !runOnce(opts) || runErrors > 0 || inconsistent > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runVersion(args []string, opts runOptions) int {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		fmt.Println("hetzner-dns-update (unknown version)")
+		return 0
+	}
+	version := info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && (version == "" || version == "(devel)") {
+			version = setting.Value
+		}
+	}
+	fmt.Printf("hetzner-dns-update %s (%s)\n", version, info.GoVersion)
+	return 0
+}
+
+func runZones(args []string, opts runOptions) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ZONE\tID\tSTATUS\tRECORDS\tTTL")
+	err := dnsClient().Zones(func(zone Zone) {
+		status := zone.Status
+		if zone.Paused {
+			status += ", paused"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", zone.Name, zone.ID, status, zone.RecordsCount, zone.TTL)
+	})
+	w.Flush()
+	if err != nil {
+		fmt.Println("error listing zones:", err)
+		return 1
+	}
+	return 0
+}
+
+func runRecords(args []string, opts runOptions) int {
+	zones := args
+	if len(zones) == 0 {
+		for _, fullDomain := range configuredRecords() {
+			if _, zone, ok := strings.Cut(fullDomain, "."); ok && !slices.Contains(zones, zone) {
+				zones = append(zones, zone)
+			}
+		}
+		for _, filter := range config.Filters {
+			if !slices.Contains(zones, filter.Zone) {
+				zones = append(zones, filter.Zone)
+			}
+		}
+	}
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tVALUE\tTTL\tID")
+	for _, name := range zones {
+		zone, err := findZone(name)
+		if err == nil {
+			err = eachRecord(zone.ID, func(rec Record) {
+				ttl := "-"
+				if rec.TTL > 0 {
+					ttl = fmt.Sprint(rec.TTL)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", recordName(rec.Name, zone.Name), rec.Type, rec.Value, ttl, rec.ID)
+			})
+		}
+		if err != nil {
+			w.Flush()
+			fmt.Printf("error listing records of %s: %s\n", name, err)
+			failed = true
+		}
+	}
+	w.Flush()
+	return exitCode(!failed)
+}
+
+// recordName returns the full name of a record of a zone
+func recordName(name, zone string) string {
+	if name == "@" {
+		return zone
+	}
+	return name + "." + zone
+}
+
+// printUsage lists the subcommands and the flags, except the hidden ones
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command [args]]\n\nCommands:\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	for name := range runCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		if usage, ok := runCommands[name]; ok {
+			fmt.Fprintf(w, "  %s\t%s\n", name, usage)
+			continue
+		}
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %s\t%s\n", strings.TrimSpace(name+" "+cmd.args), cmd.usage)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "\nFlags:")
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlag(f.Name) {
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
+}
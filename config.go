@@ -0,0 +1,615 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type Config struct {
+	APIToken string     `json:"api_token"`
+	Records  []string   `json:"records"`
+	TTL      Seconds    `json:"ttl"`
+	SMTP     SMTPConfig `json:"smtp"`
+	Logfile  string     `json:"logfile"`
+
+	// "text" (default) or "json" for one JSON object per line
+	LogFormat string `json:"log_format,omitempty"`
+	// "file" (default), "syslog", "stdout" or "stderr"
+	LogTarget string `json:"log_target,omitempty"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+
+	// "hetzner" (default) or "rfc2136" for the server in 'nsupdate'
+	Provider  string                    `json:"provider,omitempty"`
+	NSUpdate  NSUpdateConfig            `json:"nsupdate"`
+	Providers map[string]ProviderConfig `json:"providers,omitempty"`
+	Retry     RetryConfig               `json:"retry"`
+	Timeouts  TimeoutsConfig            `json:"timeouts"`
+	RateLimit RateLimitConfig           `json:"rate_limit"`
+
+	DesiredState DesiredStateConfig `json:"desired_state"`
+
+	AllowDelete      *bool    `json:"allow_delete,omitempty"`
+	MaxChangesPerRun int      `json:"max_changes_per_run,omitempty"`
+	ChangeWindows    []string `json:"change_windows,omitempty"`
+	LintIgnore       []string `json:"lint_ignore,omitempty"`
+
+	// plain HTTP echo services are refused unless set
+	AllowInsecureDetection bool `json:"allow_insecure_detection,omitempty"`
+
+	// echo services for the public IPs, tried in order until one answers:
+	// "ipify" (default), "icanhazip", "ifconfig.co" or a custom URL
+	Detection []string `json:"detection,omitempty"`
+
+	Filters  []RecordFilter `json:"filters,omitempty"`
+	Discover bool           `json:"discover,omitempty"`
+
+	Overrides  map[string]RecordOverride    `json:"overrides,omitempty"`
+	TXTRecords []TXTRecordConfig            `json:"txt_records,omitempty"`
+	Labels     map[string]map[string]string `json:"labels,omitempty"`
+
+	Heartbeat    string             `json:"heartbeat,omitempty"`
+	Coordination CoordinationConfig `json:"coordination"`
+	Failover     FailoverConfig     `json:"failover"`
+	ChangeNotify ChangeNotifyConfig `json:"change_notify"`
+	MetricsFile  string             `json:"metrics_file,omitempty"`
+	MetricsPush  MetricsPushConfig  `json:"metrics_push"`
+	CheckMK      CheckMKConfig      `json:"checkmk"`
+
+	Interval          int              `json:"interval,omitempty"`
+	DetectInterval    int              `json:"detect_interval,omitempty"`
+	Profile           string           `json:"profile,omitempty"`
+	LowImpactInterval int              `json:"low_impact_interval,omitempty"`
+	ControlAPI        ControlAPIConfig `json:"control_api"`
+	WebUI             WebUIConfig      `json:"web_ui"`
+	DynDNS2           DynDNS2Config    `json:"dyndns2"`
+
+	ZoneImport  ZoneImportConfig  `json:"zone_import"`
+	SOATracking SOATrackingConfig `json:"soa_tracking"`
+	MemoryLimit int               `json:"memory_limit_mb,omitempty"`
+
+	State StateConfig `json:"state"`
+	Agent AgentConfig `json:"agent"`
+	Batch BatchConfig `json:"batch"`
+
+	Telegram TelegramConfig `json:"telegram"`
+	Slack    SlackConfig    `json:"slack"`
+
+	Proxy ProxyConfig `json:"proxy"`
+	DoH   string      `json:"doh,omitempty"`
+
+	TTLRamp TTLRampConfig `json:"ttl_ramp"`
+	AXFR    AXFRConfig    `json:"axfr"`
+
+	SplitHorizon SplitHorizonConfig `json:"split_horizon"`
+
+	CloudEvents CloudEventsConfig `json:"cloudevents"`
+	HCloudToken string            `json:"hcloud_token,omitempty"`
+
+	Fallback    FallbackConfig    `json:"fallback"`
+	RenewalHook RenewalHookConfig `json:"renewal_hook"`
+	Backup      BackupConfig      `json:"backup"`
+	Approval    ApprovalConfig    `json:"approval"`
+	LatencySLO  LatencySLOConfig  `json:"latency_slo"`
+	IPv6Prefix  IPv6PrefixConfig  `json:"ipv6_prefix"`
+	CrossCheck  CrossCheckConfig  `json:"cross_check"`
+
+	Signal SignalConfig `json:"signal"`
+	XMPP   XMPPConfig   `json:"xmpp"`
+	SMS    SMSConfig    `json:"sms"`
+	Ticket TicketConfig `json:"ticket"`
+
+	Notifications NotificationsConfig `json:"notifications"`
+	SIEM          SIEMConfig          `json:"siem"`
+	ChangeSigning ChangeSigningConfig `json:"change_signing"`
+}
+
+type SMTPConfig struct {
+	Server    string `json:"server"`
+	Port      string `json:"port"`
+	User      string `json:"user"`
+	Password  string `json:"password"`
+	Recipient string `json:"recipient"`
+}
+
+// RecordFilter selects A/AAAA records of a zone by name, e.g. all 'dyn-.*';
+// the regular expressions must match the whole name
+type RecordFilter struct {
+	Zone    string `json:"zone"`
+	Include string `json:"include"`
+	Exclude string `json:"exclude"`
+}
+
+// RecordOverride changes the TTL, the managed address families or the
+// source of the addresses of one record, e.g. of a name generated from
+// '{vpn,mail,www}.example.com'; with 'probe' a new address is only
+// published if the service answers there, with 'expect' the new address
+// is checked after publishing; 'internal' is the source of the address
+// pushed to the split-horizon server, "none" to skip the record. The key
+// may be a template too, an 'ipv6_suffix' like '::{1..20}' is then
+// expanded along with it
+type RecordOverride struct {
+	TTL          Seconds  `json:"ttl"`
+	Family       string   `json:"family"`
+	Source       string   `json:"source,omitempty"`
+	Probe        string   `json:"probe,omitempty"`
+	ProbeFailure string   `json:"probe_failure,omitempty"`
+	IPv6Suffix   string   `json:"ipv6_suffix,omitempty"`
+	Expect       []string `json:"expect,omitempty"`
+	Internal     string   `json:"internal,omitempty"`
+}
+
+// TXTRecordConfig declares the values of a TXT record set, existing
+// SPF/DKIM/DMARC values are only removed if the set is 'owned'
+type TXTRecordConfig struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+	Owned  bool     `json:"owned"`
+}
+
+// CoordinationConfig lets several instances share one lock TXT record so
+// usually only the current holder applies changes and a standby takes over
+// once the holder stops refreshing it; the lock is advisory, instances
+// racing for a free or stale lock may both apply their changes
+type CoordinationConfig struct {
+	Lock       string `json:"lock"`
+	Instance   string `json:"instance"`
+	StaleAfter int    `json:"stale_after"`
+}
+
+// FailoverConfig makes a standby site publish its own IP only while the
+// primary site's heartbeat TXT record is stale
+type FailoverConfig struct {
+	Role       string `json:"role"`
+	Heartbeat  string `json:"heartbeat"`
+	StaleAfter int    `json:"stale_after"`
+}
+
+// ChangeNotifyConfig controls emails about applied record changes
+type ChangeNotifyConfig struct {
+	Enabled       bool   `json:"enabled"`
+	GeoLookup     bool   `json:"geo_lookup"`
+	GeoURL        string `json:"geo_url"`
+	OnlyASNChange bool   `json:"only_asn_change"`
+}
+
+// MetricsPushConfig sends run metrics to statsd (UDP) or Graphite (plaintext TCP)
+type MetricsPushConfig struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Prefix   string `json:"prefix"`
+}
+
+// CheckMKConfig writes a local check result into the agent spool directory
+type CheckMKConfig struct {
+	Spool  string `json:"spool"`
+	MaxAge int    `json:"max_age"`
+}
+
+// ControlAPIConfig enables the HTTP control API in daemon mode
+type ControlAPIConfig struct {
+	Listen     string `json:"listen"`
+	GRPCListen string `json:"grpc_listen,omitempty"`
+	Token      string `json:"token"`
+}
+
+// WebUIConfig serves a read-only status page in daemon mode
+type WebUIConfig struct {
+	Listen   string `json:"listen"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// DynDNS2Config accepts updates from routers and clients speaking the
+// dyndns2 protocol (ddclient, inadyn, most router firmwares) in daemon mode
+type DynDNS2Config struct {
+	Listen   string                  `json:"listen"`
+	User     string                  `json:"user"`
+	Password string                  `json:"password"`
+	Agents   map[string]DynDNS2Agent `json:"agents,omitempty"`
+}
+
+// DynDNS2Agent is an additional login that may only update its own records
+type DynDNS2Agent struct {
+	Password string   `json:"password"`
+	Records  []string `json:"records"`
+}
+
+// ZoneImportConfig applies large batches by rewriting and re-importing the
+// whole zone file instead of one API call per record
+type ZoneImportConfig struct {
+	Enabled   bool `json:"enabled"`
+	Threshold int  `json:"threshold"`
+}
+
+// SOATrackingConfig logs the zone serial as seen by the primary and any
+// secondaries before and after changes are applied
+type SOATrackingConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Nameservers []string `json:"nameservers"`
+}
+
+// DesiredStateConfig reads additional records from a file in a git
+// repository, so DNS intent can be reviewed via pull requests
+type DesiredStateConfig struct {
+	File string `json:"file"`
+}
+
+// StateConfig selects where the run state and history are kept: a JSON
+// file (default), an SQLite database or a Redis server shared by a fleet
+type StateConfig struct {
+	Backend  string `json:"backend"`
+	Path     string `json:"path"`
+	Address  string `json:"address"`
+	Password string `json:"password"`
+	Key      string `json:"key"`
+}
+
+// AgentConfig turns this instance into an agent that only detects its
+// addresses and reports them to the dyndns2 server of a controller, so
+// no API token is needed on the device
+type AgentConfig struct {
+	Controller string   `json:"controller"`
+	User       string   `json:"user"`
+	Password   string   `json:"password"`
+	Records    []string `json:"records,omitempty"`
+}
+
+// BatchConfig spreads many single changes over time: after 'size' API
+// calls the run pauses, so a burst does not trip the rate limit midway
+type BatchConfig struct {
+	Size  int     `json:"size"`
+	Pause Seconds `json:"pause"`
+}
+
+// TelegramConfig enables bot commands like 'status' or 'freeze' in daemon
+// mode, messages from other users are ignored
+type TelegramConfig struct {
+	Token        string  `json:"token"`
+	AllowedUsers []int64 `json:"allowed_users"`
+}
+
+// SignalConfig sends the notifications via signal-cli from 'account' to
+// the recipients, through its JSON-RPC endpoint if 'rpc_url' is set
+type SignalConfig struct {
+	Account    string   `json:"account"`
+	Recipients []string `json:"recipients"`
+	Command    string   `json:"command,omitempty"`
+	RPCURL     string   `json:"rpc_url,omitempty"`
+}
+
+// XMPPConfig sends the notifications from the account 'jid' to the
+// recipient JIDs
+type XMPPConfig struct {
+	JID        string   `json:"jid"`
+	Password   string   `json:"password"`
+	Server     string   `json:"server,omitempty"`
+	Recipients []string `json:"recipients"`
+}
+
+// SMSConfig alerts the recipients only about persistent failures, after
+// 'after_runs' failed runs and rate limited; the provider is 'twilio'
+// (account SID, token), 'seven' (API key as token) or 'http' with a
+// gateway URL containing {to} and {text}
+type SMSConfig struct {
+	Provider    string   `json:"provider"`
+	Recipients  []string `json:"recipients"`
+	From        string   `json:"from,omitempty"`
+	Account     string   `json:"account,omitempty"`
+	Token       string   `json:"token,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	AfterRuns   int      `json:"after_runs,omitempty"`
+	MinInterval Seconds  `json:"min_interval,omitempty"`
+	PerDay      int      `json:"per_day,omitempty"`
+}
+
+// TicketConfig opens a ticket after 'after_runs' failed runs, at most one
+// a day: in Zammad with an API token, 'queue' as group and 'customer', or
+// in Jira with 'user' and API token, 'queue' as project key
+type TicketConfig struct {
+	System    string `json:"system"`
+	URL       string `json:"url"`
+	User      string `json:"user,omitempty"`
+	Token     string `json:"token"`
+	Queue     string `json:"queue"`
+	Customer  string `json:"customer,omitempty"`
+	IssueType string `json:"issue_type,omitempty"`
+	AfterRuns int    `json:"after_runs,omitempty"`
+}
+
+// NotificationsConfig switches channels off by name ('smtp', 'signal',
+// 'xmpp' or the name of a webhook) and adds generic webhooks. During the
+// 'quiet_hours' of a channel, cron expressions like 'change_windows', its
+// notifications are collected and sent as one digest afterwards; channels
+// without quiet hours, e.g. the one for critical failures, always send
+type NotificationsConfig struct {
+	Disabled   []string            `json:"disabled,omitempty"`
+	Webhooks   []NotifyWebhook     `json:"webhooks,omitempty"`
+	Timeout    Seconds             `json:"timeout,omitempty"`
+	QuietHours map[string][]string `json:"quiet_hours,omitempty"`
+}
+
+// NotifyWebhook posts {"subject", "body", "instance"} as JSON to the URL
+type NotifyWebhook struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// SIEMConfig exports changes and errors as syslog messages to 'address'
+// (host:port) via 'udp', 'tcp' or 'tls', in the 'format' 'rfc5424'
+// (structured data) or 'cef'
+type SIEMConfig struct {
+	Address      string `json:"address"`
+	Protocol     string `json:"protocol,omitempty"`
+	Format       string `json:"format,omitempty"`
+	EnterpriseID int    `json:"enterprise_id,omitempty"`
+}
+
+// ChangeSigningConfig signs each applied change in the history with the
+// base64 ed25519 'key', e.g. from
+// 'openssl genpkey -algorithm ed25519 -outform DER | tail -c 32 | base64';
+// 'verify-history' needs only the 'public_key'
+type ChangeSigningConfig struct {
+	Key       string `json:"key,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+// SlackConfig serves the same commands as a Slack slash command
+type SlackConfig struct {
+	Listen        string   `json:"listen"`
+	SigningSecret string   `json:"signing_secret"`
+	AllowedUsers  []string `json:"allowed_users"`
+}
+
+// ProxyConfig routes traffic through HTTP or SOCKS5 proxies, e.g. an SSH
+// tunnel opened with 'ssh -D 1080 jumphost', separately for API calls and
+// IP detection; without it HTTP_PROXY and HTTPS_PROXY apply
+type ProxyConfig struct {
+	API       string `json:"api"`
+	Detection string `json:"detection"`
+}
+
+// TTLRampConfig sets a changed record to the 'low' TTL and back to the
+// configured TTL once the address was stable for 'after'
+type TTLRampConfig struct {
+	Low   Seconds `json:"low"`
+	After Seconds `json:"after"`
+}
+
+// AXFRConfig lets 'audit' compare the API view of the zones with a zone
+// transfer from 'server', e.g. when the Hetzner secondary DNS is used
+type AXFRConfig struct {
+	Server        string `json:"server"`
+	TSIGName      string `json:"tsig_name"`
+	TSIGSecret    string `json:"tsig_secret"`
+	TSIGAlgorithm string `json:"tsig_algorithm"`
+}
+
+// NSUpdateConfig is the authoritative server of the 'rfc2136' provider,
+// e.g. BIND or Knot; it must allow zone transfers and dynamic updates for
+// the key
+type NSUpdateConfig struct {
+	Server        string `json:"server"`
+	TSIGName      string `json:"tsig_name,omitempty"`
+	TSIGSecret    string `json:"tsig_secret,omitempty"`
+	TSIGAlgorithm string `json:"tsig_algorithm,omitempty"`
+}
+
+// RetryConfig limits the retries of failed API calls, a 429 is retried
+// after its Retry-After unless that is longer than 'max_delay'
+type RetryConfig struct {
+	Attempts int     `json:"attempts,omitempty"`
+	MaxDelay Seconds `json:"max_delay,omitempty"`
+}
+
+// TimeoutsConfig limits each request to the APIs and to the IP detection
+// services, including reading the response
+type TimeoutsConfig struct {
+	API       Seconds `json:"api,omitempty"`
+	Detection Seconds `json:"detection,omitempty"`
+}
+
+// RateLimitConfig is the budget of Hetzner API requests per second of the
+// whole process, bursts of up to 'burst' requests are sent at once
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+}
+
+// ProviderConfig manages the records of a zone at another provider, e.g.
+// a fallback name below "dedyn.io" at deSEC ("desec") or below
+// "duckdns.org" at DuckDNS ("duckdns"); 'providers' is keyed by zone
+type ProviderConfig struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+// SplitHorizonConfig pushes the LAN addresses of the managed records to a
+// local DNS server while Hetzner gets the public ones: 'server' is
+// "rfc2136" (dynamic update to 'address', TSIG signed if a key is set),
+// "pihole" or "adguard" (web API at 'address'); the addresses come from
+// 'source' like for overrides, e.g. "interface:eth0"
+type SplitHorizonConfig struct {
+	Server        string  `json:"server"`
+	Address       string  `json:"address"`
+	Source        string  `json:"source"`
+	Zone          string  `json:"zone,omitempty"`
+	TTL           Seconds `json:"ttl,omitempty"`
+	TSIGName      string  `json:"tsig_name,omitempty"`
+	TSIGSecret    string  `json:"tsig_secret,omitempty"`
+	TSIGAlgorithm string  `json:"tsig_algorithm,omitempty"`
+	Username      string  `json:"username,omitempty"`
+	Password      string  `json:"password,omitempty"`
+}
+
+// CloudEventsConfig posts every record change as CloudEvent to 'url',
+// 'mode' is "structured" (default) or "binary"
+type CloudEventsConfig struct {
+	URL    string `json:"url"`
+	Source string `json:"source"`
+	Mode   string `json:"mode"`
+}
+
+// FallbackConfig publishes fixed addresses, e.g. of a status page host,
+// while IP detection fails for longer than 'after'; records of a family
+// without a fallback address are handled like an undetected family
+type FallbackConfig struct {
+	IPv4  string  `json:"ipv4"`
+	IPv6  string  `json:"ipv6"`
+	After Seconds `json:"after"`
+}
+
+// RenewalHookConfig runs a command and/or touches a flag file once applied
+// changes are answered by the authoritative nameservers, e.g. to renew
+// certificates whose HTTP-01 validation fails until DNS has converged;
+// 'timeout' limits the wait for the nameservers, also for the latency SLO
+type RenewalHookConfig struct {
+	Command  string   `json:"command"`
+	FlagFile string   `json:"flag_file"`
+	Records  []string `json:"records"`
+	Timeout  Seconds  `json:"timeout"`
+}
+
+// BackupConfig exports the affected zones to timestamped zone files in
+// 'dir' before a run with deletes or more than 'updates' updates
+type BackupConfig struct {
+	Dir     string `json:"dir"`
+	Updates int    `json:"updates"`
+	Keep    int    `json:"keep"`
+}
+
+// ApprovalConfig holds back deletes and changes of the records in
+// 'requires_approval' until their plan is approved, 'url' is the web UI
+// base for signed approval links
+type ApprovalConfig struct {
+	Deletes bool     `json:"deletes"`
+	Records []string `json:"requires_approval"`
+	Secret  string   `json:"secret"`
+	URL     string   `json:"url"`
+}
+
+// LatencySLOConfig tracks the time from detecting an IP change to the
+// nameservers answering with it, and the share of changes within 'target'
+type LatencySLOConfig struct {
+	Enabled bool    `json:"enabled"`
+	Target  Seconds `json:"target"`
+}
+
+// CrossCheckConfig names echo services, ideally in another network, that
+// return the public address they see; a difference to the detected IP is
+// notified
+type CrossCheckConfig struct {
+	IPv4 string `json:"ipv4"`
+	IPv6 string `json:"ipv6"`
+}
+
+// IPv6PrefixConfig keeps the host suffix of AAAA records of other hosts in
+// the network when the delegated prefix of 'length' bits changes
+type IPv6PrefixConfig struct {
+	Length  int      `json:"length"`
+	Records []string `json:"records"`
+}
+
+// configDirs are searched for the config file in order: in a snap its
+// common directory and ~/.config/hetzner-dns-update of the real home,
+// granted by the 'dot-config' plug, otherwise CONFIG_DIR or the working
+// directory
+func configDirs() []string {
+	if snap_dir := os.Getenv("SNAP_USER_COMMON"); snap_dir != "" {
+		dirs := []string{snap_dir}
+		if home := os.Getenv("SNAP_REAL_HOME"); home != "" {
+			dirs = append(dirs, filepath.Join(home, ".config", "hetzner-dns-update"))
+		}
+		return dirs
+	}
+	if env_dir := os.Getenv("CONFIG_DIR"); env_dir != "" {
+		return []string{env_dir}
+	}
+	config_dir, _ := os.Getwd()
+	return []string{config_dir}
+}
+
+func loadConfig(filename string) error {
+	dirs := configDirs()
+	configFile = filepath.Join(dirs[0], filename)
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, filename)); err == nil {
+			configFile = filepath.Join(dir, filename)
+			break
+		}
+	}
+	data, err := os.ReadFile(configFile)
+	if err == nil {
+		err = json.Unmarshal(data, &config)
+	}
+	// the config may come from snap settings or the environment alone
+	if err != nil && !((inSnap() || envConfigured()) && errors.Is(err, fs.ErrNotExist)) {
+		return snapConfigError(err)
+	}
+
+	found, snap_err := loadSnapSettings()
+	if snap_err != nil {
+		return snap_err
+	}
+	env_found, env_err := loadEnvSettings()
+	if env_err != nil {
+		return env_err
+	}
+	if err != nil && !found && !env_found {
+		return err
+	}
+	return nil
+}
+
+// addToConfig appends records and overrides to the config file as it is
+// on disk, values from snap settings, credentials or the environment are
+// not written to it
+func addToConfig(records []string, overrides map[string]RecordOverride) error {
+	file := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(configFile); err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var file_records []string
+	if raw, ok := file["records"]; ok {
+		if err := json.Unmarshal(raw, &file_records); err != nil {
+			return fmt.Errorf("records: %w", err)
+		}
+	}
+	raw, err := json.Marshal(append(file_records, records...))
+	if err != nil {
+		return err
+	}
+	file["records"] = raw
+
+	if len(overrides) > 0 {
+		file_overrides := make(map[string]json.RawMessage)
+		if raw, ok := file["overrides"]; ok {
+			if err := json.Unmarshal(raw, &file_overrides); err != nil {
+				return fmt.Errorf("overrides: %w", err)
+			}
+		}
+		for name, override := range overrides {
+			if file_overrides[name], err = json.Marshal(override); err != nil {
+				return err
+			}
+		}
+		if file["overrides"], err = json.Marshal(file_overrides); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configFile, append(data, '\n'), 0600)
+}
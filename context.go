@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runContext is cancelled by SIGINT or SIGTERM, all outgoing requests use
+// it, so an interrupted run stops at its current request instead of
+// waiting for it to time out
+var runContext = context.Background()
+
+// cancelOnSignal sets up runContext, a second signal after the first one
+// kills the process as usual
+func cancelOnSignal() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	runContext = ctx
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+}
+
+// interrupted reports whether the run was cancelled by a signal
+func interrupted() bool {
+	return runContext.Err() != nil
+}
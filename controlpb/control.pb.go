@@ -0,0 +1,657 @@
+// gRPC control interface of hetzner-dns-update, offers the same operations
+// as the HTTP control API. Regenerate the stubs with 'go generate ./controlpb'.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{0}
+}
+
+type StatusReply struct {
+	state             protoimpl.MessageState            `protogen:"open.v1"`
+	LastRun           *timestamppb.Timestamp            `protobuf:"bytes,1,opt,name=last_run,json=lastRun,proto3" json:"last_run,omitempty"`
+	DurationSeconds   float64                           `protobuf:"fixed64,2,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	Errors            int32                             `protobuf:"varint,3,opt,name=errors,proto3" json:"errors,omitempty"`
+	Changes           int32                             `protobuf:"varint,4,opt,name=changes,proto3" json:"changes,omitempty"`
+	LastError         string                            `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	Ipv4              string                            `protobuf:"bytes,6,opt,name=ipv4,proto3" json:"ipv4,omitempty"`
+	Ipv6              string                            `protobuf:"bytes,7,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	UpdateMode        bool                              `protobuf:"varint,8,opt,name=update_mode,json=updateMode,proto3" json:"update_mode,omitempty"`
+	NextRun           *timestamppb.Timestamp            `protobuf:"bytes,9,opt,name=next_run,json=nextRun,proto3" json:"next_run,omitempty"`
+	FrozenUntil       map[string]*timestamppb.Timestamp `protobuf:"bytes,10,rep,name=frozen_until,json=frozenUntil,proto3" json:"frozen_until,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	DetectFailedSince *timestamppb.Timestamp            `protobuf:"bytes,11,opt,name=detect_failed_since,json=detectFailedSince,proto3" json:"detect_failed_since,omitempty"`
+	FallbackActive    bool                              `protobuf:"varint,12,opt,name=fallback_active,json=fallbackActive,proto3" json:"fallback_active,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *StatusReply) Reset() {
+	*x = StatusReply{}
+	mi := &file_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusReply) ProtoMessage() {}
+
+func (x *StatusReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusReply.ProtoReflect.Descriptor instead.
+func (*StatusReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StatusReply) GetLastRun() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastRun
+	}
+	return nil
+}
+
+func (x *StatusReply) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *StatusReply) GetErrors() int32 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *StatusReply) GetChanges() int32 {
+	if x != nil {
+		return x.Changes
+	}
+	return 0
+}
+
+func (x *StatusReply) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *StatusReply) GetIpv4() string {
+	if x != nil {
+		return x.Ipv4
+	}
+	return ""
+}
+
+func (x *StatusReply) GetIpv6() string {
+	if x != nil {
+		return x.Ipv6
+	}
+	return ""
+}
+
+func (x *StatusReply) GetUpdateMode() bool {
+	if x != nil {
+		return x.UpdateMode
+	}
+	return false
+}
+
+func (x *StatusReply) GetNextRun() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRun
+	}
+	return nil
+}
+
+func (x *StatusReply) GetFrozenUntil() map[string]*timestamppb.Timestamp {
+	if x != nil {
+		return x.FrozenUntil
+	}
+	return nil
+}
+
+func (x *StatusReply) GetDetectFailedSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DetectFailedSince
+	}
+	return nil
+}
+
+func (x *StatusReply) GetFallbackActive() bool {
+	if x != nil {
+		return x.FallbackActive
+	}
+	return false
+}
+
+type RecordsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordsRequest) Reset() {
+	*x = RecordsRequest{}
+	mi := &file_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordsRequest) ProtoMessage() {}
+
+func (x *RecordsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordsRequest.ProtoReflect.Descriptor instead.
+func (*RecordsRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{2}
+}
+
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	A             string                 `protobuf:"bytes,1,opt,name=a,proto3" json:"a,omitempty"`
+	Aaaa          string                 `protobuf:"bytes,2,opt,name=aaaa,proto3" json:"aaaa,omitempty"`
+	LastCheck     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=last_check,json=lastCheck,proto3" json:"last_check,omitempty"`
+	LastChange    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=last_change,json=lastChange,proto3" json:"last_change,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Record) GetA() string {
+	if x != nil {
+		return x.A
+	}
+	return ""
+}
+
+func (x *Record) GetAaaa() string {
+	if x != nil {
+		return x.Aaaa
+	}
+	return ""
+}
+
+func (x *Record) GetLastCheck() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastCheck
+	}
+	return nil
+}
+
+func (x *Record) GetLastChange() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastChange
+	}
+	return nil
+}
+
+type RecordsReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       map[string]*Record     `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordsReply) Reset() {
+	*x = RecordsReply{}
+	mi := &file_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordsReply) ProtoMessage() {}
+
+func (x *RecordsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordsReply.ProtoReflect.Descriptor instead.
+func (*RecordsReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RecordsReply) GetRecords() map[string]*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type ReconcileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileRequest) Reset() {
+	*x = ReconcileRequest{}
+	mi := &file_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileRequest) ProtoMessage() {}
+
+func (x *ReconcileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{5}
+}
+
+type ReconcileReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileReply) Reset() {
+	*x = ReconcileReply{}
+	mi := &file_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileReply) ProtoMessage() {}
+
+func (x *ReconcileReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileReply.ProtoReflect.Descriptor instead.
+func (*ReconcileReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ReconcileReply) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type FreezeRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Record string                 `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	// Go duration like "2h", empty or "0" freezes until unfrozen
+	Duration      string `protobuf:"bytes,2,opt,name=duration,proto3" json:"duration,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FreezeRequest) Reset() {
+	*x = FreezeRequest{}
+	mi := &file_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FreezeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FreezeRequest) ProtoMessage() {}
+
+func (x *FreezeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FreezeRequest.ProtoReflect.Descriptor instead.
+func (*FreezeRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FreezeRequest) GetRecord() string {
+	if x != nil {
+		return x.Record
+	}
+	return ""
+}
+
+func (x *FreezeRequest) GetDuration() string {
+	if x != nil {
+		return x.Duration
+	}
+	return ""
+}
+
+type FreezeReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Record        string                 `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	FrozenUntil   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=frozen_until,json=frozenUntil,proto3" json:"frozen_until,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FreezeReply) Reset() {
+	*x = FreezeReply{}
+	mi := &file_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FreezeReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FreezeReply) ProtoMessage() {}
+
+func (x *FreezeReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FreezeReply.ProtoReflect.Descriptor instead.
+func (*FreezeReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FreezeReply) GetRecord() string {
+	if x != nil {
+		return x.Record
+	}
+	return ""
+}
+
+func (x *FreezeReply) GetFrozenUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FrozenUntil
+	}
+	return nil
+}
+
+var File_control_proto protoreflect.FileDescriptor
+
+const file_control_proto_rawDesc = "" +
+	"\n" +
+	"\rcontrol.proto\x12\x1bhetznerdnsupdate.control.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x0f\n" +
+	"\rStatusRequest\"\xef\x04\n" +
+	"\vStatusReply\x125\n" +
+	"\blast_run\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\alastRun\x12)\n" +
+	"\x10duration_seconds\x18\x02 \x01(\x01R\x0fdurationSeconds\x12\x16\n" +
+	"\x06errors\x18\x03 \x01(\x05R\x06errors\x12\x18\n" +
+	"\achanges\x18\x04 \x01(\x05R\achanges\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x05 \x01(\tR\tlastError\x12\x12\n" +
+	"\x04ipv4\x18\x06 \x01(\tR\x04ipv4\x12\x12\n" +
+	"\x04ipv6\x18\a \x01(\tR\x04ipv6\x12\x1f\n" +
+	"\vupdate_mode\x18\b \x01(\bR\n" +
+	"updateMode\x125\n" +
+	"\bnext_run\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\anextRun\x12\\\n" +
+	"\ffrozen_until\x18\n" +
+	" \x03(\v29.hetznerdnsupdate.control.v1.StatusReply.FrozenUntilEntryR\vfrozenUntil\x12J\n" +
+	"\x13detect_failed_since\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\x11detectFailedSince\x12'\n" +
+	"\x0ffallback_active\x18\f \x01(\bR\x0efallbackActive\x1aZ\n" +
+	"\x10FrozenUntilEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x120\n" +
+	"\x05value\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05value:\x028\x01\"\x10\n" +
+	"\x0eRecordsRequest\"\xa2\x01\n" +
+	"\x06Record\x12\f\n" +
+	"\x01a\x18\x01 \x01(\tR\x01a\x12\x12\n" +
+	"\x04aaaa\x18\x02 \x01(\tR\x04aaaa\x129\n" +
+	"\n" +
+	"last_check\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tlastCheck\x12;\n" +
+	"\vlast_change\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"lastChange\"\xc1\x01\n" +
+	"\fRecordsReply\x12P\n" +
+	"\arecords\x18\x01 \x03(\v26.hetznerdnsupdate.control.v1.RecordsReply.RecordsEntryR\arecords\x1a_\n" +
+	"\fRecordsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x129\n" +
+	"\x05value\x18\x02 \x01(\v2#.hetznerdnsupdate.control.v1.RecordR\x05value:\x028\x01\"\x12\n" +
+	"\x10ReconcileRequest\"(\n" +
+	"\x0eReconcileReply\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\"C\n" +
+	"\rFreezeRequest\x12\x16\n" +
+	"\x06record\x18\x01 \x01(\tR\x06record\x12\x1a\n" +
+	"\bduration\x18\x02 \x01(\tR\bduration\"d\n" +
+	"\vFreezeReply\x12\x16\n" +
+	"\x06record\x18\x01 \x01(\tR\x06record\x12=\n" +
+	"\ffrozen_until\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\vfrozenUntil2\x95\x03\n" +
+	"\aControl\x12^\n" +
+	"\x06Status\x12*.hetznerdnsupdate.control.v1.StatusRequest\x1a(.hetznerdnsupdate.control.v1.StatusReply\x12a\n" +
+	"\aRecords\x12+.hetznerdnsupdate.control.v1.RecordsRequest\x1a).hetznerdnsupdate.control.v1.RecordsReply\x12g\n" +
+	"\tReconcile\x12-.hetznerdnsupdate.control.v1.ReconcileRequest\x1a+.hetznerdnsupdate.control.v1.ReconcileReply\x12^\n" +
+	"\x06Freeze\x12*.hetznerdnsupdate.control.v1.FreezeRequest\x1a(.hetznerdnsupdate.control.v1.FreezeReplyB3Z1github.com/railduino/hetzner-dns-update/controlpbb\x06proto3"
+
+var (
+	file_control_proto_rawDescOnce sync.Once
+	file_control_proto_rawDescData []byte
+)
+
+func file_control_proto_rawDescGZIP() []byte {
+	file_control_proto_rawDescOnce.Do(func() {
+		file_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)))
+	})
+	return file_control_proto_rawDescData
+}
+
+var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_control_proto_goTypes = []any{
+	(*StatusRequest)(nil),         // 0: hetznerdnsupdate.control.v1.StatusRequest
+	(*StatusReply)(nil),           // 1: hetznerdnsupdate.control.v1.StatusReply
+	(*RecordsRequest)(nil),        // 2: hetznerdnsupdate.control.v1.RecordsRequest
+	(*Record)(nil),                // 3: hetznerdnsupdate.control.v1.Record
+	(*RecordsReply)(nil),          // 4: hetznerdnsupdate.control.v1.RecordsReply
+	(*ReconcileRequest)(nil),      // 5: hetznerdnsupdate.control.v1.ReconcileRequest
+	(*ReconcileReply)(nil),        // 6: hetznerdnsupdate.control.v1.ReconcileReply
+	(*FreezeRequest)(nil),         // 7: hetznerdnsupdate.control.v1.FreezeRequest
+	(*FreezeReply)(nil),           // 8: hetznerdnsupdate.control.v1.FreezeReply
+	nil,                           // 9: hetznerdnsupdate.control.v1.StatusReply.FrozenUntilEntry
+	nil,                           // 10: hetznerdnsupdate.control.v1.RecordsReply.RecordsEntry
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_control_proto_depIdxs = []int32{
+	11, // 0: hetznerdnsupdate.control.v1.StatusReply.last_run:type_name -> google.protobuf.Timestamp
+	11, // 1: hetznerdnsupdate.control.v1.StatusReply.next_run:type_name -> google.protobuf.Timestamp
+	9,  // 2: hetznerdnsupdate.control.v1.StatusReply.frozen_until:type_name -> hetznerdnsupdate.control.v1.StatusReply.FrozenUntilEntry
+	11, // 3: hetznerdnsupdate.control.v1.StatusReply.detect_failed_since:type_name -> google.protobuf.Timestamp
+	11, // 4: hetznerdnsupdate.control.v1.Record.last_check:type_name -> google.protobuf.Timestamp
+	11, // 5: hetznerdnsupdate.control.v1.Record.last_change:type_name -> google.protobuf.Timestamp
+	10, // 6: hetznerdnsupdate.control.v1.RecordsReply.records:type_name -> hetznerdnsupdate.control.v1.RecordsReply.RecordsEntry
+	11, // 7: hetznerdnsupdate.control.v1.FreezeReply.frozen_until:type_name -> google.protobuf.Timestamp
+	11, // 8: hetznerdnsupdate.control.v1.StatusReply.FrozenUntilEntry.value:type_name -> google.protobuf.Timestamp
+	3,  // 9: hetznerdnsupdate.control.v1.RecordsReply.RecordsEntry.value:type_name -> hetznerdnsupdate.control.v1.Record
+	0,  // 10: hetznerdnsupdate.control.v1.Control.Status:input_type -> hetznerdnsupdate.control.v1.StatusRequest
+	2,  // 11: hetznerdnsupdate.control.v1.Control.Records:input_type -> hetznerdnsupdate.control.v1.RecordsRequest
+	5,  // 12: hetznerdnsupdate.control.v1.Control.Reconcile:input_type -> hetznerdnsupdate.control.v1.ReconcileRequest
+	7,  // 13: hetznerdnsupdate.control.v1.Control.Freeze:input_type -> hetznerdnsupdate.control.v1.FreezeRequest
+	1,  // 14: hetznerdnsupdate.control.v1.Control.Status:output_type -> hetznerdnsupdate.control.v1.StatusReply
+	4,  // 15: hetznerdnsupdate.control.v1.Control.Records:output_type -> hetznerdnsupdate.control.v1.RecordsReply
+	6,  // 16: hetznerdnsupdate.control.v1.Control.Reconcile:output_type -> hetznerdnsupdate.control.v1.ReconcileReply
+	8,  // 17: hetznerdnsupdate.control.v1.Control.Freeze:output_type -> hetznerdnsupdate.control.v1.FreezeReply
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_control_proto_init() }
+func file_control_proto_init() {
+	if File_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_control_proto_goTypes,
+		DependencyIndexes: file_control_proto_depIdxs,
+		MessageInfos:      file_control_proto_msgTypes,
+	}.Build()
+	File_control_proto = out.File
+	file_control_proto_goTypes = nil
+	file_control_proto_depIdxs = nil
+}
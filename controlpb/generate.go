@@ -0,0 +1,5 @@
+// Package controlpb holds the generated client and server stubs of the gRPC
+// control interface, see control.proto
+package controlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
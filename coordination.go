@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultStaleAfter = 300
+
+func instanceName() string {
+	if config.Coordination.Instance != "" {
+		return config.Coordination.Instance
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// acquireLock returns whether this instance holds the lock, and the
+// current holder otherwise; the DNS API has no compare-and-set, so writing
+// and reading back the TXT record is best effort: two instances starting
+// at the same moment may both see their own write and apply changes
+func acquireLock(now time.Time) (bool, string, error) {
+	me := instanceName()
+	stale_after := time.Duration(config.Coordination.StaleAfter) * time.Second
+	if stale_after <= 0 {
+		stale_after = defaultStaleAfter * time.Second
+	}
+
+	value, err := getTXTRecord(config.Coordination.Lock)
+	if err != nil {
+		return false, "", err
+	}
+	owner, since, ok := parseLock(value)
+	if ok && owner != me && now.Sub(since) < stale_after {
+		return false, owner, nil
+	}
+
+	err = setTXTRecord(config.Coordination.Lock, fmt.Sprintf("%s %d", me, now.Unix()))
+	if err != nil {
+		return false, "", err
+	}
+
+	// another instance may have written the record meanwhile, this only
+	// narrows the window, it doesn't close it
+	value, err = getTXTRecord(config.Coordination.Lock)
+	if err != nil {
+		return false, "", err
+	}
+	owner, _, _ = parseLock(value)
+	return owner == me, owner, nil
+}
+
+func parseLock(value string) (string, time.Time, bool) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return fields[0], time.Unix(unix, 0), true
+}
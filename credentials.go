@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadCredentials overrides secrets with files passed by systemd via
+// LoadCredential= or ImportCredential= in $CREDENTIALS_DIRECTORY
+func loadCredentials() error {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return nil
+	}
+
+	for name, target := range secretFields() {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*target = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// secretFields maps the credential names to the secrets in the config
+func secretFields() map[string]*string {
+	return map[string]*string{
+		"api_token":                 &config.APIToken,
+		"smtp_password":             &config.SMTP.Password,
+		"control_api_token":         &config.ControlAPI.Token,
+		"web_ui_password":           &config.WebUI.Password,
+		"dyndns2_password":          &config.DynDNS2.Password,
+		"state_password":            &config.State.Password,
+		"agent_password":            &config.Agent.Password,
+		"telegram_token":            &config.Telegram.Token,
+		"slack_secret":              &config.Slack.SigningSecret,
+		"axfr_tsig_secret":          &config.AXFR.TSIGSecret,
+		"hcloud_token":              &config.HCloudToken,
+		"approval_secret":           &config.Approval.Secret,
+		"xmpp_password":             &config.XMPP.Password,
+		"sms_token":                 &config.SMS.Token,
+		"ticket_token":              &config.Ticket.Token,
+		"signing_key":               &config.ChangeSigning.Key,
+		"split_horizon_tsig_secret": &config.SplitHorizon.TSIGSecret,
+		"split_horizon_password":    &config.SplitHorizon.Password,
+		"nsupdate_tsig_secret":      &config.NSUpdate.TSIGSecret,
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+)
+
+// crossCheckIPs compares the detected addresses with an echo service in
+// another network, a mismatch hints at a split-tunnel VPN where services
+// are reachable on another address than the published one; the Hetzner
+// DNS API doesn't tell the source address of a request
+func crossCheckIPs(ipv4, ipv6 string) {
+	if lowImpact {
+		return
+	}
+	var mismatches []string
+	for _, check := range []struct {
+		family, url, detected string
+	}{{"IPv4", config.CrossCheck.IPv4, ipv4}, {"IPv6", config.CrossCheck.IPv6, ipv6}} {
+		if check.url == "" || check.detected == "" || check.detected == keepAddress {
+			continue
+		}
+		seen, err := echoIP(check.url)
+		if err != nil {
+			log.Printf("error cross-checking %s via %s: %s\n", check.family, check.url, err)
+			continue
+		}
+		if seen != check.detected {
+			mismatches = append(mismatches, fmt.Sprintf("%s: detected '%s', %s sees '%s'", check.family, check.detected, check.url, seen))
+		}
+	}
+
+	mismatch := strings.Join(mismatches, "\n")
+	if !live.setCrossCheck(mismatch) || mismatch == "" {
+		return
+	}
+	message := "the detected public IP differs from the cross-check, records may point at an address services are not reachable on:\n" + mismatch
+	log.Println(message)
+	sendNotification("DNS Update: public IP cross-check mismatch", message)
+}
+
+func echoIP(url string) (string, error) {
+	resp, err := getURL(detectionClient(), url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 100))
+	if err != nil {
+		return "", err
+	}
+	ip := strings.TrimSpace(string(body))
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("invalid address '%s'", ip)
+	}
+	return ip, nil
+}
+
+func validateCrossCheck() error {
+	for _, echo := range []string{config.CrossCheck.IPv4, config.CrossCheck.IPv6} {
+		if echo == "" {
+			continue
+		}
+		if err := validateDetectionURL(echo); err != nil {
+			return fmt.Errorf("cross_check: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+//go:build !minimal
+
+package main
+
+import (
+	"log"
+	"time"
+)
+
+const defaultInterval = 300
+
+func daemonInterval() time.Duration {
+	if lowImpact {
+		return lowImpactInterval()
+	}
+	if config.Interval <= 0 {
+		return defaultInterval * time.Second
+	}
+	return time.Duration(config.Interval) * time.Second
+}
+
+// runDaemon reconciles every 'interval' seconds or when triggered via the control API
+func runDaemon(opts runOptions) {
+	reconcile := make(chan struct{}, 1)
+	enableAPICache()
+	if config.ControlAPI.Listen != "" {
+		goSafe(func() { serveControlAPI(reconcile) })
+	}
+	if config.ControlAPI.GRPCListen != "" {
+		goSafe(func() { serveControlGRPC(reconcile) })
+	}
+	if config.WebUI.Listen != "" {
+		goSafe(func() { serveWebUI() })
+	}
+	if config.DynDNS2.Listen != "" {
+		goSafe(func() { serveDynDNS2(reconcile) })
+	}
+	if config.Telegram.Token != "" {
+		goSafe(func() { serveTelegram(reconcile) })
+	}
+	if config.Slack.Listen != "" {
+		goSafe(func() { serveSlack(reconcile) })
+	}
+	reconcileLoop(opts, reconcile)
+}
+
+func reconcileLoop(opts runOptions, reconcile <-chan struct{}) {
+	log.Printf("daemon started, reconciling every %s\n", daemonInterval())
+	backgroundVerification = true
+	for {
+		runOnce(opts)
+		if hits := apiCacheHits(); hits > 0 && opts.verbose {
+			log.Printf("%d API responses unchanged since the last run\n", hits)
+		}
+
+		// the interval depends on the profile selected by the run
+		interval := daemonInterval()
+		live.setNextRun(clock.Now().Add(interval))
+		if !waitForNextRun(interval, reconcile) {
+			log.Println("daemon interrupted, stopping")
+			return
+		}
+	}
+}
+
+// waitForNextRun returns after the interval, when a reconcile is requested
+// or when the quick check every 'detect_interval' sees a new address; it
+// returns false when runContext is cancelled
+func waitForNextRun(interval time.Duration, reconcile <-chan struct{}) bool {
+	timer := clock.NewTimer(interval)
+	defer timer.Stop()
+
+	var check <-chan time.Time
+	detect := time.Duration(config.DetectInterval) * time.Second
+	if detect > 0 && detect < interval && !lowImpact {
+		ticker := clock.NewTicker(detect)
+		defer ticker.Stop()
+		check = ticker.C()
+	}
+
+	for {
+		select {
+		case <-timer.C():
+			return true
+		case <-reconcile:
+			log.Println("reconcile requested")
+			return true
+		case <-check:
+			if ipChanged() {
+				log.Println("public IP changed, reconciling")
+				return true
+			}
+		case <-runContext.Done():
+			return false
+		}
+	}
+}
+
+// lastQuickCheck is the previous answer of the quick check, compared with
+// the next one instead of the addresses of the last run, which HTTP
+// detection may see differently, e.g. behind a proxy or with CGNAT
+var lastQuickCheck struct {
+	ipv4, ipv6 string
+}
+
+// ipChanged does a cheap DNS based check against its previous answer, a
+// family the check can't detect counts as unchanged
+func ipChanged() bool {
+	ipv4, ipv6, err := getPublicIPsDNS()
+	if err != nil {
+		return false
+	}
+	previous := lastQuickCheck
+	if ipv4 != "" {
+		lastQuickCheck.ipv4 = ipv4
+	}
+	if ipv6 != "" {
+		lastQuickCheck.ipv6 = ipv6
+	}
+	changed := func(was, is string) bool {
+		return was != "" && is != "" && was != is
+	}
+	return changed(previous.ipv4, ipv4) || changed(previous.ipv6, ipv6)
+}
+
+// requestReconcile triggers a reconcile unless one is already pending
+func requestReconcile(reconcile chan<- struct{}) {
+	select {
+	case reconcile <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// exit code of a run that had to skip some zones, the others were
+// reconciled
+const exitPartial = 3
+
+// SkippedZone is a zone that could not be read in a run, its records were
+// left as they are
+type SkippedZone struct {
+	Zone    string   `json:"zone"`
+	Error   string   `json:"error"`
+	Records []string `json:"records"`
+}
+
+// runSkipped collects the zones planChanges could not read
+var runSkipped []SkippedZone
+
+// skipZone marks the zone as unreadable for the rest of the run, its
+// records are skipped without asking the API again
+func skipZone(zone string, err error) {
+	log.Printf("error reading zone %s, skipping its records: %s\n", zone, err)
+	runSkipped = append(runSkipped, SkippedZone{Zone: zone, Error: err.Error()})
+}
+
+// skipRecord returns true if the zone of the record was skipped, the
+// record is added to it then
+func skipRecord(managed ManagedRecord) bool {
+	i := slices.IndexFunc(runSkipped, func(s SkippedZone) bool { return s.Zone == managed.Zone })
+	if i < 0 {
+		return false
+	}
+	runSkipped[i].Records = append(runSkipped[i].Records, managed.FullDomain)
+	return true
+}
+
+func skippedZones(skipped []SkippedZone) []string {
+	zones := make([]string, len(skipped))
+	for i, s := range skipped {
+		zones[i] = s.Zone
+	}
+	slices.Sort(zones)
+	return zones
+}
+
+// reportSkipped sums up the skipped zones in one message instead of an
+// error per record: a partial outage is a warning, it is an error only if
+// no zone could be read at all; false is returned in that case. The
+// warning is only sent when other zones are skipped than in the last run
+func reportSkipped(records []ManagedRecord, verbose bool) bool {
+	changed := live.setSkipped(runSkipped)
+	if len(runSkipped) == 0 {
+		return true
+	}
+	perZone := make(map[string]int)
+	for _, managed := range records {
+		perZone[managed.Zone]++
+	}
+	var lines []string
+	skippedRecords, failedZones := 0, 0
+	for _, s := range runSkipped {
+		skippedRecords += len(s.Records)
+		if len(s.Records) >= perZone[s.Zone] {
+			failedZones++
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", s.Zone, s.Error, strings.Join(s.Records, ", ")))
+	}
+	if verbose {
+		for _, line := range lines {
+			fmt.Println("- skipped zone", line)
+		}
+	}
+
+	if failedZones == len(perZone) {
+		logAndMail(fmt.Sprintf("error: no zone could be read, all %d records skipped: %s", skippedRecords, strings.Join(lines, "; ")))
+		return false
+	}
+	summary := fmt.Sprintf("warning: skipped %d of %d records in %d of %d zones, the others were reconciled",
+		skippedRecords, len(records), len(runSkipped), len(perZone))
+	runErrors++
+	live.setError(summary)
+	logEvent(logEntry{Level: "warn", Message: summary})
+	if changed {
+		sendNotification("DNS Update Warning: partial outage", summary+"\r\n\r\n"+strings.Join(lines, "\r\n"))
+	}
+	return true
+}
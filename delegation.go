@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// nameservers of Hetzner DNS, a zone must be delegated to these
+var hetznerNameservers = []string{"hydrogen.ns.hetzner.com", "oxygen.ns.hetzner.com", "helium.ns.hetzner.de"}
+
+// checkDelegation returns a problem description if the registrar does
+// not delegate the zone to the Hetzner nameservers, "" if it does
+func checkDelegation(zone string) (string, error) {
+	delegated, err := delegatedNameservers(zone)
+	if err != nil {
+		return "", err
+	}
+	if len(delegated) == 0 {
+		return fmt.Sprintf("zone '%s' is not delegated at all (no NS records at the parent zone)", zone), nil
+	}
+
+	var foreign, missing []string
+	for _, ns := range delegated {
+		if !slices.Contains(hetznerNameservers, ns) {
+			foreign = append(foreign, ns)
+		}
+	}
+	for _, ns := range hetznerNameservers {
+		if !slices.Contains(delegated, ns) {
+			missing = append(missing, ns)
+		}
+	}
+	switch {
+	case len(foreign) == len(delegated):
+		return fmt.Sprintf("zone '%s' is delegated to %s, not to Hetzner - updated records are not visible",
+			zone, strings.Join(delegated, ", ")), nil
+	case len(foreign) > 0:
+		return fmt.Sprintf("zone '%s' is also delegated to %s, resolvers asking those get other answers",
+			zone, strings.Join(foreign, ", ")), nil
+	case len(missing) > 0:
+		return fmt.Sprintf("zone '%s' is not delegated to %s", zone, strings.Join(missing, ", ")), nil
+	}
+	return "", nil
+}
+
+// delegatedNameservers asks a nameserver of the parent zone directly, so
+// the answer is the delegation set at the registrar and not a cached one
+func delegatedNameservers(zone string) ([]string, error) {
+	zone = strings.TrimSuffix(zone, ".")
+	_, parent, found := strings.Cut(zone, ".")
+	if !found {
+		return nil, fmt.Errorf("'%s' has no parent zone", zone)
+	}
+	parent_servers, err := lookupNS(parent)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up nameservers of '%s': %w", parent, err)
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeNS)
+	msg.RecursionDesired = false
+	client := &dns.Client{Timeout: 5 * time.Second}
+	for _, server := range parent_servers {
+		addrs, err := resolveHost(server)
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+		resp, _, err := client.ExchangeContext(runContext, msg, net.JoinHostPort(addrs[0], "53"))
+		if err != nil {
+			continue
+		}
+		// referrals are in the authority section, answers if the
+		// parent server is authoritative for the zone as well
+		var names []string
+		for _, rr := range append(resp.Answer, resp.Ns...) {
+			if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Hdr.Name, dns.Fqdn(zone)) {
+				names = append(names, strings.ToLower(strings.TrimSuffix(ns.Ns, ".")))
+			}
+		}
+		return names, nil
+	}
+	return nil, fmt.Errorf("no nameserver of '%s' answered", parent)
+}
+
+// lookupNS uses the DoH resolver if configured
+func lookupNS(zone string) ([]string, error) {
+	if config.DoH == "" {
+		records, err := net.LookupNS(zone)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, ns := range records {
+			names = append(names, strings.TrimSuffix(ns.Host, "."))
+		}
+		return names, nil
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeNS)
+	reply, err := dohExchange(msg, config.DoH, "")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, rr := range reply.Answer {
+		if ns, ok := rr.(*dns.NS); ok {
+			names = append(names, strings.TrimSuffix(ns.Ns, "."))
+		}
+	}
+	return names, nil
+}
+
+func resolveHost(host string) ([]string, error) {
+	if config.DoH != "" {
+		return dohResolve(host)
+	}
+	return net.LookupHost(host)
+}
+
+// auditDelegation prints a warning for every zone that is not delegated
+// to Hetzner, the most common reason for "updates don't work"
+func auditDelegation() {
+	for _, zone := range configuredZones() {
+		problem, err := checkDelegation(zone)
+		switch {
+		case err != nil:
+			fmt.Printf("warning: cannot check delegation of zone '%s': %s\n", zone, err)
+		case problem != "":
+			fmt.Println("warning:", problem)
+		default:
+			fmt.Printf("zone '%s' is delegated to Hetzner\n", zone)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type desiredState struct {
+	records   []string
+	overrides map[string]RecordOverride
+	revision  string
+}
+
+var desired desiredState
+
+// loadDesiredState re-reads the desired records file (CSV or JSON as for
+// import-records) and the git revision it was checked out at
+func loadDesiredState() error {
+	if config.DesiredState.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(config.DesiredState.File)
+	if err != nil {
+		return err
+	}
+	var imported []importedRecord
+	if strings.EqualFold(filepath.Ext(config.DesiredState.File), ".json") {
+		imported, err = parseImportJSON(data)
+	} else {
+		imported, err = parseImportCSV(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	state := desiredState{
+		overrides: make(map[string]RecordOverride),
+		revision:  gitRevision(config.DesiredState.File),
+	}
+	for _, rec := range imported {
+		name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec.Name), "."))
+		state.records = append(state.records, name)
+		override := RecordOverride{TTL: rec.TTL, Family: rec.Family}
+		if rec.TTL != 0 || rec.Family != "" {
+			state.overrides[name] = override
+		}
+	}
+
+	if state.revision != desired.revision {
+		log.Printf("desired state: %s at revision %s\n", config.DesiredState.File, state.revision)
+	}
+	desired = state
+	return nil
+}
+
+// gitRevision returns the commit hash of the repository containing file,
+// with a '-dirty' suffix if the file has uncommitted changes
+func gitRevision(file string) string {
+	dir := filepath.Dir(file)
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--short=12", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	revision := strings.TrimSpace(string(out))
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--", filepath.Base(file)).Output()
+	if err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// desiredStateFooter is appended to notifications
+func desiredStateFooter() string {
+	if config.DesiredState.File == "" {
+		return ""
+	}
+	return "\r\n-- \r\ndesired state: " + config.DesiredState.File + " at revision " + desired.revision + "\r\n"
+}
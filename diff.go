@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecordDiff describes one record change the same way in the log, the JSON
+// output, the Atom feed, CloudEvents and emails
+type RecordDiff struct {
+	Time     time.Time `json:"time"`
+	Record   string    `json:"record"`
+	Type     string    `json:"type"`
+	Action   string    `json:"action"`
+	OldValue string    `json:"old_value,omitempty"`
+	NewValue string    `json:"new_value,omitempty"`
+	OldTTL   Seconds   `json:"old_ttl,omitempty"`
+	NewTTL   Seconds   `json:"new_ttl,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+	RunID  string            `json:"run_id,omitempty"`
+
+	// set if 'change_signing' is configured, see signChange
+	Sequence  int    `json:"sequence,omitempty"`
+	Previous  string `json:"previous,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+func (d RecordDiff) String() string {
+	var detail string
+	switch d.Action {
+	case "create":
+		detail = fmt.Sprintf("'%s'", d.NewValue)
+	case "delete", "dedupe":
+		detail = fmt.Sprintf("'%s'", d.OldValue)
+	default:
+		detail = fmt.Sprintf("'%s' -> '%s'", d.OldValue, d.NewValue)
+		if d.NewTTL > 0 && d.OldTTL != d.NewTTL {
+			detail += fmt.Sprintf(", ttl %d -> %d", d.OldTTL, d.NewTTL)
+		}
+	}
+	if len(d.Labels) > 0 {
+		detail += " [" + formatLabels(d.Labels) + "]"
+	}
+	return fmt.Sprintf("%s record was %sd: %s (%s)", d.Type, d.Action, d.Record, detail)
+}
+
+func (c Change) diff() RecordDiff {
+	return RecordDiff{
+		Record:   c.FullDomain,
+		Type:     c.Type,
+		Action:   c.Action,
+		OldValue: c.OldValue,
+		NewValue: c.NewValue,
+		OldTTL:   c.OldTTL,
+		NewTTL:   c.TTL,
+		Labels:   recordLabels(c.FullDomain),
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// disabled records are kept under their own key, so a running daemon only
+// reads them and never overwrites the changes of 'disable' and 'enable'
+func disabledKey() string {
+	return stateKey() + ":disabled"
+}
+
+func loadDisabled() (map[string]time.Time, error) {
+	disabled := make(map[string]time.Time)
+	if state == nil {
+		return disabled, nil
+	}
+	data, err := state.load(disabledKey())
+	if err != nil || data == nil {
+		return disabled, err
+	}
+	err = json.Unmarshal(data, &disabled)
+	return disabled, err
+}
+
+// disabledRecords returns the names to skip in this run
+func disabledRecords() []string {
+	disabled, err := loadDisabled()
+	if err != nil {
+		log.Println("error loading disabled records:", err)
+	}
+	var names []string
+	for name := range disabled {
+		names = append(names, name)
+	}
+	return names
+}
+
+// runDisable handles 'disable <record>...' and 'enable <record>...', the
+// names may be globs like for '-skip'; without names it lists the
+// disabled records
+func runDisable(names []string, disable bool) error {
+	if state == nil {
+		return fmt.Errorf("no state store")
+	}
+	disabled, err := loadDisabled()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		var list []string
+		for name := range disabled {
+			list = append(list, name)
+		}
+		sort.Strings(list)
+		for _, name := range list {
+			fmt.Printf("%s (disabled %s)\n", name, disabled[name].Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if disable {
+			disabled[name] = clock.Now()
+			log.Printf("record '%s' disabled\n", name)
+			fmt.Println("disabled", name)
+		} else {
+			delete(disabled, name)
+			log.Printf("record '%s' enabled\n", name)
+			fmt.Println("enabled", name)
+		}
+	}
+	data, err := json.Marshal(disabled)
+	if err != nil {
+		return err
+	}
+	return state.save(disabledKey(), data)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// configuredZones returns the zones of all configured records and filters
+func configuredZones() []string {
+	var zones []string
+	seen := make(map[string]bool)
+	add := func(zone string) {
+		if zone != "" && !seen[zone] {
+			seen[zone] = true
+			zones = append(zones, zone)
+		}
+	}
+	for _, fullDomain := range configuredRecords() {
+		if parts := strings.SplitN(fullDomain, ".", 2); len(parts) == 2 {
+			add(parts[1])
+		}
+	}
+	for _, filter := range config.Filters {
+		add(filter.Zone)
+	}
+	return zones
+}
+
+// discoverRecords scans the configured zones for A/AAAA records whose value
+// equals the current public IP and which are not in 'seen' yet
+func discoverRecords(ipv4, ipv6 string, seen map[string]bool) ([]ManagedRecord, error) {
+	var found []ManagedRecord
+	for _, zone := range configuredZones() {
+		zoneID, err := findZoneID(zone)
+		if err != nil {
+			return found, err
+		}
+		err = eachRecord(zoneID, func(rec Record) {
+			if !addressable(rec) {
+				return
+			}
+			if !(rec.Type == "A" && ipv4 != "" && rec.Value == ipv4) &&
+				!(rec.Type == "AAAA" && ipv6 != "" && rec.Value == ipv6) {
+				return
+			}
+			fullDomain := rec.Name + "." + zone
+			if seen[fullDomain] {
+				return
+			}
+			seen[fullDomain] = true
+			found = append(found, newManagedRecord(fullDomain, rec.Name, zone))
+		})
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+func runDiscover(ipv4, ipv6 string) {
+	seen := make(map[string]bool)
+	for _, managed := range managedRecords(ipv4, ipv6) {
+		seen[managed.FullDomain] = true
+	}
+
+	found, err := discoverRecords(ipv4, ipv6, seen)
+	if err != nil {
+		fmt.Println("error discovering records:", err)
+		os.Exit(1)
+	}
+	if len(found) == 0 {
+		fmt.Println("no unmanaged records point at the current public IP")
+		return
+	}
+
+	fmt.Println("unmanaged records pointing at the current public IP:")
+	for _, managed := range found {
+		fmt.Println("-", managed.FullDomain)
+	}
+
+	if configFile == "" || !isTerminal(os.Stdin) {
+		fmt.Println("set \"discover\": true in the config to manage them implicitly")
+		return
+	}
+	fmt.Printf("add them to %s? [y/N] ", configFile)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+		return
+	}
+
+	var records []string
+	for _, managed := range found {
+		records = append(records, managed.FullDomain)
+	}
+	if err := addToConfig(records, nil); err != nil {
+		fmt.Println("error saving config file:", err)
+		os.Exit(1)
+	}
+	log.Printf("discover: added %d records to %s\n", len(found), configFile)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
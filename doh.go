@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// openDNSDoH answers myip.opendns.com like the OpenDNS resolvers do,
+// other resolvers don't know the name
+const openDNSDoH = "https://doh.opendns.com/dns-query"
+
+const dohTimeout = 10 * time.Second
+
+func validateDoH() error {
+	if config.DoH == "" {
+		return nil
+	}
+	doh_url, err := url.Parse(config.DoH)
+	if err != nil {
+		return fmt.Errorf("doh: %w", err)
+	}
+	if doh_url.Scheme != "https" || doh_url.Host == "" {
+		return fmt.Errorf("doh: '%s' is not an https URL", config.DoH)
+	}
+	return nil
+}
+
+// dohExchange sends a query to a DNS-over-HTTPS endpoint (RFC 8484),
+// network "tcp4" or "tcp6" selects the address family of the connection
+func dohExchange(msg *dns.Msg, endpoint, network string) (*dns.Msg, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(runContext, "POST", endpoint, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	// the resolver itself is looked up with the system resolver, use an
+	// address like https://1.1.1.1/dns-query to avoid that
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if network != "" {
+		dialer := &net.Dialer{}
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	client := &http.Client{Transport: transport, Timeout: dohTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, &StatusError{Op: "doh query", Code: resp.StatusCode, Status: resp.Status}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+	reply := new(dns.Msg)
+	if err := reply.Unpack(data); err != nil {
+		return nil, fmt.Errorf("doh reply: %w", err)
+	}
+	return reply, nil
+}
+
+// dohResolve looks up the addresses of a host via the configured resolver,
+// IP addresses are returned as they are
+func dohResolve(host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	var addrs []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(host), qtype)
+		reply, err := dohExchange(msg, config.DoH, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range reply.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, rr.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, rr.AAAA.String())
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("doh: no address for '%s'", host)
+	}
+	return addrs, nil
+}
+
+// dohDialContext is the dialer of the HTTP clients if 'doh' is set
+func dohDialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := dohResolve(host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{}
+	for _, ip := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,129 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// serveDynDNS2 implements GET /nic/update with the dyndns2 field conventions:
+// hostname (comma separated), myip (IPv4 and/or IPv6, comma separated),
+// myipv6 (inadyn) and the usual 'good', 'nochg', 'nohost', 'badauth' replies
+func serveDynDNS2(reconcile chan<- struct{}) {
+	if (config.DynDNS2.User == "" || config.DynDNS2.Password == "") && len(config.DynDNS2.Agents) == 0 {
+		log.Println("dyndns2 server disabled: 'user' and 'password' or 'agents' must be set")
+		return
+	}
+
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		handleDynDNS2(w, r, reconcile)
+	}
+	mux.HandleFunc("/nic/update", handler)
+	mux.HandleFunc("/v3/update", handler)
+
+	log.Println("dyndns2 server listening on", config.DynDNS2.Listen)
+	err := http.ListenAndServe(config.DynDNS2.Listen, mux)
+	if err != nil {
+		log.Println("error running dyndns2 server:", err)
+	}
+}
+
+func handleDynDNS2(w http.ResponseWriter, r *http.Request, reconcile chan<- struct{}) {
+	w.Header().Set("Content-Type", "text/plain")
+
+	user, password, ok := r.BasicAuth()
+	allowed, ok := dynDNS2Login(user, password, ok)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="hetzner-dns-update"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, "badauth")
+		return
+	}
+
+	query := r.URL.Query()
+	hostnames := strings.Split(query.Get("hostname"), ",")
+	ipv4, ipv6 := dynDNS2Addresses(query.Get("myip"), query.Get("myipv6"))
+	if ipv4 == "" && ipv6 == "" {
+		// ddclient convention: no myip means the address of the caller
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		ipv4, ipv6 = dynDNS2Addresses(host, "")
+	}
+
+	for _, hostname := range hostnames {
+		hostname = strings.TrimSpace(hostname)
+		if allowed != nil && !slices.Contains(allowed, hostname) {
+			fmt.Fprintln(w, "nohost")
+			continue
+		}
+		fmt.Fprintln(w, dynDNS2Update(hostname, ipv4, ipv6, reconcile))
+	}
+}
+
+// dynDNS2Login returns the records an agent may update, nil for the main user
+func dynDNS2Login(user, password string, ok bool) ([]string, bool) {
+	if !ok {
+		return nil, false
+	}
+	if agent, found := config.DynDNS2.Agents[user]; found && agent.Password != "" {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(agent.Password)) != 1 {
+			return nil, false
+		}
+		return append([]string{}, agent.Records...), true
+	}
+	if config.DynDNS2.User == "" || config.DynDNS2.Password == "" ||
+		subtle.ConstantTimeCompare([]byte(user), []byte(config.DynDNS2.User)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(config.DynDNS2.Password)) != 1 {
+		return nil, false
+	}
+	return nil, true
+}
+
+func dynDNS2Addresses(myip, myipv6 string) (string, string) {
+	ipv4, ipv6 := "", ""
+	for _, value := range strings.Split(myip+","+myipv6, ",") {
+		ip := net.ParseIP(strings.TrimSpace(value))
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			ipv4 = ip.String()
+		default:
+			ipv6 = ip.String()
+		}
+	}
+	return ipv4, ipv6
+}
+
+// dynDNS2Update takes the reported addresses of a record over for the
+// next run, which applies them with the gates of any other change: frozen
+// and disabled records, change windows, approval, the blast radius, the
+// coordination lock and the check mode
+func dynDNS2Update(hostname, ipv4, ipv6 string, reconcile chan<- struct{}) string {
+	if hostname == "" || !strings.Contains(hostname, ".") {
+		return "notfqdn"
+	}
+	if !slices.Contains(configuredRecords(), hostname) {
+		return "nohost"
+	}
+	if config.ReadOnly || live.isFrozen(hostname) || matchesAny(hostname, disabledRecords()) {
+		log.Printf("dyndns2: not taking over the addresses of %s, the record is read-only, frozen or disabled\n", hostname)
+		return "abuse"
+	}
+
+	reply := strings.TrimSpace(ipv4 + " " + ipv6)
+	current := live.recordList()[hostname]
+	if (ipv4 == "" || ipv4 == current.A) && (ipv6 == "" || ipv6 == current.AAAA) {
+		live.setReported(hostname, ipv4, ipv6)
+		return "nochg " + reply
+	}
+	log.Printf("dyndns2: %s reported '%s' / '%s', reconciling\n", hostname, ipv4, ipv6)
+	live.setReported(hostname, ipv4, ipv6)
+	requestReconcile(reconcile)
+	return "good " + reply
+}
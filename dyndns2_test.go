@@ -0,0 +1,44 @@
+//go:build !minimal
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDynDNS2UpdateIsReconciled(t *testing.T) {
+	useConfig(t, Config{Records: []string{"home.example.com", "nas.example.com"}})
+	useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	t.Cleanup(func() {
+		live.mu.Lock()
+		defer live.mu.Unlock()
+		delete(live.reported, "home.example.com")
+		delete(live.reported, "nas.example.com")
+		delete(live.frozen, "nas.example.com")
+	})
+	reconcile := make(chan struct{}, 1)
+
+	if reply := dynDNS2Update("home.example.com", "192.0.2.7", "", reconcile); reply != "good 192.0.2.7" {
+		t.Errorf("reply %q, want good", reply)
+	}
+	select {
+	case <-reconcile:
+	default:
+		t.Error("no reconcile requested for the reported address")
+	}
+	if reported, ok := live.reportedIPs("home.example.com"); !ok || reported.IPv4 != "192.0.2.7" || reported.IPv6 != "" {
+		t.Errorf("reported addresses %+v, want the IPv4 address only", reported)
+	}
+
+	live.freeze("nas.example.com", clock.Now().Add(time.Hour))
+	if reply := dynDNS2Update("nas.example.com", "192.0.2.8", "", reconcile); reply != "abuse" {
+		t.Errorf("frozen record replied %q, want abuse", reply)
+	}
+	if _, ok := live.reportedIPs("nas.example.com"); ok {
+		t.Error("address of a frozen record was taken over")
+	}
+	if reply := dynDNS2Update("www.example.com", "192.0.2.9", "", reconcile); reply != "nohost" {
+		t.Errorf("unknown record replied %q, want nohost", reply)
+	}
+}
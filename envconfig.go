@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const envPrefix = "HDU_"
+
+// hookEnvPrefix names the variables passed to hooks, they are never read
+// back as config keys, e.g. by a hook that runs the tool again
+const hookEnvPrefix = envPrefix + "HOOK_"
+
+// envVariable is a config key settable as HDU_<KEY> at the top level or
+// as HDU_<SECTION>_<KEY> in a section, e.g. HDU_API_TOKEN or HDU_SMTP_SERVER
+type envVariable struct {
+	name  string
+	value reflect.Value
+}
+
+func envVariables() []envVariable {
+	var variables []envVariable
+	root := reflect.ValueOf(&config).Elem()
+	for i := range root.NumField() {
+		name := envName(root.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		if strings.HasPrefix(envPrefix+name, hookEnvPrefix) {
+			continue
+		}
+		value := root.Field(i)
+		variables = append(variables, envVariable{envPrefix + name, value})
+		if value.Kind() != reflect.Struct {
+			continue
+		}
+		for j := range value.NumField() {
+			if sub := envName(value.Type().Field(j)); sub != "" {
+				variables = append(variables, envVariable{envPrefix + name + "_" + sub, value.Field(j)})
+			}
+		}
+	}
+	return variables
+}
+
+// envConfigured reports whether a config key is set in the environment,
+// the config file is optional then
+func envConfigured() bool {
+	for _, variable := range envVariables() {
+		if _, ok := os.LookupEnv(variable.name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadEnvSettings overlays the config with the environment, a value is
+// JSON or a plain string and lists may be whitespace separated, e.g.
+// HDU_RECORDS="a.example.com {b,c}.example.com" or as a JSON array
+func loadEnvSettings() (bool, error) {
+	found := false
+	for _, variable := range envVariables() {
+		raw, ok := os.LookupEnv(variable.name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(variable.value, raw); err != nil {
+			return false, fmt.Errorf("%s: %w", variable.name, err)
+		}
+		found = true
+	}
+	return found, nil
+}
+
+func envName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return ""
+	}
+	return strings.ToUpper(name)
+}
+
+func setEnvValue(value reflect.Value, raw string) error {
+	target := value.Addr().Interface()
+	if json.Unmarshal([]byte(raw), target) == nil {
+		return nil
+	}
+	if json.Unmarshal([]byte(strconv.Quote(raw)), target) == nil {
+		return nil
+	}
+	if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String {
+		value.Set(reflect.ValueOf(strings.Fields(raw)))
+		return nil
+	}
+	return fmt.Errorf("can't parse '%.40s' as %s", raw, value.Type())
+}
+
+// hookEnviron is the environment of a hook command without the HDU_
+// variables, which may hold the API token and other secrets
+func hookEnviron(vars ...string) []string {
+	env := slices.DeleteFunc(os.Environ(), func(entry string) bool {
+		return strings.HasPrefix(entry, envPrefix)
+	})
+	return append(env, vars...)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnvRecordsBraceTemplate(t *testing.T) {
+	useConfig(t, Config{})
+	t.Setenv("HDU_RECORDS", "{a,b}.example.com www.example.com")
+	if _, err := loadEnvSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"{a,b}.example.com", "www.example.com"}; !slices.Equal(config.Records, want) {
+		t.Errorf("got %q, want %q", config.Records, want)
+	}
+}
+
+func TestHookEnvironWithoutSecrets(t *testing.T) {
+	t.Setenv("HDU_API_TOKEN", "secret")
+	env := hookEnviron(hookEnvPrefix + "RECORDS=home.example.com/A")
+	for _, entry := range env {
+		if strings.HasPrefix(entry, "HDU_API_TOKEN=") {
+			t.Error("hook environment contains the API token")
+		}
+	}
+	if !slices.Contains(env, "HDU_HOOK_RECORDS=home.example.com/A") {
+		t.Errorf("hook variable missing in %q", env)
+	}
+}
+
+func TestHookVariablesNotConfig(t *testing.T) {
+	useConfig(t, Config{})
+	t.Setenv("HDU_HOOK_RECORDS", "home.example.com/A")
+	if found, err := loadEnvSettings(); found || err != nil {
+		t.Errorf("hook variable read as config: %v, %v", found, err)
+	}
+}
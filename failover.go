@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const defaultFailoverStaleAfter = 900
+
+func failoverHeartbeat() string {
+	if config.Failover.Heartbeat != "" {
+		return config.Failover.Heartbeat
+	}
+	return config.Heartbeat
+}
+
+func isStandby() bool {
+	return config.Failover.Role == "standby"
+}
+
+// primaryAge returns how long ago the primary bumped its heartbeat
+func primaryAge(now time.Time) (time.Duration, error) {
+	value, err := getTXTRecord(failoverHeartbeat())
+	if err != nil {
+		return 0, err
+	}
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid heartbeat value '%s'", value)
+	}
+	return now.Sub(time.Unix(unix, 0)), nil
+}
+
+// failoverTakeover decides whether the standby applies its own IP in this
+// run: only a heartbeat that was read and is stale counts, a failing read
+// may be a problem on this side and is retried with the next run
+func failoverTakeover(now time.Time) bool {
+	stale_after := time.Duration(config.Failover.StaleAfter) * time.Second
+	if stale_after <= 0 {
+		stale_after = defaultFailoverStaleAfter * time.Second
+	}
+
+	age, err := primaryAge(now)
+	if err != nil {
+		logAndMail("failover: error reading primary heartbeat, standing by: " + err.Error())
+		return false
+	}
+	if age < stale_after {
+		log.Printf("failover: primary heartbeat is %s old, standing by\n", age.Round(time.Second))
+		return false
+	}
+	log.Printf("failover: primary heartbeat is %s old, taking over\n", age.Round(time.Second))
+	return true
+}
+
+func notifyTakeover() {
+	if runChanges == 0 {
+		return
+	}
+	message := fmt.Sprintf("The primary site's heartbeat %s is stale.\r\n"+
+		"This standby (%s) has rewritten %d records to its own IP.\r\n", failoverHeartbeat(), instanceName(), runChanges)
+	log.Println("failover: standby took over")
+	sendNotification("DNS Failover: standby took over", message)
+}
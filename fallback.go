@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// the fallback is published after detection failed this long by default
+const defaultFallbackAfter = 900
+
+// fallbackIPs is used when IP detection failed, it returns the fallback
+// addresses once detection has been failing for 'fallback.after'; the
+// records of a family without a fallback address are kept
+func fallbackIPs() (string, string, bool) {
+	since := live.detectionFailed()
+	if config.Fallback.IPv4 == "" && config.Fallback.IPv6 == "" {
+		return "", "", false
+	}
+	after := config.Fallback.After
+	if after <= 0 {
+		after = defaultFallbackAfter
+	}
+	if elapsed(since) < time.Duration(after)*time.Second {
+		return "", "", false
+	}
+
+	if live.setFallback(true) {
+		message := fmt.Sprintf("IP detection has been failing since %s, publishing the fallback addresses '%s' / '%s'",
+			since.Format("2006-01-02 15:04:05"), config.Fallback.IPv4, config.Fallback.IPv6)
+		log.Println(message)
+		sendNotification("DNS Update: fallback addresses published", message)
+	}
+	return orKeep(config.Fallback.IPv4), orKeep(config.Fallback.IPv6), true
+}
+
+// detectionRecovered ends the fallback once detection works again
+func detectionRecovered(ipv4, ipv6 string) {
+	live.detectionOK()
+	if live.setFallback(false) {
+		message := fmt.Sprintf("IP detection works again, switching back from the fallback to '%s' / '%s'", ipv4, ipv6)
+		log.Println(message)
+		sendNotification("DNS Update: fallback ended", message)
+	}
+}
+
+func validateFallback() error {
+	if ip := config.Fallback.IPv4; ip != "" && (net.ParseIP(ip) == nil || net.ParseIP(ip).To4() == nil) {
+		return fmt.Errorf("fallback: invalid IPv4 address '%s'", ip)
+	}
+	if ip := config.Fallback.IPv6; ip != "" && (net.ParseIP(ip) == nil || net.ParseIP(ip).To4() != nil) {
+		return fmt.Errorf("fallback: invalid IPv6 address '%s'", ip)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFallbackKeepsUnconfiguredFamily(t *testing.T) {
+	useConfig(t, Config{Fallback: FallbackConfig{IPv4: "192.0.2.9", After: 60}})
+	m := useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	t.Cleanup(func() {
+		live.detectionOK()
+		live.setFallback(false)
+	})
+
+	if _, _, ok := fallbackIPs(); ok {
+		t.Fatal("fallback published before 'after' passed")
+	}
+	m.Advance(time.Minute)
+	ipv4, ipv6, ok := fallbackIPs()
+	if !ok || ipv4 != "192.0.2.9" || ipv6 != keepAddress {
+		t.Errorf("got '%s' / '%s' / %v, want the IPv4 fallback and the AAAA records kept", ipv4, ipv6, ok)
+	}
+}
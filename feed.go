@@ -0,0 +1,66 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  string      `xml:"author>name"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Content string `xml:"content"`
+}
+
+// serveAtomFeed lists the last record changes, newest first
+func serveAtomFeed(w http.ResponseWriter, r *http.Request) {
+	feed := atomFeed{
+		ID:      "urn:hetzner-dns-update:" + instanceName(),
+		Title:   "hetzner-dns-update on " + instanceName(),
+		Updated: clock.Now().UTC().Format(time.RFC3339),
+		Link:    atomLink{"self", "http://" + r.Host + r.URL.Path},
+		Author:  "hetzner-dns-update",
+	}
+
+	changes := live.changeList()
+	if len(changes) > 0 {
+		feed.Updated = changes[len(changes)-1].Time.UTC().Format(time.RFC3339)
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("urn:hetzner-dns-update:%s:%s:%s:%d", instanceName(), change.Record, change.Type, change.Time.UnixNano()),
+			Title:   change.String(),
+			Updated: change.Time.UTC().Format(time.RFC3339),
+			Content: fmt.Sprintf("%s at %s", change, change.Time.Format("2006-01-02 15:04:05")),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Println("error writing Atom feed:", err)
+	}
+}
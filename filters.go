@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type ManagedRecord struct {
+	FullDomain  string
+	Name        string
+	Zone        string
+	TTL         Seconds
+	OverrideTTL bool
+	Family      string
+	Source      string
+	IPv6Suffix  string
+
+	Probe        string
+	ProbeFailure string
+}
+
+// managedRecords returns the configured records followed by the records
+// selected by the filters from the live zone contents and, if enabled,
+// the records discovered to point at the current public IP
+func managedRecords(ipv4, ipv6 string) []ManagedRecord {
+	var managed []ManagedRecord
+	seen := make(map[string]bool)
+
+	for _, fullDomain := range configuredRecords() {
+		parts := strings.SplitN(fullDomain, ".", 2)
+		if len(parts) != 2 {
+			logAndMail("invalid domain name: " + fullDomain)
+			continue
+		}
+		// a desired-state name may also be configured
+		if seen[fullDomain] {
+			continue
+		}
+		seen[fullDomain] = true
+		managed = append(managed, newManagedRecord(fullDomain, parts[0], parts[1]))
+	}
+
+	for _, filter := range config.Filters {
+		names, err := filterRecords(filter)
+		if err != nil {
+			logAndMail(fmt.Sprintf("error applying filter for zone '%s': %s", filter.Zone, err))
+			continue
+		}
+		for _, name := range names {
+			fullDomain := name + "." + filter.Zone
+			if seen[fullDomain] {
+				continue
+			}
+			seen[fullDomain] = true
+			managed = append(managed, newManagedRecord(fullDomain, name, filter.Zone))
+		}
+	}
+
+	if config.Discover {
+		found, err := discoverRecords(ipv4, ipv6, seen)
+		if err != nil {
+			logAndMail("error discovering records: " + err.Error())
+		}
+		managed = append(managed, found...)
+	}
+	return managed
+}
+
+func filterRecords(filter RecordFilter) ([]string, error) {
+	zoneID, err := findZoneID(filter.Zone)
+	if err != nil {
+		return nil, err
+	}
+	all_names, err := listRecordNames(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	return filterNames(filter, all_names)
+}
+
+// filterNames returns the names matched by 'include' and not by 'exclude'
+func filterNames(filter RecordFilter, all_names []string) ([]string, error) {
+	include, err := filterPattern(filter.Include)
+	if err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	var exclude *regexp.Regexp
+	if filter.Exclude != "" {
+		exclude, err = filterPattern(filter.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+	}
+
+	var names []string
+	for _, name := range all_names {
+		if !include.MatchString(name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// filterPattern matches whole names only, so a pattern like 'dyn' doesn't
+// select 'www.dyndns' as well
+func filterPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// validateFilters rejects filters that can't work, and an empty 'include'
+// that would hand over every record of the zone
+func validateFilters() error {
+	for _, filter := range config.Filters {
+		if filter.Zone == "" {
+			return fmt.Errorf("filter: 'zone' is not set")
+		}
+		if strings.TrimSpace(filter.Include) == "" {
+			return fmt.Errorf("filter of zone '%s': 'include' is empty", filter.Zone)
+		}
+		if _, err := filterNames(filter, nil); err != nil {
+			return fmt.Errorf("filter of zone '%s': %w", filter.Zone, err)
+		}
+	}
+	return nil
+}
+
+// listRecordNames returns the distinct names of all A/AAAA records in a zone
+func listRecordNames(zoneID string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	err := eachRecord(zoneID, func(rec Record) {
+		if addressable(rec) && !seen[rec.Name] {
+			seen[rec.Name] = true
+			names = append(names, rec.Name)
+		}
+	})
+	return names, err
+}
+
+// addressable reports whether rec is an A/AAAA record usable as 'name.zone',
+// which excludes '@' and multi-label names
+func addressable(rec Record) bool {
+	if rec.Type != "A" && rec.Type != "AAAA" {
+		return false
+	}
+	return rec.Name != "@" && !strings.Contains(rec.Name, ".")
+}
+
+// nameList collects a repeatable command line flag like '-only'
+type nameList []string
+
+func (l *nameList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *nameList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// matchesAny reports whether the name matches one of the glob patterns,
+// e.g. 'vpn.example.com' or '*.example.com'
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectRecords restricts a run to the records given with '-only' and
+// drops those given with '-skip' or disabled
+func selectRecords(records []ManagedRecord, opts runOptions) []ManagedRecord {
+	var selected []ManagedRecord
+	for _, managed := range records {
+		if opts.selected(managed.FullDomain) {
+			selected = append(selected, managed)
+		}
+	}
+	if len(records) > 0 && len(selected) == 0 {
+		log.Println("warning: all managed records are skipped")
+	}
+	return selected
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFilterNamesAnchored(t *testing.T) {
+	names := []string{"dyn-home", "dyn-test1", "www", "mydyn-x", "dyn"}
+	got, err := filterNames(RecordFilter{Zone: "example.com", Include: "dyn-.*", Exclude: "dyn-test.*"}, names)
+	if err != nil || !slices.Equal(got, []string{"dyn-home"}) {
+		t.Errorf("got %v, %v, want only dyn-home", got, err)
+	}
+	got, _ = filterNames(RecordFilter{Zone: "example.com", Include: "dyn"}, names)
+	if !slices.Equal(got, []string{"dyn"}) {
+		t.Errorf("unanchored match: got %v, want only dyn", got)
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	for _, filter := range []RecordFilter{
+		{Zone: "example.com"},
+		{Zone: "example.com", Include: " "},
+		{Zone: "example.com", Include: "dyn-("},
+		{Zone: "example.com", Include: "dyn-.*", Exclude: "["},
+		{Include: "dyn-.*"},
+	} {
+		useConfig(t, Config{Filters: []RecordFilter{filter}})
+		if err := validateFilters(); err == nil {
+			t.Errorf("filter %+v was accepted", filter)
+		}
+	}
+	useConfig(t, Config{Filters: []RecordFilter{{Zone: "example.com", Include: "dyn-.*"}}})
+	if err := validateFilters(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestManagedRecordsOnce(t *testing.T) {
+	useConfig(t, Config{Records: []string{"home.example.com", "nas.example.com"}})
+	saved := desired
+	desired = desiredState{records: []string{"nas.example.com", "vpn.example.com"}}
+	t.Cleanup(func() { desired = saved })
+
+	var names []string
+	for _, managed := range managedRecords("192.0.2.1", "") {
+		names = append(names, managed.FullDomain)
+	}
+	if want := []string{"home.example.com", "nas.example.com", "vpn.example.com"}; !slices.Equal(names, want) {
+		t.Errorf("managed %v, want %v", names, want)
+	}
+}
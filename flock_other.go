@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+func lockFile(name string) (bool, error) {
+	return false, errors.New("file locking is not supported on this platform")
+}
@@ -0,0 +1,33 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockedFile stays referenced for the life of the process, the finalizer of
+// a collected *os.File would close it and release the lock
+var lockedFile *os.File
+
+// lockFile takes an exclusive lock like 'flock -n', it returns false if
+// another process holds it, the lock is released when the process exits
+func lockFile(name string) (bool, error) {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		file.Close()
+		return false, nil
+	}
+	if err != nil {
+		file.Close()
+		return false, err
+	}
+	lockedFile = file
+	return true, nil
+}
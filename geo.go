@@ -0,0 +1,105 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const defaultGeoURL = "https://ipinfo.io/%s/json"
+
+type GeoInfo struct {
+	IP      string `json:"ip"`
+	City    string `json:"city"`
+	Country string `json:"country"`
+	Org     string `json:"org"`
+}
+
+// ASN returns the AS number from an org like 'AS3320 Deutsche Telekom AG'
+func (g GeoInfo) ASN() string {
+	if asn, _, found := strings.Cut(g.Org, " "); found && strings.HasPrefix(asn, "AS") {
+		return asn
+	}
+	return ""
+}
+
+func (g GeoInfo) ISP() string {
+	if g.ASN() != "" {
+		return strings.TrimSpace(strings.TrimPrefix(g.Org, g.ASN()))
+	}
+	return g.Org
+}
+
+func (g GeoInfo) String() string {
+	var parts []string
+	for _, part := range []string{g.Org, g.City, g.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func lookupGeo(ip string) (GeoInfo, error) {
+	info := GeoInfo{IP: ip}
+	geo_url := config.ChangeNotify.GeoURL
+	if geo_url == "" {
+		geo_url = defaultGeoURL
+	}
+	resp, err := getURL(detectionClient(), fmt.Sprintf(geo_url, ip))
+	if err != nil {
+		return info, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return info, fmt.Errorf("geo lookup status: %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	return info, err
+}
+
+// notifyChange mails an applied change, enriched with ASN/geo context of
+// the old and new address if enabled
+func notifyChange(diff RecordDiff) {
+	if !config.ChangeNotify.Enabled {
+		return
+	}
+
+	oldIP, newIP := diff.OldValue, diff.NewValue
+	subject := fmt.Sprintf("DNS Update: %s record of %s changed", diff.Type, diff.Record)
+	body := diff.String() + "\r\n" + notesText(live.recordNotes(diff.Record))
+	if !config.ChangeNotify.GeoLookup || lowImpact {
+		if !config.ChangeNotify.OnlyASNChange {
+			sendNotification(subject, body)
+		}
+		return
+	}
+
+	newGeo, err := lookupGeo(newIP)
+	if err != nil {
+		log.Println("error looking up geo info:", err)
+	}
+	oldGeo := GeoInfo{}
+	if oldIP != "" {
+		oldGeo, err = lookupGeo(oldIP)
+		if err != nil {
+			log.Println("error looking up geo info:", err)
+		}
+	}
+
+	body += fmt.Sprintf("\r\nold: %s (%s)\r\nnew: %s (%s)\r\n", oldIP, oldGeo, newIP, newGeo)
+	asn_changed := oldGeo.ASN() != newGeo.ASN()
+	if asn_changed && oldGeo.ASN() != "" && newGeo.ASN() != "" {
+		body += fmt.Sprintf("\r\nISP changed from %s to %s\r\n", oldGeo.ISP(), newGeo.ISP())
+	} else if !asn_changed {
+		body += fmt.Sprintf("\r\nroutine change within %s\r\n", newGeo.Org)
+	}
+
+	if config.ChangeNotify.OnlyASNChange && !asn_changed {
+		return
+	}
+	sendNotification(subject, body)
+}
@@ -0,0 +1,124 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net"
+	"time"
+
+	"github.com/railduino/hetzner-dns-update/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// controlServer offers the operations of the HTTP control API via gRPC
+type controlServer struct {
+	controlpb.UnimplementedControlServer
+	reconcile chan<- struct{}
+}
+
+func serveControlGRPC(reconcile chan<- struct{}) {
+	if config.ControlAPI.Token == "" {
+		log.Println("gRPC control API disabled: 'token' is not set")
+		return
+	}
+
+	listener, err := net.Listen("tcp", config.ControlAPI.GRPCListen)
+	if err != nil {
+		log.Println("error running gRPC control API:", err)
+		return
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(requireGRPCToken))
+	controlpb.RegisterControlServer(server, &controlServer{reconcile: reconcile})
+
+	log.Println("gRPC control API listening on", config.ControlAPI.GRPCListen)
+	if err := server.Serve(listener); err != nil {
+		log.Println("error running gRPC control API:", err)
+	}
+}
+
+// requireGRPCToken expects the same bearer token as the HTTP control API
+// in the 'authorization' metadata
+func requireGRPCToken(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	expected := []byte("Bearer " + config.ControlAPI.Token)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value), expected) == 1 {
+			return handler(ctx, req)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func (s *controlServer) Status(ctx context.Context, req *controlpb.StatusRequest) (*controlpb.StatusReply, error) {
+	run := live.status()
+	reply := &controlpb.StatusReply{
+		LastRun:           timestamp(run.LastRun),
+		DurationSeconds:   run.Duration,
+		Errors:            int32(run.Errors),
+		Changes:           int32(run.Changes),
+		LastError:         run.LastError,
+		Ipv4:              run.IPv4,
+		Ipv6:              run.IPv6,
+		UpdateMode:        run.UpdateMode,
+		NextRun:           timestamp(run.NextRun),
+		FrozenUntil:       make(map[string]*timestamppb.Timestamp),
+		DetectFailedSince: timestamp(run.DetectFailedSince),
+		FallbackActive:    run.FallbackActive,
+	}
+	for name, until := range run.FrozenUntil {
+		reply.FrozenUntil[name] = timestamppb.New(until)
+	}
+	return reply, nil
+}
+
+func (s *controlServer) Records(ctx context.Context, req *controlpb.RecordsRequest) (*controlpb.RecordsReply, error) {
+	reply := &controlpb.RecordsReply{Records: make(map[string]*controlpb.Record)}
+	for name, rec := range live.recordList() {
+		reply.Records[name] = &controlpb.Record{
+			A:          rec.A,
+			Aaaa:       rec.AAAA,
+			LastCheck:  timestamp(rec.LastCheck),
+			LastChange: timestamp(rec.LastChange),
+		}
+	}
+	return reply, nil
+}
+
+func (s *controlServer) Reconcile(ctx context.Context, req *controlpb.ReconcileRequest) (*controlpb.ReconcileReply, error) {
+	requestReconcile(s.reconcile)
+	return &controlpb.ReconcileReply{Status: "reconcile scheduled"}, nil
+}
+
+func (s *controlServer) Freeze(ctx context.Context, req *controlpb.FreezeRequest) (*controlpb.FreezeReply, error) {
+	if req.Record == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing record")
+	}
+	until := time.Time{}
+	if req.Duration != "" {
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if duration > 0 {
+			until = clock.Now().Add(duration)
+		}
+	}
+	live.freeze(req.Record, until)
+	log.Printf("gRPC control API: record '%s' frozen until %s\n", req.Record, until.Format(time.RFC3339))
+	return &controlpb.FreezeReply{Record: req.Record, FrozenUntil: timestamp(until)}, nil
+}
+
+// timestamp leaves zero times unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// updateHeartbeat sets the TXT record 'heartbeat' to the current unix
+// timestamp so external monitoring can detect a dead updater via DNS
+func updateHeartbeat(now time.Time) error {
+	return setTXTRecord(config.Heartbeat, strconv.FormatInt(now.Unix(), 10))
+}
+
+func setTXTRecord(fullDomain, value string) error {
+	parts := strings.SplitN(fullDomain, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid TXT record name '%s'", fullDomain)
+	}
+	zoneID, err := writableZoneID(parts[1])
+	if err != nil {
+		return err
+	}
+	record, err := findRecord(zoneID, parts[0], "TXT")
+	if err != nil {
+		return err
+	}
+
+	value = encodeTXT(value)
+	if record.ID == "" {
+		return createRecord(zoneID, "TXT", parts[0], value, config.TTL)
+	}
+	return updateRecord(zoneID, record.ID, "TXT", parts[0], value, config.TTL)
+}
+
+// getTXTRecord returns the decoded value of a TXT record, or "" if there is none
+func getTXTRecord(fullDomain string) (string, error) {
+	parts := strings.SplitN(fullDomain, ".", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid TXT record name '%s'", fullDomain)
+	}
+	zoneID, err := findZoneID(parts[1])
+	if err != nil {
+		return "", err
+	}
+	record, err := findRecord(zoneID, parts[0], "TXT")
+	if err != nil {
+		return "", err
+	}
+	return decodeTXT(record.Value), nil
+}
+
+// findRecord returns the record with the given name and type, or an empty
+// record if there is none
+func findRecord(zoneID, name, recType string) (Record, error) {
+	found := Record{}
+	err := eachRecord(zoneID, func(rec Record) {
+		if rec.Name == name && rec.Type == recType {
+			found = rec
+		}
+	})
+	return found, err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9_]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+type importedRecord struct {
+	Name   string  `json:"name"`
+	TTL    Seconds `json:"ttl"`
+	Family string  `json:"family"`
+}
+
+// runImportRecords appends the records from a CSV file (name[,ttl[,family]])
+// or a JSON file (list of names or of {"name", "ttl", "family"}) to the config
+func runImportRecords(args []string) error {
+	flags := flag.NewFlagSet("import-records", flag.ExitOnError)
+	file := flags.String("file", "", "CSV or JSON file with records")
+	dryRun := flags.Bool("dry-run", false, "only validate the file")
+	flags.Parse(args)
+	if *file == "" {
+		return fmt.Errorf("missing -file")
+	}
+	if configFile == "" {
+		return fmt.Errorf("importing needs a config.json file")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var imported []importedRecord
+	if strings.EqualFold(filepath.Ext(*file), ".json") {
+		imported, err = parseImportJSON(data)
+	} else {
+		imported, err = parseImportCSV(data)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+
+	existing := configuredRecords()
+	overrides := make(map[string]RecordOverride)
+	var added []string
+	var errs []string
+	for i, rec := range imported {
+		name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec.Name), "."))
+		switch {
+		case !hostnamePattern.MatchString(name):
+			errs = append(errs, fmt.Sprintf("entry %d: invalid hostname '%s'", i+1, rec.Name))
+			continue
+		case slices.Contains(existing, name) || slices.Contains(added, name):
+			fmt.Println("skipping already managed record:", name)
+			continue
+		}
+		override := RecordOverride{TTL: rec.TTL, Family: rec.Family}
+		if rec.TTL != 0 || rec.Family != "" {
+			if config.Overrides == nil {
+				config.Overrides = make(map[string]RecordOverride)
+			}
+			config.Overrides[name] = override
+			overrides[name] = override
+		}
+		added = append(added, name)
+	}
+	if err := validateOverrides(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("validation failed:\n  %s", strings.Join(errs, "\n  "))
+	}
+
+	fmt.Printf("%d records to add, %d already managed\n", len(added), len(imported)-len(added))
+	if *dryRun || len(added) == 0 {
+		return nil
+	}
+	if err := addToConfig(added, overrides); err != nil {
+		return err
+	}
+	fmt.Println("updated", configFile)
+	return nil
+}
+
+func parseImportCSV(data []byte) ([]importedRecord, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var imported []importedRecord
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 || fields[0] == "" || strings.EqualFold(fields[0], "name") {
+			continue
+		}
+		rec := importedRecord{Name: fields[0]}
+		if len(fields) > 1 && strings.TrimSpace(fields[1]) != "" {
+			rec.TTL, err = parseSeconds(fields[1])
+			if err != nil {
+				line, _ := reader.FieldPos(1)
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if len(fields) > 2 {
+			rec.Family = strings.TrimSpace(fields[2])
+		}
+		imported = append(imported, rec)
+	}
+}
+
+func parseImportJSON(data []byte) ([]importedRecord, error) {
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		var imported []importedRecord
+		for _, name := range names {
+			imported = append(imported, importedRecord{Name: name})
+		}
+		return imported, nil
+	}
+	var imported []importedRecord
+	err := json.Unmarshal(data, &imported)
+	return imported, err
+}
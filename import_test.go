@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseImportCSVLine(t *testing.T) {
+	_, err := parseImportCSV([]byte("name,ttl\n# comment\na.example.com,60\nb.example.com,soon\n"))
+	if err == nil || !strings.HasPrefix(err.Error(), "line 4:") {
+		t.Errorf("got %v, want an error in line 4", err)
+	}
+}
+
+func TestAddToConfigKeepsFileValues(t *testing.T) {
+	useConfig(t, Config{APIToken: "from-env", Records: []string{"env.example.com"}})
+	previous := configFile
+	t.Cleanup(func() { configFile = previous })
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(configFile, []byte(`{"records": ["home.example.com"], "ttl": "5m"}`), 0600)
+
+	err := addToConfig([]string{"nas.example.com"}, map[string]RecordOverride{"nas.example.com": {TTL: 60}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(configFile)
+	var file struct {
+		APIToken  string                    `json:"api_token"`
+		Records   []string                  `json:"records"`
+		TTL       string                    `json:"ttl"`
+		Overrides map[string]RecordOverride `json:"overrides"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.APIToken != "" || file.TTL != "5m" {
+		t.Errorf("file values changed: %s", data)
+	}
+	if !slices.Equal(file.Records, []string{"home.example.com", "nas.example.com"}) || file.Overrides["nas.example.com"].TTL != 60 {
+		t.Errorf("records not added: %s", data)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+)
+
+// a /64 is the smallest prefix delegated for a LAN
+const defaultIPv6PrefixLength = 64
+
+// prefixes of the previous and the current public IPv6 address, valid only
+// if 'ipv6_prefix.length' is set; 'remaining' counts the records planChanges
+// found in the previous prefix
+var ipv6Prefixes struct {
+	previousIP        string
+	previous, current netip.Prefix
+	remaining         int
+}
+
+// trackIPv6Prefix remembers the prefixes of the previous and the current
+// public IPv6 address for prefixTarget; the previous prefix is kept in the
+// state until a run finds no record left in it, so a check-only or failed
+// run after the change doesn't lose it
+func trackIPv6Prefix(previousIP, ipv6 string) {
+	ipv6Prefixes.previousIP = previousIP
+	ipv6Prefixes.previous, ipv6Prefixes.current = netip.Prefix{}, netip.Prefix{}
+	ipv6Prefixes.remaining = 0
+	if config.IPv6Prefix.Length <= 0 {
+		return
+	}
+	current := ipv6Prefix(ipv6)
+	previous, _ := netip.ParsePrefix(live.status().OldIPv6Prefix)
+	if last := ipv6Prefix(previousIP); last.IsValid() && current.IsValid() && last != current {
+		log.Printf("IPv6 prefix changed from %s to %s, rewriting the AAAA records of the old prefix\n", last, current)
+		previous = last
+		live.setOldIPv6Prefix(previous.String())
+	}
+	if previous == current {
+		previous = netip.Prefix{}
+	}
+	ipv6Prefixes.previous, ipv6Prefixes.current = previous, current
+}
+
+// settleIPv6Prefix forgets the previous prefix once a run over all records
+// found none of them in it
+func settleIPv6Prefix(opts runOptions) {
+	if !ipv6Prefixes.previous.IsValid() || !ipv6Prefixes.current.IsValid() || ipv6Prefixes.remaining > 0 || len(runSkipped) > 0 ||
+		len(opts.only) > 0 || len(opts.skip) > 0 {
+		return
+	}
+	log.Printf("no AAAA records left in the old IPv6 prefix %s\n", ipv6Prefixes.previous)
+	live.setOldIPv6Prefix("")
+}
+
+func ipv6Prefix(ip string) netip.Prefix {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Is6() {
+		return netip.Prefix{}
+	}
+	prefix, _ := addr.Prefix(config.IPv6Prefix.Length)
+	return prefix
+}
+
+// prefixTarget returns the address an AAAA record should have: records of
+// other hosts in the current prefix are kept, those in the previous prefix
+// get the current prefix with their host suffix, those outside of both keep
+// their address; the record of this host follows the public IPv6 address.
+// moved reports a record taken out of the previous prefix
+func prefixTarget(managed ManagedRecord, value, ipv6 string) (target string, moved bool) {
+	current := ipv6Prefixes.current
+	if !current.IsValid() || value == "" || value == ipv6Prefixes.previousIP || managed.Source != "" ||
+		!matchesAny(managed.FullDomain, ipv6PrefixRecords()) {
+		return ipv6, false
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil || !addr.Is6() {
+		return ipv6, false
+	}
+	if current.Contains(addr) || !ipv6Prefixes.previous.Contains(addr) {
+		return value, false
+	}
+	return withPrefix(addr, current).String(), true
+}
+
+// suffixTarget returns the address with the host suffix of a record, e.g.
+// '::10', in the prefix of the public IPv6 address
+func suffixTarget(suffix, ipv6 string) string {
+	addr, err := netip.ParseAddr(ipv6)
+	host, host_err := netip.ParseAddr(suffix)
+	if err != nil || host_err != nil {
+		// rejected by validateOverrides
+		return ipv6
+	}
+	length := config.IPv6Prefix.Length
+	if length <= 0 {
+		length = defaultIPv6PrefixLength
+	}
+	prefix, _ := addr.Prefix(length)
+	return withPrefix(host, prefix).String()
+}
+
+// withPrefix takes the prefix bits from the prefix, host bits from addr
+func withPrefix(addr netip.Addr, prefix netip.Prefix) netip.Addr {
+	bytes, network := addr.As16(), prefix.Addr().As16()
+	bits := prefix.Bits()
+	for i := range 16 {
+		switch {
+		case (i+1)*8 <= bits:
+			bytes[i] = network[i]
+		case i*8 < bits:
+			mask := byte(0xff << (8 - bits%8))
+			bytes[i] = network[i]&mask | bytes[i]&^mask
+		}
+	}
+	return netip.AddrFrom16(bytes)
+}
+
+func ipv6PrefixRecords() []string {
+	if len(config.IPv6Prefix.Records) == 0 {
+		return []string{"*"}
+	}
+	return config.IPv6Prefix.Records
+}
+
+func validateIPv6Prefix() error {
+	if length := config.IPv6Prefix.Length; length < 0 || length > 128 {
+		return fmt.Errorf("ipv6_prefix: invalid length %d", length)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// recordLabels merges the labels of all matching 'labels' entries, keyed
+// by a zone or a record glob, longer keys override shorter ones
+func recordLabels(fullDomain string) map[string]string {
+	if len(config.Labels) == 0 {
+		return nil
+	}
+	keys := slices.SortedFunc(maps.Keys(config.Labels), func(a, b string) int {
+		return len(a) - len(b)
+	})
+	var labels map[string]string
+	for _, key := range keys {
+		if !strings.HasSuffix(fullDomain, "."+key) && !matchesAny(fullDomain, []string{key}) {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		maps.Copy(labels, config.Labels[key])
+	}
+	return labels
+}
+
+// formatLabels returns 'key=value' pairs sorted by key
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// promLabels returns the labels as Prometheus label pairs sorted by key,
+// each preceded by a comma
+func promLabels(labels map[string]string) string {
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		fmt.Fprintf(&b, ",%s=%q", key, labels[key])
+	}
+	return b.String()
+}
+
+func validateLabels() error {
+	for key, labels := range config.Labels {
+		for name := range labels {
+			if !labelName.MatchString(name) || name == "record" {
+				return fmt.Errorf("labels of '%s': invalid label name '%s'", key, name)
+			}
+		}
+	}
+	return nil
+}
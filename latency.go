@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"math"
+	"slices"
+	"time"
+)
+
+// the latency percentiles are computed over this many IP change events
+const maxLatencySamples = 100
+
+// LatencySample is the time from detecting an IP change to the nameservers
+// answering with the new address
+type LatencySample struct {
+	Detected time.Time `json:"detected"`
+	Verified time.Time `json:"verified"`
+	Seconds  float64   `json:"seconds"`
+}
+
+type LatencySummary struct {
+	Samples      int     `json:"samples"`
+	P50          float64 `json:"p50_seconds"`
+	P90          float64 `json:"p90_seconds"`
+	P99          float64 `json:"p99_seconds"`
+	Target       Seconds `json:"target_seconds,omitempty"`
+	WithinTarget float64 `json:"within_target_ratio,omitempty"`
+}
+
+// recordLatency takes a sample if the verified changes published the
+// current public IP, once per IP change event
+func recordLatency(changes []Change, verified time.Time) {
+	current := live.status()
+	published := slices.ContainsFunc(changes, func(change Change) bool {
+		return change.NewValue != "" && (change.NewValue == current.IPv4 || change.NewValue == current.IPv6)
+	})
+	history := live.ipHistory()
+	if !published || len(history) == 0 {
+		return
+	}
+	detected := history[len(history)-1].Time
+	sample := LatencySample{detected, verified, verified.Sub(detected).Seconds()}
+	if live.addLatency(sample) {
+		log.Printf("IP change detected at %s was answered by the nameservers after %.0fs\n",
+			detected.Format("2006-01-02 15:04:05"), sample.Seconds)
+	}
+}
+
+// summarizeLatency returns nil without samples
+func summarizeLatency(samples []LatencySample) *LatencySummary {
+	if len(samples) == 0 {
+		return nil
+	}
+	seconds := make([]float64, len(samples))
+	for i, sample := range samples {
+		seconds[i] = sample.Seconds
+	}
+	slices.Sort(seconds)
+	summary := &LatencySummary{
+		Samples: len(seconds),
+		P50:     percentile(seconds, 50),
+		P90:     percentile(seconds, 90),
+		P99:     percentile(seconds, 99),
+		Target:  config.LatencySLO.Target,
+	}
+	if summary.Target > 0 {
+		within := 0
+		for _, s := range seconds {
+			if s <= float64(summary.Target) {
+				within++
+			}
+		}
+		summary.WithinTarget = float64(within) / float64(len(seconds))
+	}
+	return summary
+}
+
+// percentile uses the nearest rank of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
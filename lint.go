@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// keys for 'lint_ignore'
+const (
+	lintTTL      = "ttl"
+	lintDelete   = "delete"
+	lintSMTPTLS  = "smtp_tls"
+	lintFileMode = "file_mode"
+)
+
+// dynamic records should expire quickly after an IP change
+const maxDynamicTTL = 300
+
+type lintWarning struct {
+	key     string
+	message string
+}
+
+// lintConfig returns opinionated warnings about risky setups, each of them
+// can be suppressed by listing its key in 'lint_ignore'
+func lintConfig() []lintWarning {
+	var warnings []lintWarning
+	add := func(key, format string, args ...any) {
+		if !slices.Contains(config.LintIgnore, key) {
+			warnings = append(warnings, lintWarning{key, fmt.Sprintf(format, args...)})
+		}
+	}
+
+	ttl_warnings, _ := validateTTL(config.TTL)
+	for _, warning := range ttl_warnings {
+		add(lintTTL, "%s", warning)
+	}
+	if config.TTL > maxDynamicTTL {
+		add(lintTTL, "ttl %d is above %d seconds, clients keep stale addresses long after an IP change", config.TTL, maxDynamicTTL)
+	}
+	for name, override := range config.Overrides {
+		if override.TTL > maxDynamicTTL {
+			add(lintTTL, "ttl %d of '%s' is above %d seconds", override.TTL, name, maxDynamicTTL)
+		}
+	}
+
+	// without 'detection' only ipify is asked
+	if allowDelete() && len(config.Detection) <= 1 {
+		add(lintDelete, "records are deleted when an address family is not detected and IP detection "+
+			"has a single source, a short outage of it removes records (set \"allow_delete\": false)")
+	}
+
+	if config.SMTP.Server != "" {
+		switch config.SMTP.Port {
+		case "25":
+			add(lintSMTPTLS, "smtp port 25 is often unencrypted, use the submission port 587 with STARTTLS")
+		case "465":
+			add(lintSMTPTLS, "smtp port 465 (implicit TLS) is not supported, use 587 with STARTTLS")
+		}
+	}
+
+	if configFile != "" && fileHasToken(configFile) {
+		if info, err := os.Stat(configFile); err == nil && info.Mode().Perm()&0o004 != 0 {
+			add(lintFileMode, "%s contains the API token and is world-readable (chmod 600)", configFile)
+		}
+	}
+	return warnings
+}
+
+// fileHasToken ignores tokens passed as credentials or environment
+func fileHasToken(filename string) bool {
+	var file struct {
+		APIToken string `json:"api_token"`
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil || json.Unmarshal(data, &file) != nil {
+		return false
+	}
+	return file.APIToken != ""
+}
+
+func allowDelete() bool {
+	return config.AllowDelete == nil || *config.AllowDelete
+}
+
+// runLint prints all warnings and returns false if there are any
+func runLint() bool {
+	warnings := lintConfig()
+	for _, warning := range warnings {
+		fmt.Printf("warning [%s]: %s\n", warning.key, warning.message)
+	}
+	if len(warnings) == 0 {
+		fmt.Println("no warnings")
+	}
+	return len(warnings) == 0
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// writes refused as protected are retried after this time
+const protectedRetry = 24 * time.Hour
+
+// bounds for the in-memory history shown by the web UI
+const (
+	maxIPHistory  = 100
+	maxLastErrors = 20
+	maxChanges    = 100
+	maxDigest     = 100
+)
+
+type IPChange struct {
+	Time time.Time `json:"time"`
+	IPv4 string    `json:"ipv4"`
+	IPv6 string    `json:"ipv6"`
+}
+
+// QueuedNotification is held back during the quiet hours of a channel
+type QueuedNotification struct {
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+}
+
+type ErrorEntry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	RunID   string    `json:"run_id,omitempty"`
+}
+
+type RecordStatus struct {
+	A          string    `json:"a"`
+	AAAA       string    `json:"aaaa"`
+	LastCheck  time.Time `json:"last_check"`
+	LastChange time.Time `json:"last_change,omitempty"`
+
+	Responders []ResponderCheck `json:"responders,omitempty"`
+	Notes      []Annotation     `json:"notes,omitempty"`
+}
+
+type RunStatus struct {
+	LastRun     time.Time            `json:"last_run"`
+	RunID       string               `json:"run_id,omitempty"`
+	Duration    float64              `json:"duration_seconds"`
+	Errors      int                  `json:"errors"`
+	Changes     int                  `json:"changes"`
+	LastError   string               `json:"last_error,omitempty"`
+	IPv4        string               `json:"ipv4"`
+	IPv6        string               `json:"ipv6"`
+	UpdateMode  bool                 `json:"update_mode"`
+	NextRun     time.Time            `json:"next_run"`
+	FrozenUntil map[string]time.Time `json:"frozen_until"`
+
+	DetectFailedSince time.Time `json:"detect_failed_since,omitempty"`
+	FallbackActive    bool      `json:"fallback_active,omitempty"`
+
+	Queued          []RecordDiff    `json:"queued,omitempty"`
+	PendingApproval string          `json:"pending_approval,omitempty"`
+	Latency         *LatencySummary `json:"latency,omitempty"`
+	FailedRuns      int             `json:"failed_runs,omitempty"`
+	CrossCheck      string          `json:"cross_check_mismatch,omitempty"`
+	Skipped         []SkippedZone   `json:"skipped,omitempty"`
+	OldIPv6Prefix   string          `json:"old_ipv6_prefix,omitempty"`
+}
+
+// daemonState is shared between the reconcile loop and the control API
+type daemonState struct {
+	mu      sync.Mutex
+	run     RunStatus
+	records map[string]*RecordStatus
+	frozen  map[string]time.Time
+	history []IPChange
+	errors  []ErrorEntry
+	changes []RecordDiff
+	latency []LatencySample
+	alerts  map[string][]time.Time
+	digest  map[string][]QueuedNotification
+
+	protected map[string]time.Time
+	ramped    map[string]time.Time
+	reported  map[string]IPChange
+	signing   SigningAnchor
+
+	// read from the state store on every run, see annotate.go
+	notes map[string][]Annotation
+
+	// ID of the current run, see runid.go
+	currentRun string
+}
+
+var live = &daemonState{
+	records: make(map[string]*RecordStatus),
+	frozen:  make(map[string]time.Time),
+
+	protected: make(map[string]time.Time),
+	ramped:    make(map[string]time.Time),
+	reported:  make(map[string]IPChange),
+	alerts:    make(map[string][]time.Time),
+	digest:    make(map[string][]QueuedNotification),
+}
+
+func (s *daemonState) setIPs(ipv4, ipv6 string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.history) == 0 || s.run.IPv4 != ipv4 || s.run.IPv6 != ipv6 {
+		s.history = append(s.history, IPChange{clock.Now(), ipv4, ipv6})
+		if len(s.history) > maxIPHistory {
+			s.history = s.history[1:]
+		}
+	}
+	s.run.IPv4, s.run.IPv6 = ipv4, ipv6
+}
+
+func (s *daemonState) setRecord(fullDomain, valueA, valueAAAA string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[fullDomain]
+	if !ok {
+		rec = &RecordStatus{}
+		s.records[fullDomain] = rec
+	}
+	rec.A, rec.AAAA, rec.LastCheck = valueA, valueAAAA, clock.Now()
+}
+
+func (s *daemonState) recordChanged(fullDomain string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[fullDomain]; ok {
+		rec.LastChange = clock.Now()
+	}
+}
+
+func (s *daemonState) setResponders(fullDomain string, checks []ResponderCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rec, ok := s.records[fullDomain]; ok {
+		rec.Responders = checks
+	}
+}
+
+func (s *daemonState) addChange(diff RecordDiff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, signChange(diff, &s.signing))
+	if len(s.changes) > maxChanges {
+		s.changes = s.changes[1:]
+	}
+}
+
+func (s *daemonState) signingAnchor() SigningAnchor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signing
+}
+
+func (s *daemonState) setError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run.LastError = message
+	s.errors = append(s.errors, ErrorEntry{clock.Now(), message, s.currentRun})
+	if len(s.errors) > maxLastErrors {
+		s.errors = s.errors[1:]
+	}
+}
+
+func (s *daemonState) finishRun(start time.Time, update bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run.LastRun = start
+	s.run.RunID = s.currentRun
+	s.run.Duration = time.Since(start).Seconds()
+	s.run.Errors = runErrors
+	s.run.Changes = runChanges
+	s.run.UpdateMode = update
+}
+
+func (s *daemonState) setRunID(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentRun = id
+}
+
+func (s *daemonState) runID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.currentRun
+}
+
+// detectionFailed returns since when IP detection fails
+func (s *daemonState) detectionFailed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run.DetectFailedSince.IsZero() {
+		s.run.DetectFailedSince = clock.Now()
+	}
+	return s.run.DetectFailedSince
+}
+
+func (s *daemonState) detectionOK() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run.DetectFailedSince = time.Time{}
+}
+
+// setFallback returns true if the fallback was not already in that state
+func (s *daemonState) setFallback(active bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.run.FallbackActive != active
+	s.run.FallbackActive = active
+	return changed
+}
+
+// setQueued returns true if the queued changes differ from the last run
+func (s *daemonState) setQueued(diffs []RecordDiff) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := !sameDiffs(s.run.Queued, diffs)
+	s.run.Queued = diffs
+	return changed
+}
+
+func (s *daemonState) setOldIPv6Prefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run.OldIPv6Prefix = prefix
+}
+
+// setSkipped returns true if other zones are skipped than in the last run
+func (s *daemonState) setSkipped(skipped []SkippedZone) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := !slices.Equal(skippedZones(s.run.Skipped), skippedZones(skipped))
+	s.run.Skipped = slices.Clone(skipped)
+	return changed
+}
+
+// setPendingApproval returns true if the plan waiting for approval changed
+func (s *daemonState) setPendingApproval(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.run.PendingApproval != id
+	s.run.PendingApproval = id
+	return changed
+}
+
+// addLatency returns false if the IP change event was sampled already
+func (s *daemonState) addLatency(sample LatencySample) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.latency); n > 0 && !sample.Detected.After(s.latency[n-1].Detected) {
+		return false
+	}
+	s.latency = append(s.latency, sample)
+	if len(s.latency) > maxLatencySamples {
+		s.latency = s.latency[1:]
+	}
+	return true
+}
+
+// setCrossCheck returns true if the cross-check mismatch changed
+func (s *daemonState) setCrossCheck(mismatch string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.run.CrossCheck != mismatch
+	s.run.CrossCheck = mismatch
+	return changed
+}
+
+// countFailedRun returns the number of consecutive runs with errors
+func (s *daemonState) countFailedRun(failed bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failed {
+		s.run.FailedRuns++
+	} else {
+		s.run.FailedRuns = 0
+	}
+	return s.run.FailedRuns
+}
+
+// allowAlert rate limits the alerts of a channel to one per minInterval
+// and at most perDay within 24 hours, it records the alert if allowed
+func (s *daemonState) allowAlert(channel string, minInterval time.Duration, perDay int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock.Now()
+	sent := slices.DeleteFunc(slices.Clone(s.alerts[channel]), func(t time.Time) bool {
+		return now.Sub(t) > 24*time.Hour
+	})
+	if len(sent) >= perDay || (len(sent) > 0 && now.Sub(sent[len(sent)-1]) < minInterval) {
+		s.alerts[channel] = sent
+		return false
+	}
+	s.alerts[channel] = append(sent, now)
+	return true
+}
+
+// queueDigest keeps a notification for the digest of a channel, the
+// oldest ones are dropped beyond maxDigest
+func (s *daemonState) queueDigest(channel string, queued QueuedNotification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.digest[channel] = append(s.digest[channel], queued)
+	if len(s.digest[channel]) > maxDigest {
+		s.digest[channel] = s.digest[channel][1:]
+	}
+}
+
+// takeDigest returns and removes the queued notifications of a channel
+func (s *daemonState) takeDigest(channel string) []QueuedNotification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queued := s.digest[channel]
+	delete(s.digest, channel)
+	return queued
+}
+
+func (s *daemonState) digestChannels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Keys(s.digest))
+}
+
+func (s *daemonState) setNextRun(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run.NextRun = next
+}
+
+func (s *daemonState) freeze(fullDomain string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until.IsZero() {
+		delete(s.frozen, fullDomain)
+	} else {
+		s.frozen[fullDomain] = until
+	}
+}
+
+// markProtected returns true if key was not known to be protected yet
+func (s *daemonState) markProtected(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, known := s.protected[key]
+	s.protected[key] = clock.Now()
+	return !known
+}
+
+// isProtected reports whether a write to key was refused recently
+func (s *daemonState) isProtected(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since, ok := s.protected[key]
+	return ok && elapsed(since) < protectedRetry
+}
+
+// rampedSince returns when the TTL of key was lowered after a change
+func (s *daemonState) rampedSince(key string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since, ok := s.ramped[key]
+	return since, ok
+}
+
+func (s *daemonState) setRamped(key string, since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if since.IsZero() {
+		delete(s.ramped, key)
+	} else {
+		s.ramped[key] = since
+	}
+}
+
+// setReported keeps the addresses a dyndns2 client reported for a record,
+// a family it didn't report keeps its last address
+func (s *daemonState) setReported(fullDomain, ipv4, ipv6 string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reported := s.reported[fullDomain]
+	reported.Time = clock.Now()
+	if ipv4 != "" {
+		reported.IPv4 = ipv4
+	}
+	if ipv6 != "" {
+		reported.IPv6 = ipv6
+	}
+	s.reported[fullDomain] = reported
+}
+
+func (s *daemonState) reportedIPs(fullDomain string) (IPChange, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reported, ok := s.reported[fullDomain]
+	return reported, ok
+}
+
+func (s *daemonState) isFrozen(fullDomain string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.frozen[fullDomain]
+	if ok && clock.Now().After(until) {
+		delete(s.frozen, fullDomain)
+		return false
+	}
+	return ok
+}
+
+func (s *daemonState) status() RunStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.run
+	status.Latency = summarizeLatency(s.latency)
+	status.FrozenUntil = make(map[string]time.Time)
+	for name, until := range s.frozen {
+		status.FrozenUntil[name] = until
+	}
+	return status
+}
+
+func (s *daemonState) ipHistory() []IPChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]IPChange(nil), s.history...)
+}
+
+func (s *daemonState) lastErrors() []ErrorEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ErrorEntry(nil), s.errors...)
+}
+
+func (s *daemonState) changeList() []RecordDiff {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordDiff(nil), s.changes...)
+}
+
+func (s *daemonState) recordList() map[string]RecordStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make(map[string]RecordStatus)
+	for name, rec := range s.records {
+		copied := *rec
+		copied.Notes = s.notes[name]
+		list[name] = copied
+	}
+	return list
+}
+
+func (s *daemonState) setNotes(notes map[string][]Annotation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notes = notes
+}
+
+func (s *daemonState) recordNotes(fullDomain string) []Annotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notes[fullDomain]
+}
+
+func (s *daemonState) snapshot() savedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := savedState{
+		Run:       s.run,
+		Records:   make(map[string]*RecordStatus),
+		Frozen:    maps.Clone(s.frozen),
+		History:   slices.Clone(s.history),
+		Errors:    slices.Clone(s.errors),
+		Changes:   slices.Clone(s.changes),
+		Latency:   slices.Clone(s.latency),
+		Alerts:    maps.Clone(s.alerts),
+		Digest:    maps.Clone(s.digest),
+		Protected: maps.Clone(s.protected),
+		Ramped:    maps.Clone(s.ramped),
+		Reported:  maps.Clone(s.reported),
+	}
+	if s.signing.Count > 0 {
+		signing := s.signing
+		saved.Signing = &signing
+	}
+	for name, rec := range s.records {
+		copied := *rec
+		saved.Records[name] = &copied
+	}
+	return saved
+}
+
+// restore takes over a saved state, the IPs of the last run are kept so an
+// unchanged address does not add to the history
+func (s *daemonState) restore(saved savedState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run = saved.Run
+	s.history = saved.History
+	s.errors = saved.Errors
+	s.changes = saved.Changes
+	s.latency = saved.Latency
+	for channel, sent := range saved.Alerts {
+		s.alerts[channel] = sent
+	}
+	for channel, queued := range saved.Digest {
+		s.digest[channel] = queued
+	}
+	for name, rec := range saved.Records {
+		s.records[name] = rec
+	}
+	for name, until := range saved.Frozen {
+		s.frozen[name] = until
+	}
+	for key, since := range saved.Protected {
+		s.protected[key] = since
+	}
+	for key, since := range saved.Ramped {
+		s.ramped[key] = since
+	}
+	for name, reported := range saved.Reported {
+		s.reported[name] = reported
+	}
+	if saved.Signing != nil {
+		s.signing = *saved.Signing
+	}
+}
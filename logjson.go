@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// logEntry is a line of the log with 'log_format' "json"
+type logEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Record    string    `json:"record,omitempty"`
+	Zone      string    `json:"zone,omitempty"`
+	Action    string    `json:"action,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	RunID     string    `json:"run_id,omitempty"`
+}
+
+// logEntryPrefix marks lines that logEvent already encoded
+const logEntryPrefix = `{"timestamp":`
+
+func jsonLogging() bool {
+	return config.LogFormat == "json"
+}
+
+// logWriter returns the writer for the log package, with 'log_format'
+// "json" every line is written as a JSON object
+func logWriter(w io.Writer) io.Writer {
+	if !jsonLogging() {
+		return w
+	}
+	log.SetFlags(0)
+	return jsonLogWriter{w}
+}
+
+// logEvent logs a message with the record, zone and action it is about
+func logEvent(entry logEntry) {
+	if !jsonLogging() {
+		log.Println(entry.Message)
+		return
+	}
+	entry.Timestamp = time.Now()
+	entry.RunID = live.runID()
+	if entry.Level == "" {
+		entry.Level = logLevel(entry.Message)
+	}
+	if entry.Zone == "" && entry.Record != "" {
+		_, entry.Zone, _ = strings.Cut(entry.Record, ".")
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Println(entry.Message)
+		return
+	}
+	log.Println(string(line))
+}
+
+// logLevel guesses the level of a plain log line
+func logLevel(message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.HasPrefix(lower, "error") || strings.Contains(lower, " error ") || strings.Contains(lower, "failed"):
+		return "error"
+	case strings.HasPrefix(lower, "warning") || strings.Contains(lower, "not applying"):
+		return "warn"
+	}
+	return "info"
+}
+
+// jsonLogWriter wraps each message of log.Printf into a JSON object,
+// messages of logEvent are passed as they are
+type jsonLogWriter struct {
+	w io.Writer
+}
+
+func (j jsonLogWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+	if !strings.HasPrefix(message, logEntryPrefix) {
+		entry, err := json.Marshal(logEntry{Timestamp: time.Now(), Level: logLevel(message), Message: message, RunID: live.runID()})
+		if err != nil {
+			return 0, err
+		}
+		message = string(entry)
+	}
+	_, err := io.WriteString(j.w, message+"\n")
+	return len(p), err
+}
+
+func validateLogFormat() error {
+	switch config.LogFormat {
+	case "", "text", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown log_format '%s' (text, json)", config.LogFormat)
+}
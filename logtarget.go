@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// nopCloser keeps the log from closing stdout or stderr
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// openLog opens the 'log_target': "file" (default), "syslog", or "stdout"
+// and "stderr" for systemd, where journald captures them and adds the
+// timestamps; OpenWrt always logs to syslog, and "stdout" falls back to
+// stderr when stdout carries the output, e.g. JSON-RPC or CheckMK lines
+func openLog(openwrt, stdoutTaken bool) (io.WriteCloser, error) {
+	target := config.LogTarget
+	if openwrt {
+		target = "syslog"
+	}
+	if target == "stdout" && stdoutTaken {
+		target = "stderr"
+	}
+	switch target {
+	case "syslog":
+		log.SetFlags(0)
+		return openSyslog("hetzner-dns-update")
+	case "stdout":
+		log.SetFlags(0)
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		log.SetFlags(0)
+		return nopCloser{os.Stderr}, nil
+	}
+	return os.OpenFile(logFileName(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// logToFile is false if the log goes to syslog or journald
+func logToFile() bool {
+	return config.LogTarget == "" || config.LogTarget == "file"
+}
+
+func validateLogTarget() error {
+	switch config.LogTarget {
+	case "", "file", "syslog", "stdout", "stderr":
+		return nil
+	}
+	return fmt.Errorf("unknown log_target '%s' (file, syslog, stdout, stderr)", config.LogTarget)
+}
@@ -0,0 +1,19 @@
+//go:build !minimal
+
+package main
+
+import (
+	"net/smtp"
+)
+
+func sendEmail(subject, body string) error {
+	auth := smtp.PlainAuth("", config.SMTP.User, config.SMTP.Password, config.SMTP.Server)
+	header := "From: " + config.SMTP.User + "\r\n" +
+		"To: " + config.SMTP.Recipient + "\r\n" +
+		"Subject: " + subject + "\r\n"
+	if id := live.runID(); id != "" {
+		header += "X-Run-ID: " + id + "\r\n"
+	}
+	msg := []byte(header + "\r\n" + body + "\r\n" + desiredStateFooter())
+	return smtp.SendMail(config.SMTP.Server+":"+config.SMTP.Port, auth, config.SMTP.User, []string{config.SMTP.Recipient}, msg)
+}
@@ -1,408 +1,704 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
-	"net/smtp"
+	"net"
+	"net/netip"
 	"os"
-	"path/filepath"
+	"slices"
 	"strings"
-)
-
-type Config struct {
-	APIToken string     `json:"api_token"`
-	Records  []string   `json:"records"`
-	TTL      int        `json:"ttl"`
-	SMTP     SMTPConfig `json:"smtp"`
-	Logfile  string     `json:"logfile"`
-}
-
-type SMTPConfig struct {
-	Server    string `json:"server"`
-	Port      string `json:"port"`
-	User      string `json:"user"`
-	Password  string `json:"password"`
-	Recipient string `json:"recipient"`
-}
-
-type Zone struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
+	"time"
 
-type Record struct {
-	ID    string `json:"id"`
-	Type  string `json:"type"`
-	Name  string `json:"name"`
-	Value string `json:"value"`
-}
+	"github.com/railduino/hetzner-dns-update/pkg/hetznerdns"
+)
 
-type ZonesResponse struct {
-	Zones []Zone `json:"zones"`
-}
+type Zone = hetznerdns.Zone
+type Record = hetznerdns.Record
 
-type RecordsResponse struct {
-	Records []Record `json:"records"`
-}
+const hetznerAPI = hetznerdns.DefaultBaseURL
 
-const hetznerAPI = "https://dns.hetzner.com/api/v1"
+var errReadOnly = errors.New("config is read-only (observer mode)")
 
 var config Config
+var configFile string
+var runErrors int
+var runChanges int
+
+// runPlanned keeps the changes planned by the last run for 'plan'
+var runPlanned []Change
 
 func main() {
+	defer handleCrash()
+
 	updateMode := flag.Bool("update", false, "update A/AAAA records")
 	verboseMode := flag.Bool("verbose", false, "show progress")
+	openwrtMode := flag.Bool("openwrt", false, "read UCI config and log to syslog only")
+	procdInit := flag.Bool("procd-init", false, "print a procd init script and exit")
+	checkMKMode := flag.Bool("checkmk", false, "print a CheckMK local check line")
+	daemonMode := flag.Bool("daemon", false, "keep running and reconcile every 'interval' seconds")
+	jsonRPCMode := flag.Bool("json-rpc", false, "read plan/apply/status requests as JSON lines on stdin")
+	splay := flag.Duration("splay", 0, "sleep a random time up to this duration before starting, e.g. 120s")
+	singleShotSafe := flag.Bool("single-shot-safe", false, "for cron: skip if a run is active, abort after -deadline, print only on failure, exit 1 on any error")
+	deadline := flag.Duration("deadline", defaultDeadline, "hard deadline of a -single-shot-safe run")
+	yesReally := flag.Bool("yes-really", false, "apply the changes even if there are more than 'max_changes_per_run'")
+	dryRun := flag.Bool("dry-run", false, "same as 'plan': show all pending changes, exit code 2 if there are any")
+	recordTo := flag.String("record-snapshot", "", "record the API responses of this run to a file for 'plan --snapshot'")
+	var only, skip nameList
+	flag.Var(&only, "only", "process only this record, may be a glob and repeated")
+	flag.Var(&skip, "skip", "do not process this record, may be a glob and repeated")
+	registerChaosFlags()
+	flag.Usage = printUsage
 	flag.Parse()
 
-	err := loadConfig("config.json")
+	// 'update' and 'check' take the flags after their name as well
+	name := flag.Arg(0)
+	if _, ok := runCommands[name]; ok {
+		flag.CommandLine.Parse(flag.Args()[1:])
+		if flag.NArg() > 0 {
+			fmt.Printf("error: unexpected argument '%s' for %s\n", flag.Arg(0), name)
+			os.Exit(1)
+		}
+		if name == "update" {
+			*updateMode = true
+		} else {
+			*updateMode, *verboseMode = false, true
+		}
+	}
+	cmd, isCommand := commands[name]
+	if name != "" && !isCommand && runCommands[name] == "" {
+		fmt.Printf("error: unknown command '%s'\n\n", name)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := validateChaos(); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	if *procdInit {
+		printProcdInit()
+		return
+	}
+
+	if isCommand && cmd.early {
+		if code := cmd.run(flag.Args()[1:], runOptions{}); code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	var err error
+	if *openwrtMode {
+		err = loadUCIConfig(uciConfigFile)
+	} else {
+		err = loadConfig("config.json")
+	}
+	if err == nil {
+		err = loadCredentials()
+	}
 	if err != nil {
 		fmt.Println("error loading config file:", err)
 		os.Exit(1)
 	}
 
-	log_name := "hetzner-dns-update.log"
-	if os.Geteuid() == 0 {
-		log_name = config.Logfile
+	err = validateConfig()
+	if err != nil {
+		fmt.Println("error in config file:", err)
+		os.Exit(1)
+	}
+
+	if *recordTo != "" {
+		recordSnapshot(*recordTo)
+	}
+
+	// TTL warnings are always shown, a TTL the provider doesn't honor is
+	// easily missed otherwise
+	for _, warning := range lintConfig() {
+		if *verboseMode || warning.key == lintTTL {
+			fmt.Fprintln(os.Stderr, "warning:", warning.message)
+		}
 	}
-	log_file, err := os.OpenFile(log_name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if config.ReadOnly && *updateMode {
+		fmt.Println("error:", errReadOnly, "- '-update' is not allowed")
+		os.Exit(1)
+	}
+
+	if *openwrtMode && config.MemoryLimit == 0 {
+		config.MemoryLimit = openwrtMemoryLimit
+	}
+	applyMemoryLimit(config.MemoryLimit)
+
+	for _, plug := range checkSnapInterfaces() {
+		fmt.Fprintln(os.Stderr, "warning:", snapInterfaceHint(plug))
+	}
+
+	log_file, err := openLog(*openwrtMode, *jsonRPCMode || *checkMKMode)
 	if err != nil {
 		fmt.Println("error opening log file:", err)
 		os.Exit(1)
 	}
 	defer log_file.Close()
-	log.SetOutput(log_file)
+	log.SetOutput(logWriter(scrubWriter{log_file}))
+	loadState()
+	cancelOnSignal()
+
+	opts := runOptions{
+		update:  *updateMode,
+		verbose: *verboseMode,
+		checkmk: *checkMKMode,
+		only:    only,
+		skip:    skip,
+
+		yesReally: *yesReally,
+	}
 
-	ipv4, ipv6, err := getPublicIPs()
-	if err != nil {
-		logAndMail("error getting current public IP: " + err.Error())
+	if isCommand || *dryRun {
+		args := flag.Args()
+		if isCommand {
+			args = args[1:]
+		} else {
+			cmd = commands["plan"]
+		}
+		if code := cmd.run(args, opts); code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	if *jsonRPCMode {
+		serveJSONRPC()
+		return
+	}
+
+	var single *singleShot
+	if *singleShotSafe {
+		if *daemonMode {
+			fmt.Println("error: -single-shot-safe can't be combined with -daemon")
+			os.Exit(1)
+		}
+		if *splay >= *deadline {
+			fmt.Println("error: -splay must be shorter than -deadline")
+			os.Exit(1)
+		}
+		single, err = startSingleShot(*deadline, logWriter(scrubWriter{log_file}))
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		if single == nil {
+			return
+		}
+	}
+
+	// spread instances started by cron at the same minute
+	if *splay > 0 {
+		delay := random.Duration(*splay)
+		log.Printf("splay: sleeping %s\n", delay.Round(time.Second))
+		clock.Sleep(delay)
+	}
+
+	if *daemonMode {
+		runDaemon(opts)
+		return
+	}
+
+	ok := runOnce(opts)
+	saveSnapshot()
+	if interrupted() {
+		log.Println("interrupted, stopping")
+		ok = false
+	}
+	if single != nil {
+		single.finish(ok && runErrors == 0)
+	}
+	if !ok {
 		os.Exit(1)
 	}
-	log.Printf("Current public IP: '%s' / '%s'\n", ipv4, ipv6)
+	if len(runSkipped) > 0 {
+		os.Exit(exitPartial)
+	}
+}
+
+type runOptions struct {
+	update  bool
+	verbose bool
+	checkmk bool
+	only    []string
+	skip    []string
 
-	for _, fullDomain := range config.Records {
-		if *verboseMode {
-			fmt.Println("processing record:", fullDomain)
+	yesReally bool
+}
+
+// selected reports whether a record is part of this run
+func (o runOptions) selected(fullDomain string) bool {
+	if matchesAny(fullDomain, o.skip) {
+		return false
+	}
+	return len(o.only) == 0 || matchesAny(fullDomain, o.only)
+}
+
+// planRun plans the changes of the selected records and of the TXT
+// records for runOnce and the JSON-RPC 'plan', readable is false if no zone
+// could be read
+func planRun(ipv4, ipv6 string, opts runOptions) (records []ManagedRecord, changes []Change, readable bool) {
+	records = selectRecords(managedRecords(ipv4, ipv6), opts)
+	changes = planChanges(records, ipv4, ipv6, opts.verbose)
+	changes = append(changes, planTXTRecords(opts)...)
+	return records, changes, reportSkipped(records, opts.verbose)
+}
+
+// runOnce detects the public IPs and reconciles all managed records, it
+// returns false if the public IPs could not be detected or no zone could
+// be read
+func runOnce(opts runOptions) bool {
+	runErrors = 0
+	runChanges = 0
+	runPlanned = nil
+
+	setRunID(newRunID())
+	defer setRunID("")
+	start := time.Now()
+	opts.skip = slices.Concat(opts.skip, disabledRecords())
+	live.setNotes(recordNotes())
+	flushDigests()
+	selectProfile()
+	if err := loadDesiredState(); err != nil {
+		logAndMail("error loading desired state: " + err.Error())
+	}
+	ipv4, ipv6, err := detectIPs()
+	fallback := err != nil
+	if err == nil {
+		detectionRecovered(ipv4, ipv6)
+		crossCheckIPs(ipv4, ipv6)
+	} else {
+		logAndMail("error getting current public IP: " + err.Error())
+		var ok bool
+		ipv4, ipv6, ok = fallbackIPs()
+		if !ok {
+			reportRun(start, 0, opts.checkmk)
+			live.finishRun(start, opts.update)
+			saveState()
+			return false
 		}
-		parts := strings.SplitN(fullDomain, ".", 2)
-		if len(parts) != 2 {
-			logAndMail("invalid domain name: " + fullDomain)
-			continue
+	}
+	logEvent(logEntry{Message: fmt.Sprintf("Current public IP: '%s' / '%s'", ipv4, ipv6), Action: "detect"})
+	if fallback {
+		// the fallback is no detected address: no prefix change and no
+		// entry in the IP history
+		trackIPv6Prefix(live.status().IPv6, "")
+	} else {
+		trackIPv6Prefix(live.status().IPv6, ipv6)
+		live.setIPs(knownIPs(ipv4, ipv6))
+	}
+
+	if config.Agent.Controller != "" {
+		if opts.update {
+			if err := reportToController(ipv4, ipv6); err != nil {
+				logAndMail("error reporting to controller: " + err.Error())
+			}
+		} else if opts.verbose {
+			fmt.Printf("agent: would report %s to %s\n", strings.Join(agentRecords(), ", "), config.Agent.Controller)
 		}
-		namePart := parts[0]
-		zonePart := parts[1]
+		reportRun(start, len(agentRecords()), opts.checkmk)
+		live.finishRun(start, opts.update)
+		saveState()
+		return true
+	}
 
-		zoneID, err := findZoneID(zonePart)
+	if opts.update && config.Coordination.Lock != "" {
+		primary, owner, err := acquireLock(clock.Now())
 		if err != nil {
-			logAndMail("error fetching zone ID: " + err.Error())
-			continue
+			logAndMail("error acquiring coordination lock: " + err.Error())
+			opts.update = false
+		} else if !primary {
+			log.Printf("standby: instance '%s' holds the lock, not applying changes\n", owner)
+			opts.update = false
 		}
+	}
 
-		recordA, recordAAAA, err := findRecords(zoneID, namePart)
-		if err != nil {
-			logAndMail("error fetching A/AAAA records: " + err.Error())
-			continue
+	takeover := false
+	if opts.update && isStandby() {
+		takeover = failoverTakeover(clock.Now())
+		opts.update = takeover
+	}
+
+	records, changes, readable := planRun(ipv4, ipv6, opts)
+	settleIPv6Prefix(opts)
+	runPlanned = changes
+	if opts.update && !inChangeWindow(clock.Now()) {
+		queueChanges(changes)
+		opts.update = false
+	} else if opts.update {
+		live.setQueued(nil)
+	}
+	approvedPlan := ""
+	if opts.update {
+		changes, approvedPlan = holdForApproval(changes)
+	}
+	if opts.update && exceedsBlastRadius(changes, opts) {
+		opts.update = false
+	}
+	if opts.update {
+		if err := backupZones(changes); err != nil {
+			logAndMail("not applying changes, zone backup failed: " + err.Error())
+			opts.update = false
 		}
+	}
 
-		//
-		// Handle IPv4
-		//
-		if ipv4 != "" {
-			if recordA.Value != "" {
-				// Case: cur+ / rec+
-				if recordA.Value == ipv4 {
-					if *verboseMode {
-						fmt.Println("- A record is current for:", fullDomain)
-					}
-				} else {
-					if *verboseMode {
-						fmt.Println("- A record needs update for:", fullDomain)
-					}
-					if *updateMode {
-						err = updateRecord(zoneID, recordA.ID, "A", namePart, ipv4)
-						if err != nil {
-							logAndMail("error updating A record: " + err.Error())
-						} else {
-							log.Println("A record was updated: " + fullDomain)
-						}
-					}
-				}
-			} else {
-				// Case: cur+ / rec-
-				if *verboseMode {
-					fmt.Println("- A record needs create for:", fullDomain)
-				}
-				if *updateMode {
-					err = createRecord(zoneID, "A", namePart, ipv4)
-					if err != nil {
-						logAndMail("error creating A record: " + err.Error())
-					} else {
-						log.Println("A record was created: " + fullDomain)
-					}
-				}
-			}
-		} else {
-			if recordA.Value != "" {
-				// Case: cur- / rec+
-				if *verboseMode {
-					fmt.Println("- A record needs delete for:", fullDomain)
-				}
-				if *updateMode {
-					err = deleteRecord(recordA.ID)
-					if err != nil {
-						logAndMail("error deleting A record: " + err.Error())
-					} else {
-						log.Println("A record was deleted: " + fullDomain)
-					}
-				}
-			} else {
-				// Case: cur- / rec-
-				if *verboseMode {
-					fmt.Println("- no need for A record for:", fullDomain)
-				}
-			}
+	if opts.update {
+		report_soa := trackSOA(changes)
+		failed := runErrors
+		applyChanges(changes)
+		if approvedPlan != "" && runErrors == failed {
+			useApproval(approvedPlan)
 		}
+		report_soa()
+		verifyApplied()
+		checkResponders()
+	}
 
-		//
-		// Handle IPv6
-		//
-		if ipv6 != "" {
-			if recordAAAA.Value != "" {
-				// Case: cur+ / rec+
-				if recordAAAA.Value == ipv6 {
-					if *verboseMode {
-						fmt.Println("- AAAA record is current for:", fullDomain)
-					}
-				} else {
-					if *verboseMode {
-						fmt.Println("- AAAA record needs update for:", fullDomain)
-					}
-					if *updateMode {
-						err = updateRecord(zoneID, recordAAAA.ID, "AAAA", namePart, ipv6)
-						if err != nil {
-							logAndMail("error updating AAAA record: " + err.Error())
-						} else {
-							log.Println("AAAA record was updated: " + fullDomain)
-						}
-					}
-				}
-			} else {
-				// Case: cur+ / rec-
-				if *verboseMode {
-					fmt.Println("- AAAA record needs create for:", fullDomain)
-				}
-				if *updateMode {
-					err = createRecord(zoneID, "AAAA", namePart, ipv6)
-					if err != nil {
-						logAndMail("error creating AAAA record: " + err.Error())
-					} else {
-						log.Println("AAAA record was created: " + fullDomain)
-					}
-				}
-			}
-		} else {
-			if recordAAAA.Value != "" {
-				// Case: cur- / rec+
-				if *verboseMode {
-					fmt.Println("- AAAA record needs delete for:", fullDomain)
-				}
-				if *updateMode {
-					err = deleteRecord(recordAAAA.ID)
-					if err != nil {
-						logAndMail("error deleting AAAA record: " + err.Error())
-					} else {
-						log.Println("AAAA record was deleted: " + fullDomain)
-					}
-				}
-			} else {
-				// Case: cur- / rec-
-				if *verboseMode {
-					fmt.Println("- no need for AAAA record for:", fullDomain)
-				}
-			}
+	reconcileSplitHorizon(opts)
+
+	if takeover {
+		notifyTakeover()
+	}
+
+	// a standby must not keep the primary's heartbeat alive
+	if opts.update && config.Heartbeat != "" && runErrors == 0 && !(isStandby() && config.Heartbeat == failoverHeartbeat()) {
+		err := updateHeartbeat(clock.Now())
+		if err != nil {
+			logAndMail("error updating heartbeat: " + err.Error())
 		}
 	}
+
+	reportRun(start, len(records), opts.checkmk)
+	live.finishRun(start, opts.update)
+	saveState()
+	return readable
 }
 
-func loadConfig(filename string) error {
-	config_dir, _ := os.Getwd()
-	if snap_dir := os.Getenv("SNAP_USER_COMMON"); snap_dir != "" {
-		config_dir = snap_dir
-	} else if env_dir := os.Getenv("CONFIG_DIR"); env_dir != "" {
-		config_dir = env_dir
+// exceedsBlastRadius guards against a config mistake or a bad IP detection
+// rewriting a whole account, the run is aborted unless '-yes-really' is given
+func exceedsBlastRadius(changes []Change, opts runOptions) bool {
+	limit := config.MaxChangesPerRun
+	if limit <= 0 || len(changes) <= limit {
+		return false
+	}
+	if opts.yesReally {
+		log.Printf("applying %d changes, more than max_changes_per_run (%d), confirmed with -yes-really\n", len(changes), limit)
+		return false
 	}
+	logAndMail(fmt.Sprintf("not applying %d changes, more than max_changes_per_run (%d); check the plan and run with -yes-really to apply them",
+		len(changes), limit))
+	return true
+}
 
-	config_file := filepath.Join(config_dir, filename)
-	data, err := os.ReadFile(config_file)
-	if err != nil {
-		return err
+// detectIPs uses the detection method of the current profile
+func detectIPs() (string, string, error) {
+	if lowImpact {
+		return getPublicIPsDNS()
 	}
+	return getPublicIPs()
+}
 
-	return json.Unmarshal(data, &config)
+// detectionServices are the known echo services with their IPv4 and IPv6
+// URLs, each host only has addresses of its family
+var detectionServices = map[string][2]string{
+	"ipify":       {"https://api.ipify.org", "https://api6.ipify.org"},
+	"icanhazip":   {"https://ipv4.icanhazip.com", "https://ipv6.icanhazip.com"},
+	"ifconfig.co": {"https://ipv4.ifconfig.co/ip", "https://ipv6.ifconfig.co/ip"},
 }
 
-func getPublicIPs() (string, string, error) {
-	resp4, err := http.Get("https://api.ipify.org")
-	if err != nil {
-		return "", "", err
+// detectionURLs returns the URLs of 'detection' per family in their
+// order, a custom URL is asked for both
+func detectionURLs() ([]string, []string) {
+	providers := config.Detection
+	if len(providers) == 0 {
+		providers = []string{"ipify"}
 	}
-	defer resp4.Body.Close()
-	ip4, err := io.ReadAll(resp4.Body)
-	if err != nil {
-		return "", "", err
+	var urls4, urls6 []string
+	for _, provider := range providers {
+		urls, ok := detectionServices[provider]
+		if !ok {
+			urls = [2]string{provider, provider}
+		}
+		urls4, urls6 = append(urls4, urls[0]), append(urls6, urls[1])
 	}
+	return urls4, urls6
+}
 
-	resp6, err := http.Get("https://api6.ipify.org")
-	if err != nil {
-		return string(ip4), "", nil
-	}
-	defer resp6.Body.Close()
-	ip6, err := io.ReadAll(resp6.Body)
+func getPublicIPs() (string, string, error) {
+	urls4, urls6 := detectionURLs()
+	ip4, err := firstAddress(urls4, "IPv4", netip.Addr.Is4)
 	if err != nil {
 		return "", "", err
 	}
 
-	return string(ip4), string(ip6), nil
+	// without IPv6 connectivity the services can't be reached or return
+	// the IPv4 address; any other failure says nothing about the AAAA
+	// records, they are kept
+	ip6, err := firstAddress(urls6, "IPv6", func(addr netip.Addr) bool { return addr.Is6() && !addr.Is4In6() })
+	switch {
+	case err == nil:
+		return ip4.String(), ip6.String(), nil
+	case noConnectivity(err):
+		return ip4.String(), "", nil
+	}
+	log.Println("error getting the public IPv6 address, keeping the AAAA records:", err)
+	return ip4.String(), keepAddress, nil
 }
 
-func findZoneID(domain string) (string, error) {
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", hetznerAPI+"/zones", nil)
-	req.Header.Add("Auth-API-Token", config.APIToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+// detectionErrors are the failures of all services asked for a family
+type detectionErrors []error
+
+func (e detectionErrors) Error() string {
+	var failed []string
+	for _, err := range e {
+		failed = append(failed, err.Error())
 	}
-	defer resp.Body.Close()
+	return strings.Join(failed, "; ")
+}
 
-	var zones ZonesResponse
-	decoder := json.NewDecoder(resp.Body)
-	decoder.Decode(&zones)
+func (e detectionErrors) Unwrap() []error {
+	return e
+}
 
-	for _, zone := range zones.Zones {
-		if zone.Name == domain {
-			return zone.ID, nil
-		}
-	}
-	return "", fmt.Errorf("can't find domain '%s'", domain)
+// familyError is an answer with an address of the other family
+type familyError struct {
+	url, family string
+	addr        netip.Addr
 }
 
-func findRecords(zoneID, fullDomain string) (Record, Record, error) {
-	recordA := Record{}
-	recordAAAA := Record{}
+func (e familyError) Error() string {
+	return fmt.Sprintf("%s returned '%s', not an %s address", e.url, e.addr, e.family)
+}
 
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/records?zone_id=%s", hetznerAPI, zoneID), nil)
-	req.Header.Add("Auth-API-Token", config.APIToken)
-	resp, err := client.Do(req)
-	if err != nil {
-		return recordA, recordAAAA, err
+// noConnectivity reports whether no service could be reached over the
+// family at all: each one failed to connect or saw the other family
+func noConnectivity(err error) bool {
+	failed, ok := err.(detectionErrors)
+	if !ok || len(failed) == 0 {
+		return false
 	}
-	defer resp.Body.Close()
-
-	var records RecordsResponse
-	decoder := json.NewDecoder(resp.Body)
-	decoder.Decode(&records)
+	for _, err := range failed {
+		var family familyError
+		var op *net.OpError
+		var dns *net.DNSError
+		if !errors.As(err, &family) && !(errors.As(err, &op) && op.Op == "dial") && !errors.As(err, &dns) {
+			return false
+		}
+	}
+	return true
+}
 
-	for _, rec := range records.Records {
-		if rec.Name == fullDomain && rec.Type == "A" {
-			recordA = rec
-			continue
+// firstAddress asks the services in order until one returns an address of
+// the family, falling back to the next one is logged
+func firstAddress(urls []string, family string, valid func(netip.Addr) bool) (netip.Addr, error) {
+	var failed detectionErrors
+	for _, url := range urls {
+		addr, err := echoAddress(url)
+		if err == nil && !valid(addr) {
+			err = familyError{url, family, addr}
 		}
-		if rec.Name == fullDomain && rec.Type == "AAAA" {
-			recordAAAA = rec
+		if err != nil {
+			failed = append(failed, err)
 			continue
 		}
+		if len(failed) > 0 {
+			log.Printf("%s detection fell back to %s: %s\n", family, url, failed)
+		}
+		return addr, nil
 	}
+	return netip.Addr{}, failed
+}
 
-	if recordA.Type == "" && recordAAAA.Type == "" {
-		return recordA, recordAAAA, fmt.Errorf("can't find A record for '%s'", fullDomain)
+// knownIPs are the addresses for the state and the history, a family
+// whose records are kept keeps the address of the last run
+func knownIPs(ipv4, ipv6 string) (string, string) {
+	status := live.status()
+	if ipv4 == keepAddress {
+		ipv4 = status.IPv4
 	}
-	return recordA, recordAAAA, nil
+	if ipv6 == keepAddress {
+		ipv6 = status.IPv6
+	}
+	return ipv4, ipv6
 }
 
-func createRecord(zoneID, recType, name, newIP string) error {
-	client := &http.Client{}
-	payload := map[string]interface{}{
-		"zone_id": zoneID,
-		"type":    recType,
-		"name":    name,
-		"value":   newIP,
-		"ttl":     config.TTL,
-	}
-	body, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", fmt.Sprintf("%s/records", hetznerAPI), bytes.NewBuffer(body))
-	req.Header.Add("Auth-API-Token", config.APIToken)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
+// echoAddress expects just an IP address in the response, anything else
+// is rejected rather than published
+func echoAddress(url string) (netip.Addr, error) {
+	resp, err := getURL(detectionClient(), url)
 	if err != nil {
-		return err
+		return netip.Addr{}, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("create status: %s", resp.Status)
+		return netip.Addr{}, fmt.Errorf("%s: status %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(string(body)))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%s: unexpected response '%.40s'", url, body)
 	}
-	return nil
+	return addr, nil
 }
 
-func updateRecord(zoneID, recordID, recType, name, newIP string) error {
-	client := &http.Client{}
-	payload := map[string]interface{}{
-		"zone_id": zoneID,
-		"type":    recType,
-		"name":    name,
-		"value":   newIP,
-		"ttl":     config.TTL,
-	}
-	body, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("PUT", fmt.Sprintf("%s/records/%s", hetznerAPI, recordID), bytes.NewBuffer(body))
-	req.Header.Add("Auth-API-Token", config.APIToken)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
+// dnsProvider manages the zones, implemented by the Hetzner DNS API
+// client, nsupdateProvider and the providers of single zones
+type dnsProvider interface {
+	Zones(fn func(Zone)) error
+	FindZone(name string) (Zone, error)
+	FindZoneID(name string) (string, error)
+	Records(zoneID string, fn func(Record)) error
+	FindRecords(zoneID, name string) ([]Record, []Record, error)
+	CreateRecord(zoneID, recType, name, value string, ttl int) error
+	UpdateRecord(zoneID, recordID, recType, name, value string, ttl int) error
+	DeleteRecord(recordID string) error
+}
+
+// dnsClient returns the configured provider, the Hetzner client uses the
+// transports of apiClient, with proxy, cache, fault injection and snapshots
+func dnsClient() dnsProvider {
+	if config.Provider == "rfc2136" {
+		return nsupdateProvider{}
+	}
+	return hetznerdns.NewClient(config.APIToken, apiClient()).WithContext(runContext)
+}
+
+func findZoneID(domain string) (zoneID string, err error) {
+	err = withRetry("finding zone "+domain, func() error {
+		zoneID, err = providerFor(domain).FindZoneID(domain)
+		return err
+	})
+	return zoneID, err
+}
+
+func findZone(domain string) (zone Zone, err error) {
+	err = withRetry("finding zone "+domain, func() error {
+		zone, err = providerFor(domain).FindZone(domain)
+		return err
+	})
+	return zone, err
+}
+
+// findRecords returns all A and AAAA records of a name, a zone may
+// contain duplicates; a name without records is not an error, its records
+// are planned as creates
+func findRecords(zoneID, name string) (recordsA, recordsAAAA []Record, err error) {
+	err = withRetry("finding records of "+name, func() error {
+		recordsA, recordsAAAA, err = providerFor(zoneID).FindRecords(zoneID, name)
+		return err
+	})
+	return recordsA, recordsAAAA, err
+}
+
+func eachRecord(zoneID string, fn func(Record)) error {
+	called := false
+	var err error
+	retry_err := withRetry("listing records", func() error {
+		err = providerFor(zoneID).Records(zoneID, func(rec Record) {
+			called = true
+			fn(rec)
+		})
+		if called {
+			// a retry would pass the same records to fn again
+			return nil
+		}
+		return err
+	})
+	if called {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("update status: %s", resp.Status)
+	return retry_err
+}
+
+func createRecord(zoneID, recType, name, newIP string, ttl Seconds) error {
+	if config.ReadOnly {
+		return errReadOnly
 	}
-	return nil
+	record := Record{ZoneID: zoneID, Type: recType, Name: name, Value: newIP}
+	retried := false
+	return withRetry("creating "+recType+" record "+name, func() error {
+		if retried {
+			missing, err := missingRecords([]Record{record})
+			if err != nil || len(missing) == 0 {
+				return err
+			}
+		}
+		retried = true
+		return providerFor(zoneID).CreateRecord(zoneID, recType, name, newIP, int(ttl))
+	})
 }
 
-func deleteRecord(recordID string) error {
-	client := &http.Client{}
-	req, _ := http.NewRequest("DELETE", fmt.Sprintf("%s/records/%s", hetznerAPI, recordID), nil)
-	req.Header.Add("Auth-API-Token", config.APIToken)
-	req.Header.Add("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+// missingRecords returns the records that are not in their zone yet; a
+// create that failed with a timeout or a server error may have been
+// applied anyway and must not be repeated
+func missingRecords(records []Record) ([]Record, error) {
+	var missing []Record
+	existing := make(map[string][]Record)
+	for _, rec := range records {
+		zone_records, ok := existing[rec.ZoneID]
+		if !ok {
+			err := providerFor(rec.ZoneID).Records(rec.ZoneID, func(found Record) {
+				zone_records = append(zone_records, found)
+			})
+			if err != nil {
+				return nil, err
+			}
+			existing[rec.ZoneID] = zone_records
+		}
+		if !slices.ContainsFunc(zone_records, func(found Record) bool {
+			return found.Name == rec.Name && found.Type == rec.Type && found.Value == rec.Value
+		}) {
+			missing = append(missing, rec)
+		}
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("delete status: %s", resp.Status)
+	return missing, nil
+}
+
+func updateRecord(zoneID, recordID, recType, name, newIP string, ttl Seconds) error {
+	if config.ReadOnly {
+		return errReadOnly
 	}
-	return nil
+	return withRetry("updating "+recType+" record "+name, func() error {
+		return providerFor(zoneID).UpdateRecord(zoneID, recordID, recType, name, newIP, int(ttl))
+	})
+}
+
+func deleteRecord(recordID string) error {
+	if config.ReadOnly {
+		return errReadOnly
+	}
+	return withRetry("deleting record "+recordID, func() error {
+		return providerFor(recordZone(recordID)).DeleteRecord(recordID)
+	})
+}
+
+func logChange(fullDomain, action, message string) {
+	runChanges++
+	live.recordChanged(fullDomain)
+	logEvent(logEntry{Level: "info", Message: message, Record: fullDomain, Action: action})
 }
 
 func logAndMail(message string) {
-	log.Println(message)
-	sendEmail("DNS Update Status", message)
+	runErrors++
+	reportError(message)
 }
 
-func sendEmail(subject, body string) {
-	auth := smtp.PlainAuth("", config.SMTP.User, config.SMTP.Password, config.SMTP.Server)
-	msg := []byte("From: " + config.SMTP.User + "\r\n" +
-		"To: " + config.SMTP.Recipient + "\r\n" +
-		"Subject: " + subject + "\r\n" +
-		"\r\n" +
-		body + "\r\n")
-	err := smtp.SendMail(config.SMTP.Server+":"+config.SMTP.Port, auth, config.SMTP.User, []string{config.SMTP.Recipient}, msg)
-	if err != nil {
-		log.Println("eror sending email:", err)
-	}
+// reportError is logAndMail outside of a run, e.g. in the background,
+// without counting an error of the current run
+func reportError(message string) {
+	live.setError(message)
+	logEvent(logEntry{Level: "error", Message: message, Error: message})
+	sendSIEMError(message)
+	sendNotification("DNS Update Status", message)
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetPublicIPsKeepsAAAAOnServiceErrors(t *testing.T) {
+	ipv4Only := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("192.0.2.1\n"))
+	}))
+	defer ipv4Only.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	// both services answer over IPv4 only: there is no IPv6
+	useConfig(t, Config{Detection: []string{ipv4Only.URL}})
+	if ipv4, ipv6, err := getPublicIPs(); err != nil || ipv4 != "192.0.2.1" || ipv6 != "" {
+		t.Errorf("got '%s' / '%s' / %v, want no IPv6", ipv4, ipv6, err)
+	}
+
+	// a server error says nothing about IPv6
+	useConfig(t, Config{Detection: []string{ipv4Only.URL, failing.URL}})
+	if ipv4, ipv6, err := getPublicIPs(); err != nil || ipv4 != "192.0.2.1" || ipv6 != keepAddress {
+		t.Errorf("got '%s' / '%s' / %v, want the AAAA records kept", ipv4, ipv6, err)
+	}
+}
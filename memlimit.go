@@ -0,0 +1,17 @@
+package main
+
+import (
+	"runtime/debug"
+)
+
+// defaults for constrained devices, see 'memory_limit_mb'
+const (
+	openwrtMemoryLimit = 16
+	maxResponseSize    = 64 << 20
+)
+
+func applyMemoryLimit(limit_mb int) {
+	if limit_mb > 0 {
+		debug.SetMemoryLimit(int64(limit_mb) << 20)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	metadataHost = "http://169.254.169.254"
+	hcloudAPI    = "https://api.hetzner.cloud/v1"
+)
+
+// metadataClient talks to the link-local metadata service, never via a proxy
+var metadataClient = &http.Client{
+	Transport: &http.Transport{Proxy: nil},
+	Timeout:   5 * time.Second,
+}
+
+// metadataIPs reads the public addresses of this cloud instance for the
+// sources "aws", "gcp" and "hcloud"
+func metadataIPs(cloud string) (string, string, error) {
+	switch cloud {
+	case "aws":
+		return awsMetadataIPs()
+	case "gcp":
+		ipv4, err := metadataGet("/computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip",
+			map[string]string{"Metadata-Flavor": "Google"})
+		if err != nil {
+			return "", "", err
+		}
+		ipv6, _ := metadataGet("/computeMetadata/v1/instance/network-interfaces/0/ipv6s",
+			map[string]string{"Metadata-Flavor": "Google"})
+		return ipv4, firstLine(ipv6), nil
+	case "hcloud":
+		return hcloudIPs()
+	}
+	return "", "", fmt.Errorf("unknown cloud '%s'", cloud)
+}
+
+// awsMetadataIPs uses IMDSv2, which needs a session token first
+func awsMetadataIPs() (string, string, error) {
+	req, _ := http.NewRequestWithContext(runContext, "PUT", metadataHost+"/latest/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if err != nil {
+		return "", "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", "", fmt.Errorf("aws metadata token status: %s", resp.Status)
+	}
+
+	header := map[string]string{"X-aws-ec2-metadata-token": string(data)}
+	ipv4, err := metadataGet("/latest/meta-data/public-ipv4", header)
+	if err != nil {
+		return "", "", err
+	}
+	ipv6, _ := metadataGet("/latest/meta-data/ipv6", header)
+	return ipv4, firstLine(ipv6), nil
+}
+
+// hcloudIPs reads the IPv4 address from the metadata service and, with an
+// 'hcloud_token', the IPv6 network of the server from the Cloud API; the
+// server's address is the conventional ::1 of that network
+func hcloudIPs() (string, string, error) {
+	ipv4, err := metadataGet("/hetzner/v1/metadata/public-ipv4", nil)
+	if err != nil {
+		return "", "", err
+	}
+	if config.HCloudToken == "" {
+		return ipv4, "", nil
+	}
+
+	id, err := metadataGet("/hetzner/v1/metadata/instance-id", nil)
+	if err != nil {
+		return "", "", err
+	}
+	req, _ := http.NewRequestWithContext(runContext, "GET", hcloudAPI+"/servers/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+config.HCloudToken)
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", "", &StatusError{Op: "hcloud server", Code: resp.StatusCode, Status: resp.Status}
+	}
+	var server struct {
+		Server struct {
+			PublicNet struct {
+				IPv6 *struct {
+					IP string `json:"ip"`
+				} `json:"ipv6"`
+			} `json:"public_net"`
+		} `json:"server"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&server); err != nil {
+		return "", "", err
+	}
+	ipv6 := ""
+	if network := server.Server.PublicNet.IPv6; network != nil {
+		if prefix, _, err := net.ParseCIDR(network.IP); err == nil {
+			prefix[len(prefix)-1] = 1
+			ipv6 = prefix.String()
+		}
+	}
+	return ipv4, ipv6, nil
+}
+
+func metadataGet(path string, header map[string]string) (string, error) {
+	req, _ := http.NewRequestWithContext(runContext, "GET", metadataHost+path, nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("metadata %s status: %s", path, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return strings.TrimSpace(string(data)), err
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,169 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const metricsPrefix = "hetzner_dns_update"
+
+type runMetric struct {
+	name  string
+	help  string
+	value float64
+}
+
+func collectMetrics(start time.Time, records int) []runMetric {
+	metrics := []runMetric{
+		{"last_run_timestamp_seconds", "Start time of the last run.", float64(start.Unix())},
+		{"last_run_duration_seconds", "Duration of the last run.", time.Since(start).Seconds()},
+		{"last_run_changes", "Records created, updated or deleted in the last run.", float64(runChanges)},
+		{"last_run_errors", "Errors in the last run.", float64(runErrors)},
+		{"managed_records", "Number of managed records.", float64(records)},
+	}
+	if latency := live.status().Latency; latency != nil {
+		metrics = append(metrics,
+			runMetric{"propagation_latency_p50_seconds", "Median time from IP change detection to the nameservers answering.", latency.P50},
+			runMetric{"propagation_latency_p90_seconds", "90th percentile of the propagation latency.", latency.P90},
+			runMetric{"propagation_latency_p99_seconds", "99th percentile of the propagation latency.", latency.P99})
+		if latency.Target > 0 {
+			metrics = append(metrics, runMetric{"propagation_latency_within_target_ratio",
+				"Share of IP changes answered within the latency SLO target.", latency.WithinTarget})
+		}
+	}
+	return metrics
+}
+
+// writeMetrics writes node_exporter textfile-collector metrics for this run
+func writeMetrics(start time.Time, records int) error {
+	if config.MetricsFile == "" {
+		return nil
+	}
+
+	last_success := readMetric(config.MetricsFile, metricsPrefix+"_last_success_timestamp_seconds")
+	if runErrors == 0 {
+		last_success = float64(start.Unix())
+	}
+	metrics := append(collectMetrics(start, records),
+		runMetric{"last_success_timestamp_seconds", "Start time of the last run without errors.", last_success})
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s_%s %s\n", metricsPrefix, m.name, m.help)
+		fmt.Fprintf(&b, "# TYPE %s_%s gauge\n", metricsPrefix, m.name)
+		fmt.Fprintf(&b, "%s_%s %g\n", metricsPrefix, m.name, m.value)
+	}
+	if id := live.runID(); id != "" {
+		fmt.Fprintf(&b, "# HELP %s_last_run_info ID of the last run, as in its log lines and notifications.\n", metricsPrefix)
+		fmt.Fprintf(&b, "# TYPE %s_last_run_info gauge\n", metricsPrefix)
+		fmt.Fprintf(&b, "%s_last_run_info{run_id=\"%s\"} 1\n", metricsPrefix, id)
+	}
+	writeRecordMetrics(&b)
+
+	// write atomically so the collector never reads a partial file
+	tmp_file := filepath.Join(filepath.Dir(config.MetricsFile), "."+filepath.Base(config.MetricsFile)+".tmp")
+	if err := os.WriteFile(tmp_file, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp_file, config.MetricsFile)
+}
+
+// writeRecordMetrics adds the last change of each record, labeled with
+// its 'labels' for per-customer dashboards
+func writeRecordMetrics(b *strings.Builder) {
+	records := live.recordList()
+	name := metricsPrefix + "_record_last_change_timestamp_seconds"
+	fmt.Fprintf(b, "# HELP %s Time of the last change of a record.\n", name)
+	fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+	for _, fullDomain := range slices.Sorted(maps.Keys(records)) {
+		if last_change := records[fullDomain].LastChange; !last_change.IsZero() {
+			fmt.Fprintf(b, "%s{record=%q%s} %d\n", name, fullDomain, promLabels(recordLabels(fullDomain)), last_change.Unix())
+		}
+	}
+}
+
+func readMetric(filename, name string) float64 {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == name {
+			var value float64
+			fmt.Sscan(fields[1], &value)
+			return value
+		}
+	}
+	return 0
+}
+
+// pushMetrics emits the run metrics as statsd gauges or Graphite plaintext lines
+func pushMetrics(start time.Time, records int) error {
+	push := config.MetricsPush
+	if push.Address == "" {
+		return nil
+	}
+	prefix := push.Prefix
+	if prefix == "" {
+		prefix = metricsPrefix
+	}
+
+	var network, line string
+	switch push.Protocol {
+	case "statsd", "":
+		network, line = "udp", "%s.%s:%g|g\n"
+	case "graphite":
+		network, line = "tcp", "%s.%s %g "+fmt.Sprint(start.Unix())+"\n"
+	default:
+		return fmt.Errorf("unknown metrics protocol '%s'", push.Protocol)
+	}
+
+	var b strings.Builder
+	for _, m := range collectMetrics(start, records) {
+		fmt.Fprintf(&b, line, prefix, m.name, m.value)
+	}
+
+	conn, err := net.DialTimeout(network, push.Address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(b.String()))
+	return err
+}
+
+// reportRun hands the outcome of a run to the configured monitoring outputs
+func reportRun(start time.Time, records int, checkmk bool) {
+	failed := live.countFailedRun(runErrors > 0)
+	alertFailedRuns(failed)
+	openFailureTicket(failed)
+	err := writeMetrics(start, records)
+	if err != nil {
+		log.Println("error writing metrics file:", err)
+	}
+	err = pushMetrics(start, records)
+	if err != nil {
+		log.Println("error pushing metrics:", err)
+	}
+	err = writeCheckMK(start, records)
+	if err != nil {
+		log.Println("error writing CheckMK spool file:", err)
+	}
+	if checkmk {
+		fmt.Print(checkMKLine(start, records))
+	}
+}
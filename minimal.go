@@ -0,0 +1,64 @@
+//go:build minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// The client-only build (go build -tags minimal) keeps IP detection and
+// record updates and leaves out SMTP, the daemon and its servers, the TUI,
+// metrics and change notifications. Config keys for those are accepted but
+// have no effect.
+
+func runDaemon(opts runOptions) {
+	fmt.Fprintln(os.Stderr, "daemon mode is not available in the client-only build, run from cron instead")
+	os.Exit(1)
+}
+
+// daemonInterval is 0 without the daemon, 'schedule show' leaves it out
+func daemonInterval() time.Duration {
+	return 0
+}
+
+func runTUI(opts runOptions) error {
+	return errors.New("tui is not available in the client-only build")
+}
+
+func reportRun(start time.Time, records int, checkmk bool) {
+}
+
+func sendNotification(subject, body string) {
+	log.Println("not sending notification (client-only build):", subject)
+}
+
+func flushDigests() {
+}
+
+func validateNotifications() error {
+	return nil
+}
+
+func notifyChange(diff RecordDiff) {
+}
+
+func sendCloudEvent(diff RecordDiff) {
+}
+
+func validateSIEM() error {
+	return nil
+}
+
+func sendSIEMChange(diff RecordDiff) {
+}
+
+func sendSIEMError(message string) {
+}
+
+func openSQLiteStore(path string) (stateStore, error) {
+	return nil, errors.New("state backend 'sqlite' is not available in the client-only build")
+}
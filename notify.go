@@ -0,0 +1,183 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultNotificationTimeout = 60
+
+// notifier is a channel for the status and change notifications
+type notifier interface {
+	send(subject, body string) error
+}
+
+type notifierFunc func(subject, body string) error
+
+func (f notifierFunc) send(subject, body string) error {
+	return f(subject, body)
+}
+
+// notifiers returns the configured channels by name, without the ones
+// switched off in 'notifications.disabled'
+func notifiers() map[string]notifier {
+	all := make(map[string]notifier)
+	if config.SMTP.Server != "" {
+		all["smtp"] = notifierFunc(sendEmail)
+	}
+	if config.Signal.Account != "" {
+		all["signal"] = notifierFunc(sendSignal)
+	}
+	if config.XMPP.JID != "" {
+		all["xmpp"] = notifierFunc(sendXMPP)
+	}
+	for _, webhook := range config.Notifications.Webhooks {
+		all[webhook.Name] = webhookNotifier(webhook)
+	}
+	for name := range all {
+		if slices.Contains(config.Notifications.Disabled, name) {
+			delete(all, name)
+		}
+	}
+	return all
+}
+
+// sendNotification delivers a message via all channels at once, a failing
+// or hanging channel doesn't keep the others from sending
+func sendNotification(subject, body string) {
+	body = scrubSecrets(body) + runIDLine()
+	timeout := config.Notifications.Timeout
+	if timeout <= 0 {
+		timeout = defaultNotificationTimeout
+	}
+
+	var wg sync.WaitGroup
+	for name, n := range notifiers() {
+		if quietHours(name, clock.Now()) {
+			log.Printf("quiet hours of %s, adding '%s' to the digest\n", name, subject)
+			live.queueDigest(name, QueuedNotification{Time: clock.Now(), Subject: subject, Body: body})
+			continue
+		}
+		wg.Add(1)
+		goSafe(func() {
+			defer wg.Done()
+			if err := n.send(subject, body); err != nil {
+				log.Printf("error sending %s notification: %s\n", name, err)
+			}
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := clock.NewTimer(time.Duration(timeout) * time.Second)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C():
+		log.Printf("notification '%s' still not sent after %ds\n", subject, timeout)
+	}
+}
+
+// quietHours reports whether a channel holds back its notifications now,
+// channels without 'quiet_hours' always send
+func quietHours(channel string, now time.Time) bool {
+	for _, window := range config.Notifications.QuietHours[channel] {
+		// rejected by validateNotifications if invalid
+		c, _ := parseCron(window)
+		if c.matches(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// flushDigests sends the notifications held back during the quiet hours
+// as one message per channel once they are over
+func flushDigests() {
+	channels := notifiers()
+	for _, name := range live.digestChannels() {
+		n, ok := channels[name]
+		if !ok {
+			live.takeDigest(name)
+			continue
+		}
+		if quietHours(name, clock.Now()) {
+			continue
+		}
+		queued := live.takeDigest(name)
+		var body strings.Builder
+		for _, q := range queued {
+			fmt.Fprintf(&body, "%s  %s\r\n%s\r\n\r\n", q.Time.Local().Format("2006-01-02 15:04"), q.Subject, strings.TrimSpace(q.Body))
+		}
+		subject := fmt.Sprintf("DNS Update: %d notifications during quiet hours", len(queued))
+		if err := n.send(subject, body.String()); err != nil {
+			log.Printf("error sending %s digest: %s\n", name, err)
+		}
+	}
+}
+
+// webhookNotifier posts the subject and body as JSON
+type webhookNotifier NotifyWebhook
+
+func (w webhookNotifier) send(subject, body string) error {
+	payload, _ := json.Marshal(map[string]string{
+		"subject":  subject,
+		"body":     body,
+		"instance": instanceName(),
+		"run_id":   live.runID(),
+	})
+	req, err := http.NewRequestWithContext(runContext, "POST", w.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// validateNotifications rejects webhooks that can't be told apart from
+// each other or from the built-in channels
+func validateNotifications() error {
+	seen := map[string]bool{"smtp": true, "signal": true, "xmpp": true}
+	for i, webhook := range config.Notifications.Webhooks {
+		if webhook.Name == "" || webhook.URL == "" {
+			return fmt.Errorf("notifications: webhook %d needs a 'name' and a 'url'", i+1)
+		}
+		if seen[webhook.Name] {
+			return fmt.Errorf("notifications: duplicate name '%s'", webhook.Name)
+		}
+		seen[webhook.Name] = true
+	}
+	for channel, windows := range config.Notifications.QuietHours {
+		if !seen[channel] {
+			return fmt.Errorf("notifications: quiet_hours for unknown channel '%s'", channel)
+		}
+		for _, window := range windows {
+			if _, err := parseCron(window); err != nil {
+				return fmt.Errorf("notifications: quiet_hours of %s: %w", channel, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursDigest(t *testing.T) {
+	var mu sync.Mutex
+	var subjects []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		subjects = append(subjects, payload["subject"])
+	}))
+	defer server.Close()
+	sent := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), subjects...)
+	}
+
+	useConfig(t, Config{Notifications: NotificationsConfig{
+		Webhooks:   []NotifyWebhook{{Name: "ops", URL: server.URL}},
+		QuietHours: map[string][]string{"ops": {"* 22-23 * * *"}},
+	}})
+	m := useManualClock(t, time.Date(2024, 1, 1, 22, 30, 0, 0, time.UTC))
+	t.Cleanup(func() { live.takeDigest("ops") })
+
+	sendNotification("DNS Update: first", "body")
+	sendNotification("DNS Update: second", "body")
+	flushDigests()
+	if got := sent(); len(got) != 0 {
+		t.Fatalf("sent during the quiet hours: %v", got)
+	}
+
+	m.Advance(90 * time.Minute)
+	flushDigests()
+	got := sent()
+	if len(got) != 1 {
+		t.Fatalf("sent %v after the quiet hours, want one digest", got)
+	}
+	if digest := live.takeDigest("ops"); len(digest) != 0 {
+		t.Errorf("digest still holds %d notifications", len(digest))
+	}
+
+	sendNotification("DNS Update: third", "body")
+	if got := sent(); len(got) != 2 || got[1] != "DNS Update: third" {
+		t.Errorf("sent %v outside the quiet hours, want the notification itself", got)
+	}
+}
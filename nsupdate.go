@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// nsupdateProvider manages the zones on an authoritative server like BIND
+// or Knot: records are read via AXFR and changed with RFC 2136 dynamic
+// updates, both signed with TSIG if a key is set. A zone's ID is its name,
+// a record's ID is its zone and the record in presentation format
+type nsupdateProvider struct{}
+
+func (nsupdateProvider) key() tsigKey {
+	return tsigKey{config.NSUpdate.TSIGName, config.NSUpdate.TSIGSecret, config.NSUpdate.TSIGAlgorithm}
+}
+
+func (p nsupdateProvider) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	address, err := serverAddress(config.NSUpdate.Server)
+	if err != nil {
+		return nil, err
+	}
+	client := &dns.Client{Timeout: 10 * time.Second, TsigSecret: p.key().sign(msg)}
+	resp, _, err := client.ExchangeContext(runContext, msg, address)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s from %s", dns.RcodeToString[resp.Rcode], config.NSUpdate.Server)
+	}
+	return resp, nil
+}
+
+// Zones returns the zones of the configured records and filters, the
+// server can't be asked for the zones it serves
+func (p nsupdateProvider) Zones(fn func(Zone)) error {
+	for _, name := range configuredZones() {
+		zone, err := p.FindZone(name)
+		if err != nil {
+			return err
+		}
+		fn(zone)
+	}
+	return nil
+}
+
+// FindZone requires an authoritative SOA for the name, its TTL is used
+// for records without one
+func (p nsupdateProvider) FindZone(name string) (Zone, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeSOA)
+	msg.RecursionDesired = false
+	resp, err := p.exchange(msg)
+	if err != nil {
+		return Zone{}, fmt.Errorf("can't find domain '%s': %w", name, err)
+	}
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok && resp.Authoritative && strings.EqualFold(soa.Hdr.Name, dns.Fqdn(name)) {
+			return Zone{ID: name, Name: name, Status: "verified", TTL: int(soa.Hdr.Ttl)}, nil
+		}
+	}
+	return Zone{}, fmt.Errorf("can't find domain '%s': %s is not authoritative for it", name, config.NSUpdate.Server)
+}
+
+func (p nsupdateProvider) FindZoneID(name string) (string, error) {
+	zone, err := p.FindZone(name)
+	return zone.ID, err
+}
+
+// Records calls fn for each record of a zone transfer, except the
+// closing SOA
+func (p nsupdateProvider) Records(zoneID string, fn func(Record)) error {
+	records, err := transferZone(config.NSUpdate.Server, p.key(), zoneID)
+	if err != nil {
+		return err
+	}
+	for i, rr := range records {
+		if i > 0 && rr.Header().Rrtype == dns.TypeSOA {
+			continue
+		}
+		header := rr.Header()
+		fn(Record{
+			ID:    nsupdateRecordID(zoneID, rr),
+			Type:  dns.TypeToString[header.Rrtype],
+			Name:  relativeName(header.Name, zoneID),
+			Value: strings.TrimPrefix(rr.String(), header.String()),
+			TTL:   int(header.Ttl),
+		})
+	}
+	return nil
+}
+
+// relativeName is the name of a record within the zone, "@" for its apex
+func relativeName(name, zone string) string {
+	name, zone = strings.ToLower(name), strings.ToLower(dns.Fqdn(zone))
+	if name == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+func (p nsupdateProvider) FindRecords(zoneID, name string) ([]Record, []Record, error) {
+	return findAddressRecords(p, zoneID, name)
+}
+
+// newRR builds a record of the zone, a ttl of 0 uses the TTL of its SOA
+func (p nsupdateProvider) newRR(zoneID, recType, name, value string, ttl int) (dns.RR, error) {
+	if ttl <= 0 {
+		zone, err := p.FindZone(zoneID)
+		if err != nil {
+			return nil, err
+		}
+		ttl = zone.TTL
+	}
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", recordName(name, dns.Fqdn(zoneID)), ttl, recType, value))
+}
+
+func (p nsupdateProvider) update(zoneID string, remove, insert dns.RR) error {
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(zoneID))
+	if remove != nil {
+		msg.Remove([]dns.RR{remove})
+	}
+	if insert != nil {
+		msg.Insert([]dns.RR{insert})
+	}
+	_, err := p.exchange(msg)
+	return err
+}
+
+func (p nsupdateProvider) CreateRecord(zoneID, recType, name, value string, ttl int) error {
+	rr, err := p.newRR(zoneID, recType, name, value, ttl)
+	if err != nil {
+		return err
+	}
+	return p.update(zoneID, nil, rr)
+}
+
+// UpdateRecord removes the old and adds the new record in one update
+func (p nsupdateProvider) UpdateRecord(zoneID, recordID, recType, name, value string, ttl int) error {
+	_, old, err := parseNSUpdateRecordID(recordID)
+	if err != nil {
+		return err
+	}
+	rr, err := p.newRR(zoneID, recType, name, value, ttl)
+	if err != nil {
+		return err
+	}
+	return p.update(zoneID, old, rr)
+}
+
+func (p nsupdateProvider) DeleteRecord(recordID string) error {
+	zoneID, old, err := parseNSUpdateRecordID(recordID)
+	if err != nil {
+		return err
+	}
+	return p.update(zoneID, old, nil)
+}
+
+func nsupdateRecordID(zoneID string, rr dns.RR) string {
+	header := rr.Header()
+	return fmt.Sprintf("%s/%s %s %s", zoneID, strings.ToLower(header.Name), dns.TypeToString[header.Rrtype],
+		strings.TrimPrefix(rr.String(), header.String()))
+}
+
+func parseNSUpdateRecordID(recordID string) (string, dns.RR, error) {
+	zoneID, record, ok := strings.Cut(recordID, "/")
+	if !ok {
+		return "", nil, fmt.Errorf("invalid record ID '%s'", recordID)
+	}
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid record ID '%s': %w", recordID, err)
+	}
+	return zoneID, rr, nil
+}
+
+func validateProvider() error {
+	switch config.Provider {
+	case "", "hetzner":
+		return nil
+	case "rfc2136":
+		if config.NSUpdate.Server == "" {
+			return fmt.Errorf("provider 'rfc2136' needs nsupdate.server")
+		}
+		if config.NSUpdate.TSIGName != "" && config.NSUpdate.TSIGSecret == "" {
+			return fmt.Errorf("nsupdate: tsig_name without tsig_secret")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown provider '%s' (hetzner, rfc2136)", config.Provider)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const uciConfigFile = "/etc/config/hetzner_dns_update"
+
+const procdInitScript = `#!/bin/sh /etc/rc.common
+# procd init script for hetzner-dns-update
+# configuration: /etc/config/hetzner_dns_update
+
+USE_PROCD=1
+START=95
+STOP=10
+
+start_service() {
+	procd_open_instance
+	procd_set_param command %s -openwrt -update
+	procd_set_param stderr 1
+	procd_close_instance
+}
+
+service_triggers() {
+	procd_add_reload_trigger "hetzner_dns_update"
+	procd_add_interface_trigger "interface.*.up" wan /etc/init.d/hetzner-dns-update restart
+}
+`
+
+func printProcdInit() {
+	binary, err := os.Executable()
+	if err != nil {
+		binary = "/usr/bin/hetzner-dns-update"
+	}
+	fmt.Printf(procdInitScript, binary)
+}
+
+// loadUCIConfig reads an OpenWrt style config file, e.g.
+//
+//	config hetzner_dns_update 'main'
+//		option api_token 'secret'
+//		option ttl '60'
+//		list record 'router.example.com'
+//		option smtp_server 'smtp.example.com'
+func loadUCIConfig(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line_no := 0
+	for scanner.Scan() {
+		line_no++
+		fields, err := splitUCILine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filename, line_no, err)
+		}
+		if len(fields) == 0 || fields[0] == "config" || fields[0] == "package" {
+			continue
+		}
+		if len(fields) != 3 || (fields[0] != "option" && fields[0] != "list") {
+			return fmt.Errorf("%s:%d: can't parse '%s'", filename, line_no, scanner.Text())
+		}
+
+		key, value := fields[1], fields[2]
+		switch key {
+		case "api_token":
+			config.APIToken = value
+		case "record", "records":
+			config.Records = append(config.Records, value)
+		case "ttl":
+			config.TTL, err = parseSeconds(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: invalid ttl '%s'", filename, line_no, value)
+			}
+		case "memory_limit_mb":
+			config.MemoryLimit, err = strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: invalid memory_limit_mb '%s'", filename, line_no, value)
+			}
+		case "smtp_server":
+			config.SMTP.Server = value
+		case "smtp_port":
+			config.SMTP.Port = value
+		case "smtp_user":
+			config.SMTP.User = value
+		case "smtp_password":
+			config.SMTP.Password = value
+		case "smtp_recipient":
+			config.SMTP.Recipient = value
+		}
+	}
+	return scanner.Err()
+}
+
+func splitUCILine(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	quote := rune(0)
+	in_field := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			in_field = true
+		case r == '#':
+			if in_field {
+				fields = append(fields, field.String())
+			}
+			return fields, nil
+		case r == ' ' || r == '\t':
+			if in_field {
+				fields = append(fields, field.String())
+				field.Reset()
+				in_field = false
+			}
+		default:
+			field.WriteRune(r)
+			in_field = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if in_field {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
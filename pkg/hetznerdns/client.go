@@ -0,0 +1,322 @@
+// Package hetznerdns is a small client for the zones and records of the
+// Hetzner DNS API, as used by hetzner-dns-update. Zone and record lists are
+// decoded as a stream, so accounts with large zones need little memory.
+package hetznerdns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://dns.hetzner.com/api/v1"
+
+type Zone struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Paused     bool   `json:"paused"`
+	Permission string `json:"permission"`
+	Secondary  bool   `json:"is_secondary_dns"`
+
+	TTL          int `json:"ttl"`
+	RecordsCount int `json:"records_count"`
+}
+
+type Record struct {
+	ID     string `json:"id"`
+	ZoneID string `json:"zone_id,omitempty"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl"`
+}
+
+// StatusError is a request answered with an unexpected HTTP status,
+// RetryAfter is set from the Retry-After header of a 429 or 503
+type StatusError struct {
+	Op         string
+	Code       int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s status: %s", e.Op, e.Status)
+}
+
+// Client calls the API with an API token, BaseURL may point to a test
+// server
+type Client struct {
+	BaseURL string
+
+	token string
+	http  *http.Client
+	ctx   context.Context
+}
+
+// NewClient returns a client for the token, http.DefaultClient is used if
+// httpClient is nil
+func NewClient(token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{BaseURL: DefaultBaseURL, token: token, http: httpClient, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the client whose requests are cancelled
+// with ctx
+func (c *Client) WithContext(ctx context.Context) *Client {
+	client := *c
+	client.ctx = ctx
+	return &client
+}
+
+func (c *Client) do(op, method, path string, payload any) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	contentType := ""
+	if payload != nil || method == "DELETE" {
+		contentType = "application/json"
+	}
+	return c.send(op, method, path, body, contentType)
+}
+
+// send makes a request with a body of contentType, unless it is empty
+func (c *Client) send(op, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Auth-API-Token", c.token)
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{op, resp.StatusCode, resp.Status, retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return resp, nil
+}
+
+// retryAfter parses the seconds or the date of a Retry-After header
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// pagination is the 'meta' of a list response
+type pagination struct {
+	Pagination struct {
+		Page         int `json:"page"`
+		PerPage      int `json:"per_page"`
+		LastPage     int `json:"last_page"`
+		TotalEntries int `json:"total_entries"`
+	} `json:"pagination"`
+}
+
+// list calls fn for each element of the array 'key' on all pages of a
+// listing; the first page is requested without parameters, the following
+// ones with the page size the API reported for it
+func (c *Client) list(op, path, key string, fn func(*json.Decoder) error) error {
+	next := path
+	for page := 1; ; page++ {
+		var meta pagination
+		err := c.decodePage(op, next, map[string]func(*json.Decoder) error{
+			key: func(dec *json.Decoder) error {
+				return eachElement(dec, fn)
+			},
+			"meta": func(dec *json.Decoder) error {
+				return dec.Decode(&meta)
+			},
+		})
+		if err != nil {
+			return err
+		}
+		p := meta.Pagination
+		if p.LastPage <= page || p.PerPage <= 0 {
+			return nil
+		}
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		next = fmt.Sprintf("%s%spage=%d&per_page=%d", path, separator, page+1, p.PerPage)
+	}
+}
+
+func (c *Client) decodePage(op, path string, handlers map[string]func(*json.Decoder) error) error {
+	resp, err := c.do(op, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeObject(resp.Body, handlers)
+}
+
+// Zones calls fn for each zone of the account, on all pages
+func (c *Client) Zones(fn func(Zone)) error {
+	return c.list("zones", "/zones", "zones", func(dec *json.Decoder) error {
+		var zone Zone
+		if err := dec.Decode(&zone); err != nil {
+			return err
+		}
+		fn(zone)
+		return nil
+	})
+}
+
+// FindZone returns the zone with the name, e.g. 'example.com'
+func (c *Client) FindZone(name string) (Zone, error) {
+	found := Zone{}
+	err := c.Zones(func(zone Zone) {
+		if zone.Name == name {
+			found = zone
+		}
+	})
+	if err != nil {
+		return Zone{}, err
+	}
+	if found.ID == "" {
+		return Zone{}, fmt.Errorf("can't find domain '%s'", name)
+	}
+	return found, nil
+}
+
+// FindZoneID returns the ID of the zone with the name
+func (c *Client) FindZoneID(name string) (string, error) {
+	zone, err := c.FindZone(name)
+	return zone.ID, err
+}
+
+// Records calls fn for each record of the zone, on all pages
+func (c *Client) Records(zoneID string, fn func(Record)) error {
+	return c.list("records", "/records?zone_id="+zoneID, "records", func(dec *json.Decoder) error {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			return err
+		}
+		fn(rec)
+		return nil
+	})
+}
+
+// FindRecords returns all A and AAAA records of a name within the zone,
+// e.g. 'www', a zone may contain duplicates; both are empty for a name
+// without records
+func (c *Client) FindRecords(zoneID, name string) ([]Record, []Record, error) {
+	var recordsA, recordsAAAA []Record
+	err := c.Records(zoneID, func(rec Record) {
+		if rec.Name == name && rec.Type == "A" {
+			recordsA = append(recordsA, rec)
+		}
+		if rec.Name == name && rec.Type == "AAAA" {
+			recordsAAAA = append(recordsAAAA, rec)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return recordsA, recordsAAAA, nil
+}
+
+// recordPayload leaves out the TTL if it is 0, the zone default is used then
+func recordPayload(zoneID, recType, name, value string, ttl int) map[string]any {
+	payload := map[string]any{
+		"zone_id": zoneID,
+		"type":    recType,
+		"name":    name,
+		"value":   value,
+	}
+	if ttl > 0 {
+		payload["ttl"] = ttl
+	}
+	return payload
+}
+
+// CreateRecord adds a record, a ttl of 0 uses the zone's default
+func (c *Client) CreateRecord(zoneID, recType, name, value string, ttl int) error {
+	resp, err := c.do("create", "POST", "/records", recordPayload(zoneID, recType, name, value, ttl))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// UpdateRecord replaces a record, a ttl of 0 uses the zone's default
+func (c *Client) UpdateRecord(zoneID, recordID, recType, name, value string, ttl int) error {
+	resp, err := c.do("update", "PUT", "/records/"+recordID, recordPayload(zoneID, recType, name, value, ttl))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CreateRecords adds the records, each with its ZoneID, in one request;
+// the records the API rejected are returned
+func (c *Client) CreateRecords(records []Record) ([]Record, error) {
+	return c.bulk("bulk create", "POST", records, "invalid_records")
+}
+
+// UpdateRecords replaces the records with the IDs in one request, the
+// records that failed are returned
+func (c *Client) UpdateRecords(records []Record) ([]Record, error) {
+	return c.bulk("bulk update", "PUT", records, "failed_records")
+}
+
+func (c *Client) bulk(op, method string, records []Record, failedKey string) ([]Record, error) {
+	var payload []map[string]any
+	for _, rec := range records {
+		entry := recordPayload(rec.ZoneID, rec.Type, rec.Name, rec.Value, rec.TTL)
+		if rec.ID != "" {
+			entry["id"] = rec.ID
+		}
+		payload = append(payload, entry)
+	}
+	resp, err := c.do(op, method, "/records/bulk", map[string]any{"records": payload})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var failed []Record
+	err = decodeObject(resp.Body, map[string]func(*json.Decoder) error{
+		failedKey: func(dec *json.Decoder) error {
+			return dec.Decode(&failed)
+		},
+	})
+	return failed, err
+}
+
+// DeleteRecord removes the record with the ID
+func (c *Client) DeleteRecord(recordID string) error {
+	resp, err := c.do("delete", "DELETE", "/records/"+recordID, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
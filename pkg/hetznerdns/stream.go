@@ -0,0 +1,78 @@
+package hetznerdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// upper limit of a response, against a broken or hostile endpoint
+const maxResponseSize = 64 << 20
+
+// decodeObject walks a top level JSON object without buffering it and calls
+// the handler registered for a key to decode its value, other keys are skipped
+func decodeObject(r io.Reader, handlers map[string]func(*json.Decoder) error) error {
+	dec := json.NewDecoder(io.LimitReader(r, maxResponseSize))
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if handler, ok := handlers[key]; ok {
+			err = handler(dec)
+		} else {
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return fmt.Errorf("decoding '%s': %w", key, err)
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// eachElement decodes the elements of a JSON array one at a time
+func eachElement(dec *json.Decoder, fn func(*json.Decoder) error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := fn(dec); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected '%s' in JSON, got '%v'", delim, token)
+	}
+	return nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
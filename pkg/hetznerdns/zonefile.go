@@ -0,0 +1,51 @@
+package hetznerdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportZone returns the zone as a BIND zone file
+func (c *Client) ExportZone(zoneID string) (string, error) {
+	resp, err := c.send("zone export", "GET", "/zones/"+zoneID+"/export", nil, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// ImportZone replaces all records of the zone with the ones of a zone file
+func (c *Client) ImportZone(zoneID, zoneFile string) error {
+	resp, err := c.send("zone import", "POST", "/zones/"+zoneID+"/import", strings.NewReader(zoneFile), "text/plain")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ValidateZoneFile checks a zone file without importing it, a file with
+// invalid records is an error
+func (c *Client) ValidateZoneFile(zoneFile string) error {
+	resp, err := c.send("zone file validation", "POST", "/zones/file/validate", strings.NewReader(zoneFile), "text/plain")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var validation struct {
+		ParsedRecords  int               `json:"parsed_records"`
+		InvalidRecords []json.RawMessage `json:"invalid_records"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&validation); err != nil {
+		return err
+	}
+	if len(validation.InvalidRecords) > 0 {
+		return fmt.Errorf("%d invalid records: %s", len(validation.InvalidRecords), validation.InvalidRecords[0])
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// pause between batches if only the batch size is set
+const defaultBatchPause = 10
+
+// Change is a create, update or delete of one A/AAAA record, or the
+// delete of a duplicate ('dedupe')
+type Change struct {
+	Action     string  `json:"action"`
+	FullDomain string  `json:"record"`
+	Zone       string  `json:"zone"`
+	ZoneID     string  `json:"zone_id"`
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	RecordID   string  `json:"record_id,omitempty"`
+	OldValue   string  `json:"old_value,omitempty"`
+	NewValue   string  `json:"new_value,omitempty"`
+	TTL        Seconds `json:"ttl,omitempty"`
+	OldTTL     Seconds `json:"old_ttl,omitempty"`
+}
+
+// planRecord compares an existing A/AAAA record with the current public IP
+// and returns the change needed, if any
+func planRecord(managed ManagedRecord, zoneID, recType string, record Record, ip string, verbose bool) *Change {
+	change := &Change{
+		FullDomain: managed.FullDomain,
+		Zone:       managed.Zone,
+		ZoneID:     zoneID,
+		Name:       managed.Name,
+		Type:       recType,
+		RecordID:   record.ID,
+		OldValue:   record.Value,
+		NewValue:   ip,
+		TTL:        managed.TTL,
+		OldTTL:     Seconds(record.TTL),
+	}
+
+	if ip != "" {
+		if record.Value != "" {
+			// Case: cur+ / rec+
+			if record.Value == ip && (!managed.OverrideTTL || Seconds(record.TTL) == managed.TTL) {
+				if verbose {
+					fmt.Printf("- %s record is current for: %s\n", recType, managed.FullDomain)
+				}
+				return nil
+			}
+			change.Action = "update"
+		} else {
+			// Case: cur+ / rec-
+			change.Action = "create"
+		}
+	} else {
+		if record.Value != "" {
+			// Case: cur- / rec+
+			if !allowDelete() {
+				if verbose {
+					fmt.Printf("- %s record is kept (allow_delete is false) for: %s\n", recType, managed.FullDomain)
+				}
+				return nil
+			}
+			if !deletesRecords(managed.Zone) {
+				if verbose {
+					fmt.Printf("- %s record is kept (DuckDNS can't delete it) for: %s\n", recType, managed.FullDomain)
+				}
+				return nil
+			}
+			change.Action = "delete"
+		} else {
+			// Case: cur- / rec-
+			if verbose {
+				fmt.Printf("- no need for %s record for: %s\n", recType, managed.FullDomain)
+			}
+			return nil
+		}
+	}
+
+	if verbose {
+		fmt.Printf("- %s record needs %s for: %s\n", recType, change.Action, managed.FullDomain)
+	}
+	return change
+}
+
+// keepAddress stands for an unknown address of a family, the records of
+// that family are left as they are instead of being deleted
+const keepAddress = "keep"
+
+func orKeep(ip string) string {
+	if ip == "" {
+		return keepAddress
+	}
+	return ip
+}
+
+// planChanges compares the managed records with the current public IPs,
+// records a dyndns2 client reported get the reported addresses
+func planChanges(records []ManagedRecord, ipv4, ipv6 string, verbose bool) []Change {
+	var changes []Change
+	runSkipped = nil
+	type sourceResult struct {
+		ipv4, ipv6 string
+		err        error
+	}
+	sources := make(map[string]sourceResult)
+	for _, managed := range records {
+		fullDomain := managed.FullDomain
+		if verbose {
+			fmt.Println("processing record:", fullDomain)
+			for _, note := range live.recordNotes(fullDomain) {
+				fmt.Printf("  note: %s\n", note.Note)
+			}
+		}
+
+		recordIPv4, recordIPv6 := ipv4, ipv6
+		if reported, ok := live.reportedIPs(fullDomain); ok {
+			recordIPv4, recordIPv6 = orKeep(reported.IPv4), orKeep(reported.IPv6)
+			if verbose {
+				fmt.Printf("- reported via dyndns2: '%s' / '%s'\n", reported.IPv4, reported.IPv6)
+			}
+		} else if managed.Source != "" {
+			result, ok := sources[managed.Source]
+			if !ok {
+				result.ipv4, result.ipv6, result.err = sourceIPs(managed.Source)
+				sources[managed.Source] = result
+			}
+			if result.err != nil {
+				logAndMail(fmt.Sprintf("error reading source of %s: %s", fullDomain, result.err))
+				continue
+			}
+			recordIPv4, recordIPv6 = result.ipv4, result.ipv6
+			if verbose {
+				fmt.Printf("- source '%s': '%s' / '%s'\n", managed.Source, recordIPv4, recordIPv6)
+			}
+		}
+
+		if live.isFrozen(fullDomain) {
+			if verbose {
+				fmt.Println("- record is frozen:", fullDomain)
+			}
+			continue
+		}
+
+		if skipRecord(managed) {
+			continue
+		}
+		zone, err := findZone(managed.Zone)
+		if err != nil {
+			skipZone(managed.Zone, err)
+			skipRecord(managed)
+			continue
+		}
+		if reason := zoneProtected(zone); reason != "" {
+			skipProtected(zone.Name, fmt.Sprintf("skipping zone '%s': %s", zone.Name, reason))
+			continue
+		}
+		zoneID := zone.ID
+
+		// only a failure to read the zone skips it, a missing name is created
+		recordsA, recordsAAAA, err := findRecords(zoneID, managed.Name)
+		if err != nil {
+			skipZone(managed.Zone, err)
+			skipRecord(managed)
+			continue
+		}
+		recordA, extraA := pickRecord(managed, "A", recordsA, recordIPv4)
+		recordAAAA, extraAAAA := pickRecord(managed, "AAAA", recordsAAAA, recordIPv6)
+		live.setRecord(fullDomain, recordA.Value, recordAAAA.Value)
+
+		//
+		// Handle IPv4 and IPv6
+		//
+		for _, current := range []struct {
+			recType string
+			record  Record
+			extra   []Record
+			ip      string
+		}{{"A", recordA, extraA, recordIPv4}, {"AAAA", recordAAAA, extraAAAA, recordIPv6}} {
+			if !managed.manages(current.recType) || current.ip == keepAddress {
+				continue
+			}
+			changes = append(changes, dedupeChanges(managed, zoneID, current.recType, current.record, current.extra, verbose)...)
+			ip, moved := recordTarget(managed, current.recType, current.record.Value, current.ip)
+			if moved {
+				ipv6Prefixes.remaining++
+			}
+			ramped := rampTTL(managed, current.recType, current.record, ip)
+			change := planRecord(ramped, zoneID, current.recType, current.record, ip, verbose)
+			if change != nil && probeChange(managed, change) {
+				changes = append(changes, *change)
+			}
+		}
+	}
+
+	return changes
+}
+
+// recordTarget returns the value a record should have for the public IP,
+// an AAAA record with its host suffix or IPv6 prefix applied; moved
+// reports a record taken out of the previous IPv6 prefix
+func recordTarget(managed ManagedRecord, recType, value, ip string) (target string, moved bool) {
+	switch {
+	case recType != "AAAA" || ip == "":
+		return ip, false
+	case managed.IPv6Suffix != "":
+		return suffixTarget(managed.IPv6Suffix, ip), false
+	}
+	return prefixTarget(managed, value, ip)
+}
+
+// pickRecord keeps the record that already has its target value, or the
+// first one, if a name has several records of a type, and returns the
+// others
+func pickRecord(managed ManagedRecord, recType string, records []Record, ip string) (Record, []Record) {
+	if len(records) == 0 {
+		return Record{}, nil
+	}
+	keep := slices.IndexFunc(records, func(rec Record) bool {
+		target, _ := recordTarget(managed, recType, rec.Value, ip)
+		return rec.Value == target
+	})
+	keep = max(keep, 0)
+	return records[keep], slices.Delete(slices.Clone(records), keep, keep+1)
+}
+
+// dedupeChanges removes duplicate records instead of silently using
+// whichever the API returned last
+func dedupeChanges(managed ManagedRecord, zoneID, recType string, keep Record, extra []Record, verbose bool) []Change {
+	if len(extra) == 0 {
+		return nil
+	}
+	log.Printf("%s has %d duplicate %s records, keeping '%s'\n", managed.FullDomain, len(extra), recType, keep.Value)
+	var changes []Change
+	for _, rec := range extra {
+		if verbose {
+			fmt.Printf("- duplicate %s record '%s' needs dedupe for: %s\n", recType, rec.Value, managed.FullDomain)
+		}
+		changes = append(changes, Change{
+			Action:     "dedupe",
+			FullDomain: managed.FullDomain,
+			Zone:       managed.Zone,
+			ZoneID:     zoneID,
+			Name:       managed.Name,
+			Type:       recType,
+			RecordID:   rec.ID,
+			OldValue:   rec.Value,
+			OldTTL:     Seconds(rec.TTL),
+		})
+	}
+	return changes
+}
+
+// applyChanges applies the changes, via zone file import for zones with
+// at least 'zone_import.threshold' changes if enabled, else in bulk where
+// the provider supports it
+func applyChanges(changes []Change) {
+	var single []Change
+	for _, zone_changes := range groupByZone(changes) {
+		zone := zone_changes[0].Zone
+		// duplicates may have the same value, which a zone file line can't
+		// tell apart, and their record IDs must stay valid
+		dedupe := slices.ContainsFunc(zone_changes, func(change Change) bool { return change.Action == "dedupe" })
+		if config.ZoneImport.Enabled && len(zone_changes) >= zoneImportThreshold() && !dedupe && canImportZone(zone_changes[0].ZoneID) {
+			err := importChanges(zone_changes)
+			if err != nil {
+				logAndMail(fmt.Sprintf("error importing zone '%s': %s", zone, err))
+				continue
+			}
+			for _, change := range zone_changes {
+				changeApplied(change)
+			}
+			continue
+		}
+		single = append(single, zone_changes...)
+	}
+	single = applyBulk(single)
+
+	attempted := 0
+	for i, change := range single {
+		key := change.FullDomain + "/" + change.Type
+		if live.isProtected(key) {
+			log.Printf("skipping %s record of %s, it was protected at the last attempt\n", change.Type, change.FullDomain)
+			continue
+		}
+		if config.Batch.Size > 0 && attempted > 0 && attempted%config.Batch.Size == 0 {
+			pause := batchPause()
+			log.Printf("batch: %d of %d changes done, pausing %s\n", i, len(single), pause)
+			clock.Sleep(pause)
+		}
+		attempted++
+		err := applyChange(change)
+		if isProtectedError(err) {
+			skipProtected(key,
+				fmt.Sprintf("skipping %s record of %s, it is protected: %s", change.Type, change.FullDomain, err))
+			continue
+		}
+		if err != nil {
+			logAndMail(fmt.Sprintf("error %s %s record: %s", changeVerb(change.Action), change.Type, err))
+			continue
+		}
+		changeApplied(change)
+	}
+	if config.Batch.Size > 0 && attempted > config.Batch.Size {
+		log.Printf("batch: all %d changes done\n", len(single))
+	}
+}
+
+func batchPause() time.Duration {
+	if config.Batch.Pause <= 0 {
+		return defaultBatchPause * time.Second
+	}
+	return time.Duration(config.Batch.Pause) * time.Second
+}
+
+func applyChange(change Change) error {
+	switch change.Action {
+	case "create":
+		return createRecord(change.ZoneID, change.Type, change.Name, change.NewValue, change.TTL)
+	case "update":
+		return updateRecord(change.ZoneID, change.RecordID, change.Type, change.Name, change.NewValue, change.TTL)
+	case "delete", "dedupe":
+		return deleteRecord(change.RecordID)
+	}
+	return fmt.Errorf("unknown action '%s'", change.Action)
+}
+
+func changeApplied(change Change) {
+	diff := change.diff()
+	logChange(change.FullDomain, change.Action, diff.String())
+	if change.Type == "TXT" {
+		// a TXT value is no address to verify, probe or look up
+		publishChange(diff)
+		return
+	}
+	rampApplied(change)
+	queueVerification(change)
+	queueResponders(change)
+	publishChange(diff)
+	if change.Action != "delete" && change.Action != "dedupe" {
+		notifyChange(diff)
+	}
+}
+
+// publishChange keeps a change for the Atom feed and sends it as CloudEvent
+// and to the SIEM
+func publishChange(diff RecordDiff) {
+	diff.Time = clock.Now()
+	diff.RunID = live.runID()
+	live.addChange(diff)
+	sendCloudEvent(diff)
+	sendSIEMChange(diff)
+}
+
+func changeVerb(action string) string {
+	return action[:len(action)-1] + "ing"
+}
+
+// groupByZone keeps the order of the zones and of the changes within each zone
+func groupByZone(changes []Change) [][]Change {
+	var groups [][]Change
+	index := make(map[string]int)
+	for _, change := range changes {
+		i, ok := index[change.Zone]
+		if !ok {
+			i = len(groups)
+			index[change.Zone] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], change)
+	}
+	return groups
+}
+
+// printPlan summarizes the planned changes as a table after the progress
+// output of the run
+func printPlan(changes []Change) {
+	if len(changes) == 0 {
+		fmt.Println("\nno changes pending")
+		return
+	}
+	counts := make(map[string]int)
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RECORD\tTYPE\tACTION\tOLD\tNEW\tTTL")
+	for _, change := range changes {
+		counts[change.Action]++
+		ttl := "-"
+		switch {
+		case change.Action == "create" && change.TTL > 0:
+			ttl = fmt.Sprint(change.TTL)
+		case change.Action == "update" && change.TTL > 0 && change.OldTTL != change.TTL:
+			ttl = fmt.Sprintf("%d -> %d", change.OldTTL, change.TTL)
+		case change.OldTTL > 0:
+			ttl = fmt.Sprint(change.OldTTL)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", change.FullDomain, change.Type, change.Action,
+			orDash(change.OldValue), orDash(change.NewValue), ttl)
+	}
+	w.Flush()
+
+	var summary []string
+	for _, action := range []string{"create", "update", "delete", "dedupe"} {
+		if counts[action] > 0 {
+			summary = append(summary, fmt.Sprintf("%d to %s", counts[action], action))
+		}
+	}
+	fmt.Printf("\n%d changes pending: %s\n", len(changes), strings.Join(summary, ", "))
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestPickRecordSuffixTarget(t *testing.T) {
+	useConfig(t, Config{})
+	managed := ManagedRecord{FullDomain: "nas.example.com", Name: "nas", Zone: "example.com", IPv6Suffix: "::10"}
+	records := []Record{
+		{ID: "1", Type: "AAAA", Name: "nas", Value: "2001:db8::1"},
+		{ID: "2", Type: "AAAA", Name: "nas", Value: "2001:db8::10"},
+	}
+	keep, extra := pickRecord(managed, "AAAA", records, "2001:db8::5")
+	if keep.ID != "2" || len(extra) != 1 || extra[0].ID != "1" {
+		t.Errorf("kept %+v and %+v, want the record with the suffix of the host", keep, extra)
+	}
+}
+
+func TestPlanRecordKeepsDuckDNS(t *testing.T) {
+	useConfig(t, Config{Providers: map[string]ProviderConfig{"duckdns.org": {Type: "duckdns", Token: "t"}}})
+	record := Record{ID: "duckdns.org/home/AAAA/2001:db8::1", Value: "2001:db8::1"}
+	managed := ManagedRecord{FullDomain: "home.duckdns.org", Name: "home", Zone: "duckdns.org"}
+	if change := planRecord(managed, "duckdns.org", "AAAA", record, "", false); change != nil {
+		t.Errorf("planned %s of a DuckDNS record", change.Action)
+	}
+	managed = ManagedRecord{FullDomain: "home.example.com", Name: "home", Zone: "example.com"}
+	if change := planRecord(managed, "example.com", "AAAA", record, "", false); change == nil || change.Action != "delete" {
+		t.Errorf("got %+v, want a delete", change)
+	}
+}
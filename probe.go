@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const probeTimeout = 5 * time.Second
+
+// probeAddress checks that a service is reachable on a candidate address
+// before it is published, 'probe' is "tcp:<port>" or an http(s) URL which
+// is requested from ip regardless of what DNS says; probing the own public
+// address needs NAT hairpinning on most routers
+func probeAddress(probe, ip string) error {
+	dialer := &net.Dialer{Timeout: probeTimeout}
+	if port, found := strings.CutPrefix(probe, "tcp:"); found {
+		conn, err := dialer.DialContext(runContext, "tcp", net.JoinHostPort(ip, port))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	probe_url, err := url.Parse(probe)
+	if err != nil {
+		return err
+	}
+	port := probe_url.Port()
+	if port == "" {
+		port = "80"
+		if probe_url.Scheme == "https" {
+			port = "443"
+		}
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		},
+		TLSClientConfig: &tls.Config{ServerName: probe_url.Hostname()},
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   probeTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(runContext, "GET", probe, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status: %s", resp.Status)
+	}
+	return nil
+}
+
+func validateProbe(probe, failure string) error {
+	switch failure {
+	case "", "skip", "alert":
+	default:
+		return fmt.Errorf("unknown probe_failure '%s' (skip, alert)", failure)
+	}
+	if probe == "" {
+		return nil
+	}
+	if port, found := strings.CutPrefix(probe, "tcp:"); found {
+		if _, err := net.LookupPort("tcp", port); err != nil {
+			return fmt.Errorf("probe '%s': %w", probe, err)
+		}
+		return nil
+	}
+	probe_url, err := url.Parse(probe)
+	if err != nil || (probe_url.Scheme != "http" && probe_url.Scheme != "https") || probe_url.Host == "" {
+		return fmt.Errorf("probe '%s' is neither tcp:<port> nor an http(s) URL", probe)
+	}
+	return nil
+}
+
+// probeChange returns false if the change must not be applied
+func probeChange(managed ManagedRecord, change *Change) bool {
+	if managed.Probe == "" || change.Action == "delete" || change.Action == "dedupe" {
+		return true
+	}
+	err := probeAddress(managed.Probe, change.NewValue)
+	if err == nil {
+		return true
+	}
+	if managed.ProbeFailure == "alert" {
+		logAndMail(fmt.Sprintf("probe '%s' of %s on %s failed, publishing anyway: %s",
+			managed.Probe, change.FullDomain, change.NewValue, err))
+		return true
+	}
+	logAndMail(fmt.Sprintf("probe '%s' of %s on %s failed, not publishing it: %s",
+		managed.Probe, change.FullDomain, change.NewValue, err))
+	return false
+}
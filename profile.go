@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// run profiles, "auto" selects low-impact on metered connections
+const (
+	profileNormal    = "normal"
+	profileLowImpact = "low-impact"
+	profileAuto      = "auto"
+
+	defaultLowImpactInterval = 3600
+)
+
+// lowImpact is evaluated at the start of each run
+var lowImpact bool
+
+func selectProfile() {
+	was := lowImpact
+	switch config.Profile {
+	case profileLowImpact:
+		lowImpact = true
+	case profileAuto:
+		lowImpact = connectionMetered()
+	case profileNormal, "":
+		lowImpact = false
+	default:
+		log.Printf("unknown profile '%s', using '%s'\n", config.Profile, profileNormal)
+		lowImpact = false
+	}
+	if lowImpact != was {
+		log.Println("low-impact profile active:", lowImpact)
+	}
+}
+
+// connectionMetered asks NetworkManager whether the primary connection is
+// metered (NM_METERED_YES or NM_METERED_GUESS_YES)
+func connectionMetered() bool {
+	out, err := exec.Command("busctl", "get-property", "org.freedesktop.NetworkManager",
+		"/org/freedesktop/NetworkManager", "org.freedesktop.NetworkManager", "Metered").Output()
+	if err != nil {
+		return false
+	}
+	value := strings.TrimSpace(string(out))
+	return value == "u 1" || value == "u 3"
+}
+
+func lowImpactInterval() time.Duration {
+	if config.LowImpactInterval > 0 {
+		return time.Duration(config.LowImpactInterval) * time.Second
+	}
+	return defaultLowImpactInterval * time.Second
+}
+
+// getPublicIPsDNS asks the OpenDNS resolvers for the address they see,
+// a single small UDP packet per address family
+func getPublicIPsDNS() (string, string, error) {
+	ipv4, err := queryMyIP("208.67.222.222:53", dns.TypeA)
+	if err != nil {
+		return "", "", err
+	}
+	ipv6, err := queryMyIP("[2620:119:35::35]:53", dns.TypeAAAA)
+	if err != nil {
+		return ipv4, "", nil
+	}
+	return ipv4, ipv6, nil
+}
+
+func queryMyIP(server string, qtype uint16) (string, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("myip.opendns.com.", qtype)
+	var resp *dns.Msg
+	var err error
+	if config.DoH != "" {
+		// the answer depends on the address family of the connection
+		network := "tcp4"
+		if qtype == dns.TypeAAAA {
+			network = "tcp6"
+		}
+		resp, err = dohExchange(msg, openDNSDoH, network)
+		server = openDNSDoH
+	} else {
+		client := &dns.Client{Timeout: 5 * time.Second}
+		resp, _, err = client.ExchangeContext(runContext, msg, server)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range resp.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			return rr.A.String(), nil
+		case *dns.AAAA:
+			return rr.AAAA.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no address in answer from %s", server)
+}
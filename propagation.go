@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// applied changes are waited for this long to be answered by all
+// nameservers, unless 'renewal_hook.timeout' is set
+const defaultPropagationTimeout = 300
+
+const propagationPollInterval = 10 * time.Second
+
+// appliedChanges collects the changes of a run to verify their propagation
+// for the renewal hook and the latency SLO
+var appliedChanges []Change
+
+func queueVerification(change Change) {
+	if change.Action == "delete" || change.Action == "dedupe" || (!renewalHookEnabled() && !config.LatencySLO.Enabled) {
+		return
+	}
+	appliedChanges = append(appliedChanges, change)
+}
+
+// backgroundVerification is set by the daemon, its next reconcile doesn't
+// wait for the nameservers
+var backgroundVerification bool
+
+// verifyApplied waits until the nameservers answer with the new values of
+// this run's changes, the low-impact profile doesn't poll them
+func verifyApplied() {
+	changes := appliedChanges
+	appliedChanges = nil
+	if len(changes) == 0 {
+		return
+	}
+	if lowImpact {
+		log.Printf("low-impact profile, not waiting for the nameservers to answer %d changes\n", len(changes))
+		return
+	}
+	if backgroundVerification {
+		goSafe(func() { awaitPropagation(changes) })
+		return
+	}
+	awaitPropagation(changes)
+}
+
+func awaitPropagation(changes []Change) {
+	timeout := config.RenewalHook.Timeout
+	if timeout <= 0 {
+		timeout = defaultPropagationTimeout
+	}
+	deadline := clock.Now().Add(time.Duration(timeout) * time.Second)
+	for {
+		pending := unpropagated(changes)
+		if len(pending) == 0 {
+			break
+		}
+		if clock.Now().After(deadline) {
+			reportError(fmt.Sprintf("%s not answered by all nameservers after %ds", strings.Join(pending, ", "), timeout))
+			return
+		}
+		clock.Sleep(propagationPollInterval)
+	}
+	verified := clock.Now()
+
+	if config.LatencySLO.Enabled {
+		recordLatency(changes, verified)
+	}
+	if renewalHookEnabled() {
+		runRenewalHook(changes, verified)
+	}
+}
+
+// unpropagated returns the changes not yet answered with their new value
+// by every nameserver
+func unpropagated(changes []Change) []string {
+	var pending []string
+	for _, change := range changes {
+		qtype := dns.TypeA
+		if change.Type == "AAAA" {
+			qtype = dns.TypeAAAA
+		}
+		for _, server := range soaNameservers() {
+			if !answers(change.FullDomain, qtype, server, change.NewValue) {
+				pending = append(pending, change.FullDomain+"/"+change.Type)
+				break
+			}
+		}
+	}
+	return pending
+}
+
+func answers(name string, qtype uint16, server, value string) bool {
+	resp, err := queryNameserver(name, qtype, server)
+	if err != nil {
+		log.Printf("error querying %s for %s: %s\n", server, name, err)
+		return false
+	}
+	for _, rr := range resp.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			if rr.A.String() == value {
+				return true
+			}
+		case *dns.AAAA:
+			if rr.AAAA.String() == value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/railduino/hetzner-dns-update/pkg/hetznerdns"
+)
+
+// StatusError is a request answered with an unexpected HTTP status
+type StatusError = hetznerdns.StatusError
+
+// isProtectedError reports whether a write was refused because the zone or
+// record is locked or not writable with this token, retrying won't help
+func isProtectedError(err error) bool {
+	var status_err *StatusError
+	if !errors.As(err, &status_err) {
+		return false
+	}
+	return status_err.Code == http.StatusForbidden || status_err.Code == http.StatusLocked
+}
+
+// zoneProtected returns why a zone must not be modified, or ""
+func zoneProtected(zone Zone) string {
+	switch {
+	case zone.Secondary:
+		return "zone is a secondary zone, its records are transferred from the primary nameserver"
+	case zone.Paused:
+		return "zone is paused"
+	case zone.Permission != "" && zone.Permission != "write" && zone.Permission != "owner":
+		return fmt.Sprintf("token has '%s' permission only", zone.Permission)
+	}
+	return ""
+}
+
+// writableZoneID returns the ID of a zone that may be modified
+func writableZoneID(domain string) (string, error) {
+	zone, err := findZone(domain)
+	if err != nil {
+		return "", err
+	}
+	if reason := zoneProtected(zone); reason != "" {
+		return "", fmt.Errorf("zone '%s' cannot be modified: %s", zone.Name, reason)
+	}
+	return zone.ID, nil
+}
+
+// skipProtected logs the message on every run but mails it only the first
+// time, so a locked zone doesn't produce an email per daemon interval
+func skipProtected(key, message string) {
+	if live.markProtected(key) {
+		logAndMail(message)
+		return
+	}
+	log.Println(message)
+}
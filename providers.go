@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	desecAPI          = "https://desec.io/api/v1"
+	duckDNSAPI        = "https://www.duckdns.org/update"
+	duckDNSNameserver = "ns1.duckdns.org"
+	defaultDesecTTL   = 3600
+)
+
+// providerFor returns the provider of a zone, given by name or ID; zones
+// in 'providers' use their name as ID and their record IDs start with it
+func providerFor(zone string) dnsProvider {
+	provider, ok := config.Providers[zone]
+	if !ok {
+		return dnsClient()
+	}
+	switch provider.Type {
+	case "desec":
+		return desecProvider{zone: zone, token: provider.Token}
+	case "duckdns":
+		return duckDNSProvider{zone: zone, token: provider.Token}
+	}
+	// rejected by validateProviders
+	return dnsClient()
+}
+
+// recordZone returns the zone a record ID of providerFor starts with
+func recordZone(recordID string) string {
+	zone, _, _ := strings.Cut(recordID, "/")
+	return zone
+}
+
+// providerRequest calls the API of deSEC or DuckDNS, a 404 is returned as
+// *StatusError
+func providerRequest(method, endpoint string, header http.Header, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(runContext, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		op := method + " " + strings.SplitN(endpoint, "?", 2)[0]
+		return &StatusError{Op: op, Code: resp.StatusCode, Status: resp.Status}
+	}
+	if result == nil {
+		return nil
+	}
+	body = io.LimitReader(resp.Body, maxResponseSize)
+	if text, ok := result.(*string); ok {
+		data, err := io.ReadAll(body)
+		*text = string(data)
+		return err
+	}
+	return json.NewDecoder(body).Decode(result)
+}
+
+// findAddressRecords returns the A and AAAA records of a name, none if it
+// doesn't exist yet
+func findAddressRecords(p dnsProvider, zoneID, name string) ([]Record, []Record, error) {
+	var recordsA, recordsAAAA []Record
+	err := p.Records(zoneID, func(rec Record) {
+		if rec.Name == name && rec.Type == "A" {
+			recordsA = append(recordsA, rec)
+		}
+		if rec.Name == name && rec.Type == "AAAA" {
+			recordsAAAA = append(recordsAAAA, rec)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return recordsA, recordsAAAA, nil
+}
+
+// desecProvider manages the records below a zone at deSEC, e.g. the
+// names of the account under "dedyn.io"; deSEC keeps the values of a
+// name and type in one RRset, so records are changed by rewriting it.
+// Record IDs are "<zone>/<domain>/<subname>/<type>/<value>"
+type desecProvider struct {
+	zone  string
+	token string
+}
+
+type desecDomain struct {
+	Name       string `json:"name"`
+	MinimumTTL int    `json:"minimum_ttl"`
+}
+
+type desecRRset struct {
+	Subname string   `json:"subname"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl"`
+	Records []string `json:"records"`
+}
+
+func (p desecProvider) request(method, path string, payload, result any) error {
+	header := http.Header{"Authorization": {"Token " + p.token}}
+	return providerRequest(method, desecAPI+path, header, payload, result)
+}
+
+// domains returns the domains of the account at or below the zone
+func (p desecProvider) domains() ([]desecDomain, error) {
+	var all []desecDomain
+	if err := p.request("GET", "/domains/", nil, &all); err != nil {
+		return nil, err
+	}
+	var domains []desecDomain
+	for _, domain := range all {
+		if domain.Name == p.zone || strings.HasSuffix(domain.Name, "."+p.zone) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains, nil
+}
+
+func (p desecProvider) Zones(fn func(Zone)) error {
+	zone, err := p.FindZone(p.zone)
+	if err == nil {
+		fn(zone)
+	}
+	return err
+}
+
+func (p desecProvider) FindZone(name string) (Zone, error) {
+	domains, err := p.domains()
+	if err != nil {
+		return Zone{}, err
+	}
+	if len(domains) == 0 {
+		return Zone{}, fmt.Errorf("can't find domain '%s' at deSEC", name)
+	}
+	return Zone{ID: p.zone, Name: p.zone, Status: "verified", RecordsCount: len(domains)}, nil
+}
+
+func (p desecProvider) FindZoneID(name string) (string, error) {
+	zone, err := p.FindZone(name)
+	return zone.ID, err
+}
+
+func (p desecProvider) Records(zoneID string, fn func(Record)) error {
+	domains, err := p.domains()
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		var rrsets []desecRRset
+		if err := p.request("GET", "/domains/"+domain.Name+"/rrsets/", nil, &rrsets); err != nil {
+			return err
+		}
+		for _, rrset := range rrsets {
+			fullDomain := domain.Name
+			if rrset.Subname != "" {
+				fullDomain = rrset.Subname + "." + domain.Name
+			}
+			for _, value := range rrset.Records {
+				fn(Record{
+					ID:    strings.Join([]string{p.zone, domain.Name, rrset.Subname, rrset.Type, value}, "/"),
+					Type:  rrset.Type,
+					Name:  relativeName(fullDomain, p.zone),
+					Value: value,
+					TTL:   rrset.TTL,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (p desecProvider) FindRecords(zoneID, name string) ([]Record, []Record, error) {
+	return findAddressRecords(p, zoneID, name)
+}
+
+// rrset returns the RRset of a name, an empty one if there is none yet
+func (p desecProvider) rrset(domain desecDomain, subname, recType string) (desecRRset, error) {
+	var rrset desecRRset
+	err := p.request("GET", p.rrsetPath(domain.Name, subname, recType), nil, &rrset)
+	if status, ok := err.(*StatusError); ok && status.Code == http.StatusNotFound {
+		return desecRRset{Subname: subname, Type: recType, TTL: max(domain.MinimumTTL, defaultDesecTTL)}, nil
+	}
+	return rrset, err
+}
+
+func (p desecProvider) rrsetPath(domain, subname, recType string) string {
+	if subname == "" {
+		subname = "@"
+	}
+	return fmt.Sprintf("/domains/%s/rrsets/%s/%s/", domain, subname, recType)
+}
+
+// writeRRset replaces the RRset, without records it is deleted
+func (p desecProvider) writeRRset(domain string, rrset desecRRset) error {
+	if rrset.Records == nil {
+		rrset.Records = []string{}
+	}
+	return p.request("PUT", "/domains/"+domain+"/rrsets/", []desecRRset{rrset}, nil)
+}
+
+// owner returns the domain of the account a name belongs to
+func (p desecProvider) owner(fullDomain string) (desecDomain, string, error) {
+	domains, err := p.domains()
+	if err != nil {
+		return desecDomain{}, "", err
+	}
+	var found desecDomain
+	for _, domain := range domains {
+		if (fullDomain == domain.Name || strings.HasSuffix(fullDomain, "."+domain.Name)) && len(domain.Name) > len(found.Name) {
+			found = domain
+		}
+	}
+	if found.Name == "" {
+		return desecDomain{}, "", fmt.Errorf("no deSEC domain of the account contains '%s'", fullDomain)
+	}
+	return found, strings.TrimSuffix(strings.TrimSuffix(fullDomain, found.Name), "."), nil
+}
+
+func (p desecProvider) change(fullDomain, recType, oldValue, newValue string, ttl int) error {
+	domain, subname, err := p.owner(fullDomain)
+	if err != nil {
+		return err
+	}
+	rrset, err := p.rrset(domain, subname, recType)
+	if err != nil {
+		return err
+	}
+	if oldValue != "" {
+		rrset.Records = slices.DeleteFunc(rrset.Records, func(value string) bool { return value == oldValue })
+	}
+	if newValue != "" && !slices.Contains(rrset.Records, newValue) {
+		rrset.Records = append(rrset.Records, newValue)
+	}
+	if ttl > 0 {
+		rrset.TTL = max(ttl, domain.MinimumTTL)
+	}
+	return p.writeRRset(domain.Name, rrset)
+}
+
+func (p desecProvider) CreateRecord(zoneID, recType, name, value string, ttl int) error {
+	return p.change(recordName(name, p.zone), recType, "", value, ttl)
+}
+
+func (p desecProvider) UpdateRecord(zoneID, recordID, recType, name, value string, ttl int) error {
+	parts := strings.SplitN(recordID, "/", 5)
+	if len(parts) != 5 {
+		return fmt.Errorf("invalid record ID '%s'", recordID)
+	}
+	return p.change(recordName(name, p.zone), recType, parts[4], value, ttl)
+}
+
+func (p desecProvider) DeleteRecord(recordID string) error {
+	parts := strings.SplitN(recordID, "/", 5)
+	if len(parts) != 5 {
+		return fmt.Errorf("invalid record ID '%s'", recordID)
+	}
+	fullDomain := parts[1]
+	if parts[2] != "" {
+		fullDomain = parts[2] + "." + parts[1]
+	}
+	return p.change(fullDomain, parts[3], parts[4], "", 0)
+}
+
+// duckDNSProvider updates names below "duckdns.org", the API sets the
+// addresses of a name but has no way to read them, they are asked from
+// the DuckDNS nameserver. Only the configured names are listed, record IDs
+// are "<zone>/<name>/<type>/<value>"
+type duckDNSProvider struct {
+	zone  string
+	token string
+}
+
+func (p duckDNSProvider) Zones(fn func(Zone)) error {
+	zone, err := p.FindZone(p.zone)
+	if err == nil {
+		fn(zone)
+	}
+	return err
+}
+
+func (p duckDNSProvider) FindZone(name string) (Zone, error) {
+	return Zone{ID: p.zone, Name: p.zone, Status: "verified"}, nil
+}
+
+func (p duckDNSProvider) FindZoneID(name string) (string, error) {
+	return p.zone, nil
+}
+
+func (p duckDNSProvider) Records(zoneID string, fn func(Record)) error {
+	for _, fullDomain := range configuredRecords() {
+		name, zone, _ := strings.Cut(fullDomain, ".")
+		if zone != p.zone {
+			continue
+		}
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			resp, err := queryNameserver(fullDomain, qtype, duckDNSNameserver)
+			if err != nil {
+				return err
+			}
+			for _, rr := range resp.Answer {
+				value := ""
+				switch rr := rr.(type) {
+				case *dns.A:
+					value = rr.A.String()
+				case *dns.AAAA:
+					value = rr.AAAA.String()
+				default:
+					continue
+				}
+				recType := dns.TypeToString[qtype]
+				fn(Record{
+					ID:    strings.Join([]string{p.zone, name, recType, value}, "/"),
+					Type:  recType,
+					Name:  name,
+					Value: value,
+					TTL:   int(rr.Header().Ttl),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func (p duckDNSProvider) FindRecords(zoneID, name string) ([]Record, []Record, error) {
+	return findAddressRecords(p, zoneID, name)
+}
+
+// update sets one address of a name; without 'ip' DuckDNS would take the
+// IPv4 address the request comes from, so an IPv6 update passes the
+// current IPv4 address along
+func (p duckDNSProvider) update(name, recType, value string) error {
+	query := url.Values{"domains": {name}, "token": {p.token}}
+	switch recType {
+	case "A":
+		query.Set("ip", value)
+	case "AAAA":
+		query.Set("ipv6", value)
+		recordsA, _, err := p.FindRecords(p.zone, name)
+		if err == nil && len(recordsA) > 0 {
+			query.Set("ip", recordsA[0].Value)
+		}
+	default:
+		return fmt.Errorf("DuckDNS only supports A and AAAA records, not %s", recType)
+	}
+	var reply string
+	if err := providerRequest("GET", duckDNSAPI+"?"+query.Encode(), nil, nil, &reply); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(reply, "OK") {
+		return fmt.Errorf("DuckDNS rejected the update of '%s': %s", name, strings.TrimSpace(reply))
+	}
+	return nil
+}
+
+func (p duckDNSProvider) CreateRecord(zoneID, recType, name, value string, ttl int) error {
+	return p.update(name, recType, value)
+}
+
+func (p duckDNSProvider) UpdateRecord(zoneID, recordID, recType, name, value string, ttl int) error {
+	return p.update(name, recType, value)
+}
+
+// deletesRecords is false for DuckDNS zones: the API only clears both
+// addresses of a name at once, so records are never planned for deletion
+func deletesRecords(zone string) bool {
+	return config.Providers[zone].Type != "duckdns"
+}
+
+func (p duckDNSProvider) DeleteRecord(recordID string) error {
+	return fmt.Errorf("DuckDNS can't delete a single record (%s)", recordID)
+}
+
+func validateProviders() error {
+	for zone, provider := range config.Providers {
+		switch provider.Type {
+		case "desec", "duckdns":
+		default:
+			return fmt.Errorf("providers '%s': unknown type '%s' (desec, duckdns)", zone, provider.Type)
+		}
+		if provider.Token == "" {
+			return fmt.Errorf("providers '%s': missing token", zone)
+		}
+	}
+	return nil
+}
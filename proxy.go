@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// default request timeouts in seconds
+const (
+	defaultAPITimeout       = 30
+	defaultDetectionTimeout = 10
+)
+
+var (
+	proxyMu      sync.Mutex
+	proxyClients = make(map[string]*http.Client)
+)
+
+// apiClient is used for Hetzner API calls and other outgoing requests
+func apiClient() *http.Client {
+	return wrapClient(proxyClient(config.Proxy.API), requestTimeout(config.Timeouts.API, defaultAPITimeout))
+}
+
+// detectionClient is used for HTTP based IP detection, DNS based
+// detection (low-impact profile) is not proxied
+func detectionClient() *http.Client {
+	return wrapClient(proxyClient(config.Proxy.Detection), requestTimeout(config.Timeouts.Detection, defaultDetectionTimeout))
+}
+
+func requestTimeout(timeout Seconds, fallback int) time.Duration {
+	if timeout <= 0 {
+		timeout = Seconds(fallback)
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// getURL is http.Get with the client and runContext
+func getURL(client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(runContext, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// wrapClient adds the timeout, the request budget of the Hetzner API, the
+// API cache of the daemon, fault injection and snapshot recording or
+// replay
+func wrapClient(client *http.Client, timeout time.Duration) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	next = limitTransport{next}
+	if apiCacheEnabled() {
+		next = cacheTransport{next}
+	}
+	if chaosEnabled() {
+		next = chaosTransport{next}
+	}
+	if snapshotEnabled() {
+		next = snapshotTransport{next}
+	}
+	return &http.Client{Transport: next, Timeout: timeout}
+}
+
+func proxyClient(proxy string) *http.Client {
+	if proxy == "" && config.DoH == "" {
+		return &http.Client{}
+	}
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	if client, ok := proxyClients[proxy]; ok {
+		return client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		// rejected by validateProxy if invalid
+		proxy_url, _ := url.Parse(proxy)
+		transport.Proxy = http.ProxyURL(proxy_url)
+	}
+	if config.DoH != "" {
+		transport.DialContext = dohDialContext
+	}
+	client := &http.Client{Transport: transport}
+	proxyClients[proxy] = client
+	return client
+}
+
+func validateProxy() error {
+	for key, proxy := range map[string]string{"api": config.Proxy.API, "detection": config.Proxy.Detection} {
+		if proxy == "" {
+			continue
+		}
+		proxy_url, err := url.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("proxy '%s': %w", key, err)
+		}
+		switch proxy_url.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("proxy '%s': unsupported scheme '%s' (http, https, socks5)", key, proxy_url.Scheme)
+		}
+		if proxy_url.Host == "" {
+			return fmt.Errorf("proxy '%s': missing host in '%s'", key, proxy)
+		}
+	}
+	return nil
+}
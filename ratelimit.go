@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// default request budget for the Hetzner API
+const (
+	defaultRequestsPerSecond = 5
+	defaultBurst             = 10
+)
+
+// tokenBucket is shared by all clients, zones and goroutines of the
+// process: a request takes a token, tokens are refilled at 'rate' per
+// second up to 'burst'
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+var apiBudget tokenBucket
+
+func rateLimit() (float64, float64) {
+	rate, burst := config.RateLimit.RequestsPerSecond, config.RateLimit.Burst
+	if rate <= 0 {
+		rate = defaultRequestsPerSecond
+	}
+	if burst <= 0 {
+		burst = defaultBurst
+	}
+	return rate, float64(burst)
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (b *tokenBucket) reserve() time.Duration {
+	rate, burst := rateLimit()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := clock.Now()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*rate, burst)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// cancel returns a token that was reserved but not used
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
+
+// limitTransport holds requests to the Hetzner API back until the budget
+// has a token for them
+type limitTransport struct {
+	next http.RoundTripper
+}
+
+func (t limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	api, _ := url.Parse(hetznerAPI)
+	if req.URL.Host != api.Host {
+		return t.next.RoundTrip(req)
+	}
+	if wait := apiBudget.reserve(); wait > 0 {
+		timer := clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-req.Context().Done():
+			timer.Stop()
+			apiBudget.cancel()
+			return nil, req.Context().Err()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	useConfig(t, Config{RateLimit: RateLimitConfig{RequestsPerSecond: 5, Burst: 2}})
+	m := useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var b tokenBucket
+	for i := range 2 {
+		if wait := b.reserve(); wait != 0 {
+			t.Fatalf("request %d of the burst waits %s", i+1, wait)
+		}
+	}
+	if wait := b.reserve(); wait != 200*time.Millisecond {
+		t.Errorf("request after the burst waits %s, want 200ms", wait)
+	}
+	b.cancel()
+
+	m.Advance(time.Second)
+	for i := range 2 {
+		if wait := b.reserve(); wait != 0 {
+			t.Errorf("request %d after the refill waits %s", i+1, wait)
+		}
+	}
+}
+
+type okTransport struct{}
+
+func (okTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+}
+
+func TestLimitTransportWaits(t *testing.T) {
+	useConfig(t, Config{RateLimit: RateLimitConfig{RequestsPerSecond: 1, Burst: 1}})
+	m := useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	reset := func() {
+		apiBudget.mu.Lock()
+		defer apiBudget.mu.Unlock()
+		apiBudget.tokens, apiBudget.last = 0, time.Time{}
+	}
+	reset()
+	t.Cleanup(reset)
+
+	transport := limitTransport{okTransport{}}
+	req, _ := http.NewRequest("GET", hetznerAPI+"/zones", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		transport.RoundTrip(req)
+		close(done)
+	}()
+	// wait until the request is held back by its timer
+	for {
+		m.mu.Lock()
+		waiting := len(m.waiters)
+		m.mu.Unlock()
+		if waiting > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("request was sent without a token")
+	default:
+	}
+	m.Advance(time.Second)
+	<-done
+}
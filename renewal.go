@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+func renewalHookEnabled() bool {
+	return config.RenewalHook.Command != "" || config.RenewalHook.FlagFile != ""
+}
+
+func renewalRecords() []string {
+	if len(config.RenewalHook.Records) == 0 {
+		return []string{"*"}
+	}
+	return config.RenewalHook.Records
+}
+
+// runRenewalHook runs the command and touches the flag file once the
+// changes are answered by the nameservers, possibly after the run
+func runRenewalHook(changes []Change, verified time.Time) {
+	changes = slices.DeleteFunc(changes, func(change Change) bool {
+		return !matchesAny(change.FullDomain, renewalRecords())
+	})
+	if len(changes) == 0 {
+		return
+	}
+
+	var records []string
+	for _, change := range changes {
+		records = append(records, change.FullDomain+"/"+change.Type)
+	}
+	log.Printf("renewal hook: %s verified at %s\n", strings.Join(records, ", "), verified.Format(time.RFC3339))
+
+	if config.RenewalHook.FlagFile != "" {
+		err := os.WriteFile(config.RenewalHook.FlagFile, []byte(verified.Format(time.RFC3339)+"\n"), 0644)
+		if err != nil {
+			reportError("error writing renewal flag file: " + err.Error())
+		}
+	}
+	if config.RenewalHook.Command != "" {
+		cmd := exec.Command("/bin/sh", "-c", config.RenewalHook.Command)
+		cmd.Env = hookEnviron(
+			hookEnvPrefix+"RECORDS="+strings.Join(records, " "),
+			hookEnvPrefix+"VERIFIED_AT="+verified.Format(time.RFC3339),
+			fmt.Sprintf("%sVERIFIED_UNIX=%d", hookEnvPrefix, verified.Unix()),
+		)
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			log.Printf("renewal hook output:\n%s", out)
+		}
+		if err != nil {
+			reportError("error running renewal hook: " + err.Error())
+		}
+	}
+}
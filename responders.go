@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// ResponderCheck is the result of an 'expect' check against the address
+// a record was changed to
+type ResponderCheck struct {
+	Check string    `json:"check"`
+	IP    string    `json:"ip"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// respondersPending collects the changes of a run with 'expect' checks
+var respondersPending []Change
+
+func queueResponders(change Change) {
+	if change.Action != "create" && change.Action != "update" {
+		return
+	}
+	if override, ok := overrideFor(change.FullDomain); ok && len(override.Expect) > 0 {
+		respondersPending = append(respondersPending, change)
+	}
+}
+
+// checkResponders runs the 'expect' checks of the records changed in this
+// run against their new address, failures are reported but nothing is
+// rolled back
+func checkResponders() {
+	changes := respondersPending
+	respondersPending = nil
+	for _, change := range changes {
+		override, _ := overrideFor(change.FullDomain)
+		var results []ResponderCheck
+		for _, check := range override.Expect {
+			result := ResponderCheck{Check: check, IP: change.NewValue, OK: true, Time: clock.Now()}
+			if err := checkResponder(check, change.FullDomain, change.NewValue); err != nil {
+				result.OK, result.Error = false, err.Error()
+				logAndMail(fmt.Sprintf("%s record of %s changed to %s, but '%s' failed: %s",
+					change.Type, change.FullDomain, change.NewValue, check, err))
+			} else {
+				log.Printf("%s on %s: '%s' ok\n", change.FullDomain, change.NewValue, check)
+			}
+			results = append(results, result)
+		}
+		live.setResponders(change.FullDomain, results)
+	}
+}
+
+// checkResponder runs one check: an http(s) URL or 'tcp:<port>' as for
+// 'probe', 'tls:<port>' for a certificate valid for the record, or
+// 'ssh:<port>' for an SSH banner
+func checkResponder(check, fullDomain, ip string) error {
+	if port, found := strings.CutPrefix(check, "tls:"); found {
+		return checkTLS(fullDomain, ip, port)
+	}
+	if port, found := strings.CutPrefix(check, "ssh:"); found {
+		return checkSSH(ip, port)
+	}
+	return probeAddress(check, ip)
+}
+
+func checkTLS(fullDomain, ip, port string) error {
+	dialer := &net.Dialer{Timeout: probeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, port), &tls.Config{ServerName: fullDomain})
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkSSH(ip, port string) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, port), probeTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+	banner, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no SSH banner: %w", err)
+	}
+	if !strings.HasPrefix(banner, "SSH-2.0-") {
+		return fmt.Errorf("unexpected banner '%.40s'", strings.TrimSpace(banner))
+	}
+	return nil
+}
+
+func validateExpect(checks []string) error {
+	for _, check := range checks {
+		for _, prefix := range []string{"tls:", "ssh:"} {
+			if port, found := strings.CutPrefix(check, prefix); found {
+				if _, err := net.LookupPort("tcp", port); err != nil {
+					return fmt.Errorf("expect '%s': %w", check, err)
+				}
+				check = ""
+			}
+		}
+		if check == "" {
+			continue
+		}
+		if err := validateProbe(check, ""); err != nil {
+			return fmt.Errorf("expect: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryAttempts = 4
+	defaultRetryMaxDelay = 30
+	retryBaseDelay       = time.Second
+)
+
+// retryDelay returns how long to wait before retrying a failed API call,
+// false if the error won't go away by retrying: a 429 waits as long as
+// Retry-After asks, 5xx and network errors back off exponentially with
+// jitter; nothing is retried once the run is interrupted
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	if interrupted() {
+		return 0, false
+	}
+	maxDelay := time.Duration(config.Retry.MaxDelay) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay * time.Second
+	}
+	backoff := min(retryBaseDelay<<attempt, maxDelay)
+
+	var status *StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &status):
+		if status.Code != http.StatusTooManyRequests && status.Code < 500 {
+			return 0, false
+		}
+		if status.RetryAfter > 0 {
+			return status.RetryAfter, status.RetryAfter <= maxDelay
+		}
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF):
+	default:
+		return 0, false
+	}
+	return backoff/2 + random.Duration(backoff/2+1), true
+}
+
+// withRetry calls an API function until it succeeds, fails for good or
+// 'retry.attempts' are used up
+func withRetry(op string, fn func() error) error {
+	attempts := config.Retry.Attempts
+	if attempts <= 0 {
+		attempts = defaultRetryAttempts
+	}
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt+1 >= attempts {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (gave up after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+		delay, ok := retryDelay(err, attempt)
+		if !ok {
+			return err
+		}
+		log.Printf("%s failed, retrying in %s: %s\n", op, delay.Round(time.Millisecond), err)
+		clock.Sleep(delay)
+	}
+}
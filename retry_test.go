@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithRetryBackoff(t *testing.T) {
+	useConfig(t, Config{})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := useManualClock(t, start)
+
+	calls := 0
+	err := withRetry("test", func() error {
+		calls++
+		if calls < 4 {
+			return &StatusError{Code: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	if err != nil || calls != 4 {
+		t.Fatalf("got %v after %d calls, want success after 4", err, calls)
+	}
+	// half of 1s, 2s and 4s without jitter
+	if waited := m.Now().Sub(start); waited != 3500*time.Millisecond {
+		t.Errorf("backed off %s, want 3.5s", waited)
+	}
+}
+
+func TestWithRetryAfter(t *testing.T) {
+	useConfig(t, Config{})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := useManualClock(t, start)
+
+	calls := 0
+	withRetry("test", func() error {
+		calls++
+		if calls == 1 {
+			return &StatusError{Code: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}
+		}
+		return nil
+	})
+	if waited := m.Now().Sub(start); waited != 7*time.Second {
+		t.Errorf("waited %s, want the 7s of Retry-After", waited)
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	useConfig(t, Config{Retry: RetryConfig{Attempts: 2}})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := useManualClock(t, start)
+
+	calls := 0
+	notFound := &StatusError{Code: http.StatusNotFound}
+	err := withRetry("test", func() error {
+		calls++
+		return notFound
+	})
+	if !errors.Is(err, notFound) || calls != 1 || !m.Now().Equal(start) {
+		t.Errorf("a 404 was retried: %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = withRetry("test", func() error {
+		calls++
+		return &StatusError{Code: http.StatusBadGateway}
+	})
+	if err == nil || calls != 2 {
+		t.Errorf("got %v after %d calls, want failure after 2", err, calls)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result any             `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type rpcPlan struct {
+	IPv4    string       `json:"ipv4"`
+	IPv6    string       `json:"ipv6"`
+	Changes []RecordDiff `json:"changes"`
+	Errors  int          `json:"errors"`
+
+	Skipped []SkippedZone `json:"skipped,omitempty"`
+}
+
+type rpcApply struct {
+	OK      bool         `json:"ok"`
+	Changes []RecordDiff `json:"changes"`
+	Errors  int          `json:"errors"`
+}
+
+type rpcStatus struct {
+	RunStatus
+	Records map[string]RecordStatus `json:"records"`
+}
+
+// runJSONRPC reads one request per line from stdin and writes one response
+// per line to stdout, e.g. {"id": 1, "method": "plan"}; the methods are
+// 'plan', 'apply' and 'status'
+func runJSONRPC(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var req rpcRequest
+		var resp rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = "invalid request: " + err.Error()
+		} else {
+			resp.ID = req.ID
+			resp.Result, err = rpcCall(req.Method)
+			if err != nil {
+				resp.Error = err.Error()
+			}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func rpcCall(method string) (any, error) {
+	switch method {
+	case "plan":
+		runErrors = 0
+		selectProfile()
+		if err := loadDesiredState(); err != nil {
+			return nil, fmt.Errorf("error loading desired state: %w", err)
+		}
+		ipv4, ipv6, err := detectIPs()
+		if err != nil {
+			return nil, fmt.Errorf("error getting current public IP: %w", err)
+		}
+		_, changes, _ := planRun(ipv4, ipv6, runOptions{skip: disabledRecords()})
+		var diffs []RecordDiff
+		for _, change := range changes {
+			diffs = append(diffs, change.diff())
+		}
+		return rpcPlan{ipv4, ipv6, diffs, runErrors, runSkipped}, nil
+	case "apply":
+		if config.ReadOnly {
+			return nil, errReadOnly
+		}
+		// no verbose or CheckMK output, stdout belongs to the protocol
+		start := clock.Now()
+		ok := runOnce(runOptions{update: true})
+		var applied []RecordDiff
+		for _, change := range live.changeList() {
+			if !change.Time.Before(start) {
+				applied = append(applied, change)
+			}
+		}
+		return rpcApply{ok && runErrors == 0, applied, runErrors}, nil
+	case "status":
+		return rpcStatus{live.status(), live.recordList()}, nil
+	}
+	return nil, fmt.Errorf("unknown method '%s'", method)
+}
+
+func serveJSONRPC() {
+	if err := runJSONRPC(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error in json-rpc mode:", err)
+		os.Exit(1)
+	}
+}
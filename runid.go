@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// newRunID returns a random ID for a reconciliation, it is attached to the
+// log lines, changes, errors and notifications of the run so they can be
+// found together later
+func newRunID() string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// setRunID starts or, with "", ends a run; text log lines get the ID
+// after their timestamp, JSON ones as 'run_id'
+func setRunID(id string) {
+	live.setRunID(id)
+	if jsonLogging() {
+		return
+	}
+	if id == "" {
+		log.SetPrefix("")
+		return
+	}
+	log.SetFlags(log.Flags() | log.Lmsgprefix)
+	log.SetPrefix("[" + id + "] ")
+}
+
+// runIDLine is appended to notifications, empty outside of a run
+func runIDLine() string {
+	id := live.runID()
+	if id == "" {
+		return ""
+	}
+	return "\r\nrun " + id + "\r\n"
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleSearch bounds the search for the next match of an expression
+// like '0 0 31 2 *' which never matches
+const maxScheduleSearch = 4 * 366 * 24 * time.Hour
+
+// next returns the start of the next window of the expression after t,
+// i.e. a matching minute whose previous minute doesn't match
+func (c cronExpr) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	previous := c.matches(t)
+	for end := t.Add(maxScheduleSearch); t.Before(end); {
+		t = t.Add(time.Minute)
+		matches := c.matches(t)
+		if matches && !previous {
+			return t, true
+		}
+		previous = matches
+	}
+	return time.Time{}, false
+}
+
+// describe lists the values of each field, e.g. 'hour 2-4'
+func (c cronExpr) describe() string {
+	fields := []struct {
+		name     string
+		bits     uint64
+		min, max int
+		any      bool
+	}{
+		{"minute", c.minute, 0, 59, false},
+		{"hour", c.hour, 0, 23, false},
+		{"day", c.dom, 1, 31, c.domAny},
+		{"month", c.month, 1, 12, false},
+		{"weekday", c.dow &^ (1 << 7), 0, 6, c.dowAny},
+	}
+	var parts []string
+	for _, field := range fields {
+		all := uint64(1)<<(field.max+1) - uint64(1)<<field.min
+		if field.any || field.bits == all {
+			parts = append(parts, field.name+" *")
+			continue
+		}
+		parts = append(parts, field.name+" "+bitRanges(field.bits))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// bitRanges formats a bit set as '1-5,7'
+func bitRanges(set uint64) string {
+	var ranges []string
+	for set != 0 {
+		low := bits.TrailingZeros64(set)
+		high := low
+		for high < 63 && set&(1<<(high+1)) != 0 {
+			high++
+		}
+		if low == high {
+			ranges = append(ranges, strconv.Itoa(low))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", low, high))
+		}
+		set &^= (uint64(1)<<(high+1) - 1) &^ (uint64(1)<<low - 1)
+	}
+	return strings.Join(ranges, ",")
+}
+
+func scheduleTime(t time.Time) string {
+	return fmt.Sprintf("%s  (%s)", t.Local().Format("Mon 2006-01-02 15:04 MST"), t.UTC().Format("2006-01-02 15:04 UTC"))
+}
+
+// runSchedule handles 'schedule show [-n count]', it prints the parsed
+// change windows and quiet hours with the next times they open, and the
+// next runs of the daemon
+func runSchedule(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: schedule show [-n count]")
+	}
+	flags := flag.NewFlagSet("schedule show", flag.ContinueOnError)
+	count := flags.Int("n", 5, "number of times to show")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	now := clock.Now()
+
+	if interval := daemonInterval(); interval > 0 {
+		fmt.Printf("daemon: every %s\n", interval)
+		next := live.status().NextRun
+		if next.Before(now) {
+			next = now
+		}
+		for i := range *count {
+			fmt.Println("  run", scheduleTime(next.Add(time.Duration(i)*interval)))
+		}
+	}
+
+	schedules := []struct {
+		name    string
+		windows []string
+	}{{"change_windows", config.ChangeWindows}}
+	for channel, windows := range config.Notifications.QuietHours {
+		schedules = append(schedules, struct {
+			name    string
+			windows []string
+		}{"quiet_hours " + channel, windows})
+	}
+	for _, schedule := range schedules {
+		if len(schedule.windows) == 0 {
+			continue
+		}
+		fmt.Printf("%s:\n", schedule.name)
+		for _, window := range schedule.windows {
+			c, err := parseCron(window)
+			if err != nil {
+				return fmt.Errorf("%s: %w", schedule.name, err)
+			}
+			fmt.Printf("  '%s': %s\n", window, c.describe())
+			if c.matches(now) {
+				fmt.Println("    open now")
+			}
+			t := now
+			for range *count {
+				var ok bool
+				if t, ok = c.next(t); !ok {
+					fmt.Println("    never opens")
+					break
+				}
+				fmt.Println("    opens", scheduleTime(t))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	siemTimeout = 10 * time.Second
+
+	// example enterprise number of RFC 5612, set 'enterprise_id' to the
+	// own one if the SIEM requires it
+	defaultEnterpriseID = 32473
+
+	// syslog facility daemon, severities notice and err
+	syslogFacility = 3
+	syslogNotice   = 5
+	syslogError    = 3
+)
+
+// siemEvent is a change or an error in both export formats
+type siemEvent struct {
+	id       string
+	name     string
+	severity int
+	fields   [][2]string
+	message  string
+}
+
+func sendSIEMChange(diff RecordDiff) {
+	sendSIEMEvent(siemEvent{
+		id:       "record-" + diff.Action,
+		name:     fmt.Sprintf("DNS %s record %sd", diff.Type, diff.Action),
+		severity: syslogNotice,
+		fields: [][2]string{
+			{"record", diff.Record},
+			{"type", diff.Type},
+			{"action", diff.Action},
+			{"old", diff.OldValue},
+			{"new", diff.NewValue},
+			{"run", diff.RunID},
+		},
+		message: diff.String(),
+	})
+}
+
+func sendSIEMError(message string) {
+	sendSIEMEvent(siemEvent{
+		id:       "error",
+		name:     "DNS update error",
+		severity: syslogError,
+		fields:   [][2]string{{"run", live.runID()}},
+		message:  message,
+	})
+}
+
+// sendSIEMEvent sends a syslog message with the event as CEF or as RFC 5424
+// structured data, over UDP or with octet counting over TCP and TLS
+func sendSIEMEvent(event siemEvent) {
+	if config.SIEM.Address == "" {
+		return
+	}
+	hostname, _ := os.Hostname()
+	header := fmt.Sprintf("<%d>1 %s %s hetzner-dns-update %d %s",
+		syslogFacility*8+event.severity, clock.Now().UTC().Format(time.RFC3339), hostname, os.Getpid(), event.id)
+	var msg string
+	if config.SIEM.Format == "cef" {
+		msg = header + " - " + cefMessage(event)
+	} else {
+		msg = header + " " + structuredData(event) + " " + event.message
+	}
+
+	if err := writeSIEM(scrubSecrets(msg)); err != nil {
+		log.Println("error sending SIEM event:", err)
+	}
+}
+
+func writeSIEM(msg string) error {
+	dialer := &net.Dialer{Timeout: siemTimeout}
+	var conn net.Conn
+	var err error
+	switch config.SIEM.Protocol {
+	case "tls":
+		conn, err = tls.DialWithDialer(dialer, "tcp", config.SIEM.Address, &tls.Config{})
+	case "tcp":
+		conn, err = dialer.Dial("tcp", config.SIEM.Address)
+	default:
+		conn, err = dialer.Dial("udp", config.SIEM.Address)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(siemTimeout))
+	if config.SIEM.Protocol == "tcp" || config.SIEM.Protocol == "tls" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	_, err = conn.Write([]byte(msg))
+	return err
+}
+
+// cefMessage formats an ArcSight Common Event Format record
+func cefMessage(event siemEvent) string {
+	version := "devel"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	severity := 3
+	if event.severity == syslogError {
+		severity = 7
+	}
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	extension := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+	ext := []string{"dvchost=" + extension.Replace(instanceName()), "msg=" + extension.Replace(event.message)}
+	for i, field := range event.fields {
+		if i >= 6 {
+			break
+		}
+		ext = append(ext, fmt.Sprintf("cs%dLabel=%s cs%d=%s", i+1, field[0], i+1, extension.Replace(field[1])))
+	}
+	return fmt.Sprintf("CEF:0|railduino|hetzner-dns-update|%s|%s|%s|%d|%s",
+		header.Replace(version), header.Replace(event.id), header.Replace(event.name), severity, strings.Join(ext, " "))
+}
+
+// structuredData formats the fields as one RFC 5424 SD-ELEMENT
+func structuredData(event siemEvent) string {
+	id := config.SIEM.EnterpriseID
+	if id <= 0 {
+		id = defaultEnterpriseID
+	}
+	value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	var b strings.Builder
+	fmt.Fprintf(&b, `[dns@%d instance="%s"`, id, value.Replace(instanceName()))
+	for _, field := range event.fields {
+		fmt.Fprintf(&b, ` %s="%s"`, field[0], value.Replace(field[1]))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+func validateSIEM() error {
+	switch config.SIEM.Protocol {
+	case "", "udp", "tcp", "tls":
+	default:
+		return fmt.Errorf("siem: unknown protocol '%s' (udp, tcp, tls)", config.SIEM.Protocol)
+	}
+	switch config.SIEM.Format {
+	case "", "rfc5424", "cef":
+	default:
+		return fmt.Errorf("siem: unknown format '%s' (rfc5424, cef)", config.SIEM.Format)
+	}
+	return nil
+}
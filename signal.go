@@ -0,0 +1,69 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// sendSignal delivers a message via signal-cli, through its JSON-RPC HTTP
+// endpoint ('signal-cli daemon --http') if 'rpc_url' is set or by running
+// the command otherwise
+func sendSignal(subject, body string) error {
+	message := subject + "\n\n" + body
+	if config.Signal.RPCURL != "" {
+		return signalRPC(message)
+	}
+
+	command := config.Signal.Command
+	if command == "" {
+		command = "signal-cli"
+	}
+	args := append([]string{"-a", config.Signal.Account, "send", "-m", message}, config.Signal.Recipients...)
+	out, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func signalRPC(message string) error {
+	request, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "send",
+		"id":      1,
+		"params": map[string]any{
+			"account":   config.Signal.Account,
+			"recipient": config.Signal.Recipients,
+			"message":   message,
+		},
+	})
+	req, err := http.NewRequestWithContext(runContext, "POST", config.Signal.RPCURL, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("signal-cli status %s: %w", resp.Status, err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("signal-cli: %s", reply.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// signingKey decodes 'change_signing.key', the base64 ed25519 seed or
+// private key
+func signingKey() (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(config.ChangeSigning.Key)
+	if err != nil {
+		return nil, fmt.Errorf("change_signing: key: %w", err)
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	}
+	return nil, fmt.Errorf("change_signing: key has %d bytes, expected an ed25519 seed (%d) or private key (%d)",
+		len(key), ed25519.SeedSize, ed25519.PrivateKeySize)
+}
+
+// verifyKey is 'public_key' if set, so a trail can be verified on a host
+// without the private key
+func verifyKey() (ed25519.PublicKey, error) {
+	if config.ChangeSigning.PublicKey == "" {
+		if config.ChangeSigning.Key == "" {
+			return nil, errors.New("change_signing: neither 'key' nor 'public_key' is set")
+		}
+		key, err := signingKey()
+		if err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(config.ChangeSigning.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("change_signing: public_key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("change_signing: public_key has %d bytes, expected %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// SigningAnchor is kept in the state from the first signed change on: the
+// key, the number of signed changes and the signature of the latest one,
+// a history stripped of its signatures or cut short no longer matches it
+type SigningAnchor struct {
+	First time.Time `json:"first"`
+	Key   string    `json:"key"`
+	Count int       `json:"count"`
+	Last  string    `json:"last"`
+}
+
+// signedPayload covers the change, its sequence number and the signature
+// of the change before it, removing or reordering entries breaks the chain
+func signedPayload(diff RecordDiff) []byte {
+	diff.Signature = ""
+	payload, _ := json.Marshal(diff)
+	return payload
+}
+
+// signChange links the change to the previous one, numbers and signs it
+// and advances the anchor, nothing is done without a key
+func signChange(diff RecordDiff, anchor *SigningAnchor) RecordDiff {
+	if config.ChangeSigning.Key == "" {
+		return diff
+	}
+	key, err := signingKey()
+	if err != nil {
+		// rejected by validateChangeSigning
+		return diff
+	}
+	if anchor.Count == 0 {
+		anchor.First = diff.Time
+		anchor.Key = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	}
+	diff.Sequence = anchor.Count + 1
+	diff.Previous = anchor.Last
+	diff.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, signedPayload(diff)))
+	anchor.Count, anchor.Last = diff.Sequence, diff.Signature
+	return diff
+}
+
+// verifyHistory checks the signatures and the chain of the kept changes
+// against the anchor, changes before the first signed one were made before
+// signing was enabled; only a full history may have lost its oldest
+// signed changes
+func verifyHistory(changes []RecordDiff, anchor SigningAnchor) (int, []string, error) {
+	key, err := verifyKey()
+	if err != nil {
+		return 0, nil, err
+	}
+	var problems []string
+	if anchor.Count > 0 && anchor.Key != base64.StdEncoding.EncodeToString(key) {
+		problems = append(problems, fmt.Sprintf("the chain started at %s was signed with another key (%s)",
+			anchor.First.Format("2006-01-02 15:04:05"), anchor.Key))
+	}
+	signed, unsigned := 0, 0
+	var last RecordDiff
+	for i, diff := range changes {
+		entry := fmt.Sprintf("change %d (%s %s of %s at %s)", i+1, diff.Type, diff.Action, diff.Record, diff.Time.Format("2006-01-02 15:04:05"))
+		if diff.Signature == "" {
+			if signed > 0 {
+				problems = append(problems, entry+": signature missing")
+			} else {
+				unsigned++
+			}
+			continue
+		}
+		signed++
+		signature, err := base64.StdEncoding.DecodeString(diff.Signature)
+		if err != nil || !ed25519.Verify(key, signedPayload(diff), signature) {
+			problems = append(problems, entry+": invalid signature")
+			continue
+		}
+		if signed == 1 && diff.Sequence > 1 && (unsigned > 0 || len(changes) < maxChanges) {
+			problems = append(problems, fmt.Sprintf("%s: the %d signed changes before it are missing", entry, diff.Sequence-1))
+		}
+		// the first kept change may link to one dropped from the history
+		if i > 0 && diff.Previous != changes[i-1].Signature {
+			problems = append(problems, entry+": does not follow the change before it")
+		}
+		last = diff
+	}
+	switch {
+	case anchor.Count == 0 && signed > 0:
+		problems = append(problems, "the state has no anchor for the signed changes")
+	case anchor.Count > 0 && signed == 0:
+		problems = append(problems, fmt.Sprintf("all %d signed changes since %s are missing",
+			anchor.Count, anchor.First.Format("2006-01-02 15:04:05")))
+	case anchor.Count > 0 && (last.Sequence != anchor.Count || last.Signature != anchor.Last):
+		problems = append(problems, fmt.Sprintf("the latest signed changes are missing, %d were made but the history ends with change %d",
+			anchor.Count, last.Sequence))
+	}
+	return unsigned, problems, nil
+}
+
+// runVerifyHistory prints the result of verifyHistory for the stored state
+func runVerifyHistory() bool {
+	changes := live.changeList()
+	unsigned, problems, err := verifyHistory(changes, live.signingAnchor())
+	if err != nil {
+		fmt.Println("error:", err)
+		return false
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if unsigned > 0 {
+		fmt.Printf("%d changes from before signing was enabled are not signed\n", unsigned)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d of %d changes failed verification\n", len(problems), len(changes))
+		return false
+	}
+	fmt.Printf("%d signed changes verified\n", len(changes)-unsigned)
+	return true
+}
+
+func validateChangeSigning() error {
+	if config.ChangeSigning.Key != "" {
+		if _, err := signingKey(); err != nil {
+			return err
+		}
+	}
+	if config.ChangeSigning.PublicKey != "" {
+		if _, err := verifyKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func signedHistory(t *testing.T, n int) ([]RecordDiff, SigningAnchor) {
+	t.Helper()
+	seed := make([]byte, 32)
+	useConfig(t, Config{ChangeSigning: ChangeSigningConfig{Key: base64.StdEncoding.EncodeToString(seed)}})
+	var anchor SigningAnchor
+	changes := []RecordDiff{{Time: time.Unix(0, 0), Record: "old.example.com", Type: "A", Action: "create"}}
+	for i := range n {
+		diff := RecordDiff{Time: time.Unix(int64(i+1), 0), Record: "home.example.com", Type: "A", Action: "update"}
+		changes = append(changes, signChange(diff, &anchor))
+	}
+	return changes, anchor
+}
+
+func TestVerifyHistory(t *testing.T) {
+	changes, anchor := signedHistory(t, 3)
+	if unsigned, problems, err := verifyHistory(changes, anchor); err != nil || len(problems) > 0 || unsigned != 1 {
+		t.Fatalf("intact history: %d unsigned, %q, %v", unsigned, problems, err)
+	}
+
+	stripped := make([]RecordDiff, len(changes))
+	for i, diff := range changes {
+		diff.Signature = ""
+		stripped[i] = diff
+	}
+	if _, problems, _ := verifyHistory(stripped, anchor); len(problems) == 0 {
+		t.Error("history without signatures verified")
+	}
+	if _, problems, _ := verifyHistory(changes[:3], anchor); len(problems) == 0 {
+		t.Error("history without its latest change verified")
+	}
+	if _, problems, _ := verifyHistory(changes[2:], anchor); len(problems) == 0 {
+		t.Error("history without its first signed change verified")
+	}
+	if _, problems, _ := verifyHistory(changes, SigningAnchor{}); len(problems) == 0 {
+		t.Error("signed history without anchor verified")
+	}
+}
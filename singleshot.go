@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// default hard deadline of -single-shot-safe, below the cron minute
+const defaultDeadline = 50 * time.Second
+
+// singleShot replaces the 'flock -n ... timeout ... chronic' wrapper around
+// cron runs: it refuses overlapping runs, kills a run at the deadline and
+// prints the output of the run only if it failed
+type singleShot struct {
+	stdout, stderr io.Writer
+	output         *os.File
+	logged         lockedBuffer
+}
+
+// lockedBuffer collects the log, which the deadline reads while the run
+// still writes to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) WriteTo(w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.WriteTo(w)
+}
+
+func lockFileName() string {
+	return filepath.Join(filepath.Dir(logFileName()), "hetzner-dns-update.lock")
+}
+
+// startSingleShot returns nil if another run holds the lock
+func startSingleShot(deadline time.Duration, log_file io.Writer) (*singleShot, error) {
+	locked, err := lockFile(lockFileName())
+	if err != nil {
+		return nil, fmt.Errorf("error locking %s: %w", lockFileName(), err)
+	}
+	if !locked {
+		log.Println("single-shot: another run holds", lockFileName(), "- skipping this run")
+		return nil, nil
+	}
+
+	output, err := os.CreateTemp("", "hetzner-dns-update-*.out")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(output.Name())
+
+	s := &singleShot{stdout: os.Stdout, stderr: os.Stderr, output: output}
+	os.Stdout, os.Stderr = output, output
+	log.SetOutput(io.MultiWriter(log_file, scrubWriter{&s.logged}))
+
+	time.AfterFunc(deadline, func() {
+		log.Printf("single-shot: deadline of %s exceeded, aborting\n", deadline)
+		s.finish(false)
+	})
+	return s, nil
+}
+
+// finish exits the process, with the captured output on failure
+func (s *singleShot) finish(ok bool) {
+	if ok {
+		os.Exit(0)
+	}
+	s.report()
+	os.Exit(1)
+}
+
+// report prints the captured output and log of a failed run
+func (s *singleShot) report() {
+	s.output.Seek(0, io.SeekStart)
+	output, _ := io.ReadAll(s.output)
+	scrubWriter{s.stdout}.Write(output)
+	s.logged.WriteTo(s.stderr)
+}
@@ -0,0 +1,88 @@
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SMS defaults: alert after 3 failed runs, at most every 6 hours and
+// 3 times a day, SMS cost money
+const (
+	defaultSMSAfterRuns   = 3
+	defaultSMSMinInterval = 6 * 3600
+	defaultSMSPerDay      = 3
+)
+
+// alertFailedRuns sends an SMS about persistent update errors
+func alertFailedRuns(failed int) {
+	sms := config.SMS
+	if sms.AfterRuns <= 0 {
+		sms.AfterRuns = defaultSMSAfterRuns
+	}
+	if sms.MinInterval <= 0 {
+		sms.MinInterval = defaultSMSMinInterval
+	}
+	if sms.PerDay <= 0 {
+		sms.PerDay = defaultSMSPerDay
+	}
+	if sms.Provider == "" || failed < sms.AfterRuns {
+		return
+	}
+	if !live.allowAlert("sms", time.Duration(sms.MinInterval)*time.Second, sms.PerDay) {
+		return
+	}
+	text := fmt.Sprintf("hetzner-dns-update on %s: %d runs failed, last error: %s", instanceName(), failed, live.status().LastError)
+	if len(text) > 160 {
+		text = text[:157] + "..."
+	}
+	for _, to := range sms.Recipients {
+		if err := sendSMS(to, text); err != nil {
+			log.Printf("error sending SMS to %s: %s\n", to, err)
+		}
+	}
+}
+
+func sendSMS(to, text string) error {
+	sms := config.SMS
+	var req *http.Request
+	switch sms.Provider {
+	case "twilio":
+		form := url.Values{"To": {to}, "From": {sms.From}, "Body": {text}}
+		req, _ = http.NewRequestWithContext(runContext, "POST", fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", sms.Account),
+			strings.NewReader(form.Encode()))
+		req.SetBasicAuth(sms.Account, sms.Token)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	case "seven":
+		form := url.Values{"to": {to}, "text": {text}}
+		if sms.From != "" {
+			form.Set("from", sms.From)
+		}
+		req, _ = http.NewRequestWithContext(runContext, "POST", "https://gateway.seven.io/api/sms", strings.NewReader(form.Encode()))
+		req.Header.Set("X-Api-Key", sms.Token)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	case "http":
+		gateway := strings.NewReplacer("{to}", url.QueryEscape(to), "{text}", url.QueryEscape(text)).Replace(sms.URL)
+		req, _ = http.NewRequestWithContext(runContext, "GET", gateway, nil)
+	default:
+		return fmt.Errorf("unknown SMS provider '%s'", sms.Provider)
+	}
+
+	client := &http.Client{Transport: apiClient().Transport, Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("status %s: %s", resp.Status, body)
+	}
+	return nil
+}
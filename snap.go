@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// interfaces declared as plugs in snap/snapcraft.yaml
+var snapInterfaces = []string{"network", "network-observe", "dot-config"}
+
+func inSnap() bool {
+	return os.Getenv("SNAP_NAME") != ""
+}
+
+// dataDir is where state and (for snaps) log files are kept
+func dataDir() string {
+	if inSnap() {
+		if os.Geteuid() == 0 {
+			if snap_data := os.Getenv("SNAP_DATA"); snap_data != "" {
+				return snap_data
+			}
+		}
+		if user_data := os.Getenv("SNAP_USER_DATA"); user_data != "" {
+			return user_data
+		}
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+func logFileName() string {
+	log_name := "hetzner-dns-update.log"
+	if inSnap() {
+		if os.Geteuid() == 0 && config.Logfile != "" {
+			log_name = filepath.Base(config.Logfile)
+		}
+		return filepath.Join(dataDir(), log_name)
+	}
+	if os.Geteuid() == 0 && config.Logfile != "" {
+		return config.Logfile
+	}
+	return log_name
+}
+
+func checkSnapInterfaces() []string {
+	if !inSnap() {
+		return nil
+	}
+	var missing []string
+	for _, plug := range snapInterfaces {
+		err := exec.Command("snapctl", "is-connected", plug).Run()
+		if err != nil {
+			missing = append(missing, plug)
+		}
+	}
+	return missing
+}
+
+func snapInterfaceHint(plug string) string {
+	return fmt.Sprintf("snap interface '%s' is not connected, run: sudo snap connect %s:%s",
+		plug, os.Getenv("SNAP_INSTANCE_NAME"), plug)
+}
+
+// snapConfigError adds an actionable hint to config read errors caused by confinement
+func snapConfigError(err error) error {
+	if !inSnap() || !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	return fmt.Errorf("%w (%s)", err, snapInterfaceHint("dot-config"))
+}
+
+// loadSnapSettings overlays values set with 'snap set hetzner-dns-update key=value'
+func loadSnapSettings() (bool, error) {
+	if !inSnap() {
+		return false, nil
+	}
+	out, err := exec.Command("snapctl", "get", "-d").Output()
+	if err != nil {
+		return false, fmt.Errorf("snapctl get: %w", err)
+	}
+
+	var settings map[string]any
+	if err := json.Unmarshal(out, &settings); err != nil {
+		return false, fmt.Errorf("snapctl get: %w", err)
+	}
+	if len(settings) == 0 {
+		return false, nil
+	}
+
+	if value, ok := settings["api-token"]; ok {
+		config.APIToken = snapString(value)
+	}
+	if value, ok := settings["records"]; ok {
+		config.Records = snapList(value)
+	}
+	if value, ok := settings["ttl"]; ok {
+		ttl, err := parseSeconds(snapString(value))
+		if err != nil {
+			return false, fmt.Errorf("snap setting 'ttl': %w", err)
+		}
+		config.TTL = ttl
+	}
+	if value, ok := settings["logfile"]; ok {
+		config.Logfile = snapString(value)
+	}
+	if smtp, ok := settings["smtp"].(map[string]any); ok {
+		for key, value := range smtp {
+			switch key {
+			case "server":
+				config.SMTP.Server = snapString(value)
+			case "port":
+				config.SMTP.Port = snapString(value)
+			case "user":
+				config.SMTP.User = snapString(value)
+			case "password":
+				config.SMTP.Password = snapString(value)
+			case "recipient":
+				config.SMTP.Recipient = snapString(value)
+			}
+		}
+	}
+	return true, nil
+}
+
+func snapString(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func snapList(value any) []string {
+	var list []string
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			list = append(list, snapString(item))
+		}
+	default:
+		list = strings.FieldsFunc(snapString(v), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+	return list
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// snapshotFile keeps the GET responses of the Hetzner API and of IP
+// detection, so a plan can be replayed offline with 'plan --snapshot'
+type snapshotFile struct {
+	Recorded  time.Time                   `json:"recorded"`
+	Responses map[string]snapshotResponse `json:"responses"`
+}
+
+type snapshotResponse struct {
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+var snapshot struct {
+	mu     sync.Mutex
+	file   string
+	replay bool
+	data   snapshotFile
+}
+
+func snapshotEnabled() bool {
+	return snapshot.file != ""
+}
+
+func recordSnapshot(file string) {
+	snapshot.file = file
+	snapshot.data = snapshotFile{Recorded: clock.Now(), Responses: make(map[string]snapshotResponse)}
+}
+
+func saveSnapshot() {
+	if !snapshotEnabled() || snapshot.replay {
+		return
+	}
+	snapshot.mu.Lock()
+	defer snapshot.mu.Unlock()
+	data, err := json.MarshalIndent(snapshot.data, "", "  ")
+	if err == nil {
+		err = os.WriteFile(snapshot.file, data, 0600)
+	}
+	if err != nil {
+		log.Println("error saving snapshot:", err)
+		return
+	}
+	log.Printf("snapshot: %d responses recorded to %s\n", len(snapshot.data.Responses), snapshot.file)
+}
+
+func loadSnapshot(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	snapshot.file, snapshot.replay = file, true
+	return json.Unmarshal(data, &snapshot.data)
+}
+
+// snapshotTransport records responses, or answers from the snapshot
+// without any network access when replaying
+type snapshotTransport struct {
+	next http.RoundTripper
+}
+
+func (t snapshotTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+	if snapshot.replay {
+		snapshot.mu.Lock()
+		recorded, ok := snapshot.data.Responses[key]
+		snapshot.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not in snapshot %s", key, snapshot.file)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+			StatusCode:    recorded.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	snapshot.mu.Lock()
+	snapshot.data.Responses[key] = snapshotResponse{Status: resp.StatusCode, Body: string(body)}
+	snapshot.mu.Unlock()
+	return resp, nil
+}
+
+// runPlan shows the changes a run would make, offline against a recorded
+// snapshot if given, and returns whether changes are pending
+func runPlan(args []string, opts runOptions) (bool, error) {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	file := flags.String("snapshot", "", "replay the API responses recorded with -record-snapshot")
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	if *file != "" {
+		if err := loadSnapshot(*file); err != nil {
+			return false, fmt.Errorf("error loading snapshot: %w", err)
+		}
+		fmt.Printf("replaying snapshot %s recorded at %s\n", *file, snapshot.data.Recorded.Format("2006-01-02 15:04:05"))
+		// change windows, cooldowns and grace periods see the time of the recording
+		clock = newManualClock(snapshot.data.Recorded)
+		// the live state is not part of a replay
+		state = nil
+		replayConfig()
+		selectProfile()
+		if lowImpact {
+			return false, fmt.Errorf("DNS based IP detection of the low-impact profile can't be replayed")
+		}
+	}
+	opts.update, opts.verbose = false, true
+	if !runOnce(opts) || runErrors > 0 {
+		return false, fmt.Errorf("plan finished with %d errors", max(runErrors, 1))
+	}
+	printPlan(runPlanned)
+	return len(runPlanned) > 0, nil
+}
+
+// replayConfig switches off what a replay must not reach besides the
+// recorded responses: notifications, alerts, events, the internal DNS
+// server and the metrics of the real runs
+func replayConfig() {
+	config.SMTP = SMTPConfig{}
+	config.Signal = SignalConfig{}
+	config.XMPP = XMPPConfig{}
+	config.Notifications.Webhooks = nil
+	config.SMS = SMSConfig{}
+	config.Ticket = TicketConfig{}
+	config.SIEM = SIEMConfig{}
+	config.CloudEvents = CloudEventsConfig{}
+	config.ChangeNotify = ChangeNotifyConfig{}
+	config.SplitHorizon = SplitHorizonConfig{}
+	config.MetricsPush = MetricsPushConfig{}
+	config.MetricsFile = ""
+	config.CheckMK = CheckMKConfig{}
+	config.RenewalHook = RenewalHookConfig{}
+}
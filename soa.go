@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var defaultSOANameservers = []string{"hydrogen.ns.hetzner.com"}
+
+func soaNameservers() []string {
+	if len(config.SOATracking.Nameservers) > 0 {
+		return config.SOATracking.Nameservers
+	}
+	return defaultSOANameservers
+}
+
+// querySOASerial asks a nameserver directly for the SOA serial of a zone
+func querySOASerial(zone, server string) (uint32, error) {
+	soa, err := querySOA(zone, server)
+	if err != nil {
+		return 0, err
+	}
+	return soa.Serial, nil
+}
+
+func querySOA(zone, server string) (*dns.SOA, error) {
+	resp, err := queryNameserver(zone, dns.TypeSOA, server)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa, nil
+		}
+	}
+	return nil, fmt.Errorf("no SOA for '%s' from %s (%s)", zone, server, dns.RcodeToString[resp.Rcode])
+}
+
+// queryNameserver asks a nameserver directly, without recursion
+func queryNameserver(name string, qtype uint16, server string) (*dns.Msg, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "53"
+	}
+	if config.DoH != "" {
+		// the nameserver is asked directly, only its name is resolved via DoH
+		addrs, err := dohResolve(host)
+		if err != nil {
+			return nil, err
+		}
+		host = addrs[0]
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = false
+
+	client := &dns.Client{Timeout: 5 * time.Second}
+	resp, _, err := client.ExchangeContext(runContext, msg, net.JoinHostPort(host, port))
+	return resp, err
+}
+
+// soaSerials returns 'server=serial' entries for a zone
+func soaSerials(zone string) string {
+	var serials []string
+	for _, server := range soaNameservers() {
+		serial, err := querySOASerial(zone, server)
+		if err != nil {
+			log.Println("error querying SOA serial:", err)
+			serials = append(serials, server+"=?")
+			continue
+		}
+		serials = append(serials, fmt.Sprintf("%s=%d", server, serial))
+	}
+	return strings.Join(serials, " ")
+}
+
+// trackSOA records the serials of all zones touched by the changes before
+// applying them and returns a function logging the serials afterwards
+func trackSOA(changes []Change) func() {
+	if !config.SOATracking.Enabled || lowImpact || len(changes) == 0 {
+		return func() {}
+	}
+
+	var zones []string
+	before := make(map[string]string)
+	for _, zone_changes := range groupByZone(changes) {
+		zone := zone_changes[0].Zone
+		zones = append(zones, zone)
+		before[zone] = soaSerials(zone)
+	}
+
+	return func() {
+		for _, zone := range zones {
+			log.Printf("SOA serial of %s: before %s, after %s\n", zone, before[zone], soaSerials(zone))
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// sourceIPs returns the addresses a record's 'source' override points to
+// instead of the public IP of this machine:
+//
+//	static:203.0.113.5,2001:db8::5   fixed addresses
+//	file:/run/vpn/endpoint           addresses in a local file
+//	url:https://lb.example.com/ip    addresses returned by a URL
+//	aws, gcp, hcloud                 addresses of this cloud instance
+//	interface:wg0                    addresses of a local interface
+//	tailscale                        Tailscale addresses of this host
+func sourceIPs(source string) (string, string, error) {
+	kind, value, _ := strings.Cut(source, ":")
+	switch kind {
+	case "interface":
+		return interfaceIPs(value)
+	case "tailscale":
+		return tailscaleIPs()
+	case "aws", "gcp", "hcloud":
+		return metadataIPs(kind)
+	case "static":
+		return parseIPList(value)
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", "", err
+		}
+		return parseIPList(string(data))
+	case "url":
+		resp, err := getURL(detectionClient(), value)
+		if err != nil {
+			return "", "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return "", "", fmt.Errorf("source '%s' status: %s", source, resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err != nil {
+			return "", "", err
+		}
+		return parseIPList(string(data))
+	}
+	return "", "", fmt.Errorf("unknown source '%s'", source)
+}
+
+// parseIPList takes one IPv4 and/or one IPv6 address separated by commas
+// or white space
+func parseIPList(text string) (string, string, error) {
+	ipv4, ipv6 := "", ""
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' }) {
+		ip := net.ParseIP(field)
+		switch {
+		case ip == nil:
+			return "", "", fmt.Errorf("invalid address '%s'", field)
+		case ip.To4() != nil:
+			ipv4 = ip.String()
+		default:
+			ipv6 = ip.String()
+		}
+	}
+	if ipv4 == "" && ipv6 == "" {
+		return "", "", fmt.Errorf("no address found")
+	}
+	return ipv4, ipv6, nil
+}
+
+// validateDetectionURL requires HTTPS for addresses that get published,
+// unless 'allow_insecure_detection' is set
+func validateDetectionURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && config.AllowInsecureDetection:
+		return nil
+	case u.Scheme == "http":
+		return fmt.Errorf("'%s' is not HTTPS, set \"allow_insecure_detection\": true to use it anyway", value)
+	}
+	return fmt.Errorf("'%s': unsupported scheme '%s'", value, u.Scheme)
+}
+
+func validateDetection() error {
+	for _, provider := range config.Detection {
+		if _, ok := detectionServices[provider]; ok {
+			continue
+		}
+		if err := validateDetectionURL(provider); err != nil {
+			return fmt.Errorf("detection: %w (or ipify, icanhazip, ifconfig.co)", err)
+		}
+	}
+	return nil
+}
+
+func validateSource(source string) error {
+	kind, value, _ := strings.Cut(source, ":")
+	switch kind {
+	case "static":
+		_, _, err := parseIPList(value)
+		return err
+	case "file":
+		if value == "" {
+			return fmt.Errorf("source 'file' needs a value")
+		}
+		return nil
+	case "url":
+		if value == "" {
+			return fmt.Errorf("source 'url' needs a value")
+		}
+		return validateDetectionURL(value)
+	case "interface":
+		if value == "" {
+			return fmt.Errorf("source 'interface' needs a value")
+		}
+		return nil
+	case "aws", "gcp", "hcloud", "tailscale":
+		return nil
+	}
+	return fmt.Errorf("unknown source '%s' (static:, file:, url:, interface:, aws, gcp, hcloud, tailscale)", source)
+}
+
+// Tailscale assigns addresses from the CGNAT range and a fixed ULA prefix
+var tailscaleNets = []string{"100.64.0.0/10", "fd7a:115c:a1e0::/48"}
+
+// interfaceIPs returns the first IPv4 and IPv6 address (not link-local) of an
+// interface, e.g. of a WireGuard tunnel
+func interfaceIPs(name string) (string, string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", "", err
+	}
+	ipv4, ipv6 := firstAddresses(addrs, nil)
+	if ipv4 == "" && ipv6 == "" {
+		return "", "", fmt.Errorf("interface '%s' has no address", name)
+	}
+	return ipv4, ipv6, nil
+}
+
+// tailscaleIPs finds the Tailscale addresses on any interface, so neither
+// the interface name nor the tailscale command is needed
+func tailscaleIPs() (string, string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", "", err
+	}
+	var nets []*net.IPNet
+	for _, cidr := range tailscaleNets {
+		_, network, _ := net.ParseCIDR(cidr)
+		nets = append(nets, network)
+	}
+	ipv4, ipv6 := firstAddresses(addrs, nets)
+	if ipv4 == "" && ipv6 == "" {
+		return "", "", fmt.Errorf("no Tailscale address found, is tailscaled running?")
+	}
+	return ipv4, ipv6, nil
+}
+
+// firstAddresses skips link-local addresses and, if nets is set, all
+// addresses outside of nets
+func firstAddresses(addrs []net.Addr, nets []*net.IPNet) (string, string) {
+	ipv4, ipv6 := "", ""
+	for _, addr := range addrs {
+		network, ok := addr.(*net.IPNet)
+		if !ok || network.IP.IsLinkLocalUnicast() || network.IP.IsLoopback() {
+			continue
+		}
+		if nets != nil && !slices.ContainsFunc(nets, func(n *net.IPNet) bool { return n.Contains(network.IP) }) {
+			continue
+		}
+		if network.IP.To4() != nil {
+			if ipv4 == "" {
+				ipv4 = network.IP.String()
+			}
+		} else if ipv6 == "" {
+			ipv6 = network.IP.String()
+		}
+	}
+	return ipv4, ipv6
+}
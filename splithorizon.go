@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const defaultSplitHorizonTTL = 300
+
+// internalServer is the local DNS server of a split-horizon setup
+type internalServer interface {
+	addresses(fullDomain string) ([]string, error)
+	add(fullDomain, ip string) error
+	remove(fullDomain, ip string) error
+}
+
+func newInternalServer() (internalServer, error) {
+	sh := config.SplitHorizon
+	switch sh.Server {
+	case "rfc2136":
+		return rfc2136Server{}, nil
+	case "pihole":
+		return newPiholeServer(sh.Password)
+	case "adguard":
+		return adguardServer{}, nil
+	}
+	return nil, fmt.Errorf("unknown split_horizon server '%s'", sh.Server)
+}
+
+// reconcileSplitHorizon publishes the LAN addresses of the managed records
+// to the internal server, Hetzner keeps getting the public ones
+func reconcileSplitHorizon(opts runOptions) {
+	if config.SplitHorizon.Server == "" {
+		return
+	}
+	server, err := newInternalServer()
+	if err != nil {
+		logAndMail("error connecting to internal DNS server: " + err.Error())
+		return
+	}
+
+	type lanIPs struct {
+		ipv4, ipv6 string
+		err        error
+	}
+	sources := make(map[string]lanIPs)
+	for _, fullDomain := range configuredRecords() {
+		if !opts.selected(fullDomain) {
+			continue
+		}
+		source := config.SplitHorizon.Source
+		if override, ok := overrideFor(fullDomain); ok && override.Internal != "" {
+			source = override.Internal
+		}
+		if source == "none" {
+			continue
+		}
+		ips, ok := sources[source]
+		if !ok {
+			ips.ipv4, ips.ipv6, ips.err = sourceIPs(source)
+			sources[source] = ips
+		}
+		if ips.err != nil {
+			logAndMail(fmt.Sprintf("error getting internal address of %s: %s", fullDomain, ips.err))
+			continue
+		}
+
+		managed := newManagedRecord(fullDomain, "", "")
+		var want []string
+		if ips.ipv4 != "" && managed.manages("A") {
+			want = append(want, ips.ipv4)
+		}
+		if ips.ipv6 != "" && managed.manages("AAAA") {
+			want = append(want, ips.ipv6)
+		}
+		current, err := server.addresses(fullDomain)
+		if err != nil {
+			logAndMail(fmt.Sprintf("error reading internal record %s: %s", fullDomain, err))
+			continue
+		}
+		var stale, missing []string
+		for _, ip := range current {
+			if !slices.Contains(want, ip) && managed.manages(addressType(ip)) {
+				stale = append(stale, ip)
+			}
+		}
+		for _, ip := range want {
+			if !slices.Contains(current, ip) {
+				missing = append(missing, ip)
+			}
+		}
+
+		if opts.verbose {
+			if len(stale) == 0 && len(missing) == 0 {
+				fmt.Println("- internal record is current for:", fullDomain)
+			} else {
+				fmt.Printf("- internal record needs update for: %s (%s)\n", fullDomain, strings.Join(want, ", "))
+			}
+		}
+		if !opts.update {
+			continue
+		}
+		for _, ip := range stale {
+			if err := server.remove(fullDomain, ip); err != nil {
+				logAndMail(fmt.Sprintf("error removing %s from internal record %s: %s", ip, fullDomain, err))
+				continue
+			}
+			log.Printf("internal: removed %s from %s\n", ip, fullDomain)
+		}
+		for _, ip := range missing {
+			if err := server.add(fullDomain, ip); err != nil {
+				logAndMail(fmt.Sprintf("error adding %s to internal record %s: %s", ip, fullDomain, err))
+				continue
+			}
+			log.Printf("internal: added %s to %s\n", ip, fullDomain)
+		}
+	}
+}
+
+func addressType(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "AAAA"
+	}
+	return "A"
+}
+
+// rfc2136Server sends dynamic updates, signed with TSIG if a key is set
+type rfc2136Server struct{}
+
+func (rfc2136Server) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	sh := config.SplitHorizon
+	key := tsigKey{sh.TSIGName, sh.TSIGSecret, sh.TSIGAlgorithm}
+	client := &dns.Client{Timeout: 5 * time.Second, TsigSecret: key.sign(msg)}
+	address, err := serverAddress(sh.Address)
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := client.ExchangeContext(runContext, msg, address)
+	return resp, err
+}
+
+func (s rfc2136Server) addresses(fullDomain string) ([]string, error) {
+	var ips []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg := new(dns.Msg)
+		msg.SetQuestion(dns.Fqdn(fullDomain), qtype)
+		msg.RecursionDesired = false
+		resp, err := s.exchange(msg)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			return nil, fmt.Errorf("%s from %s", dns.RcodeToString[resp.Rcode], config.SplitHorizon.Address)
+		}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A.String())
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA.String())
+			}
+		}
+	}
+	return ips, nil
+}
+
+func (s rfc2136Server) update(fullDomain, ip string, insert bool) error {
+	zone := config.SplitHorizon.Zone
+	if zone == "" {
+		_, zone, _ = strings.Cut(fullDomain, ".")
+	}
+	ttl := config.SplitHorizon.TTL
+	if ttl <= 0 {
+		ttl = defaultSplitHorizonTTL
+	}
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(fullDomain), ttl, addressType(ip), ip))
+	if err != nil {
+		return err
+	}
+	msg := new(dns.Msg)
+	msg.SetUpdate(dns.Fqdn(zone))
+	if insert {
+		msg.Insert([]dns.RR{rr})
+	} else {
+		msg.Remove([]dns.RR{rr})
+	}
+	resp, err := s.exchange(msg)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update refused: %s", dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+func (s rfc2136Server) add(fullDomain, ip string) error {
+	return s.update(fullDomain, ip, true)
+}
+
+func (s rfc2136Server) remove(fullDomain, ip string) error {
+	return s.update(fullDomain, ip, false)
+}
+
+// splitHorizonRequest calls the web API of Pi-hole or AdGuard Home
+func splitHorizonRequest(method, path string, header http.Header, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(runContext, method, strings.TrimSuffix(config.SplitHorizon.Address, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s status: %s", method, path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(result)
+}
+
+// piholeServer manages the local DNS records of Pi-hole v6, entries of
+// dns.hosts are "<ip> <name>"
+type piholeServer struct {
+	header http.Header
+}
+
+func newPiholeServer(password string) (piholeServer, error) {
+	var auth struct {
+		Session struct {
+			Valid bool   `json:"valid"`
+			SID   string `json:"sid"`
+		} `json:"session"`
+	}
+	err := splitHorizonRequest("POST", "/api/auth", nil, map[string]string{"password": password}, &auth)
+	if err != nil {
+		return piholeServer{}, err
+	}
+	if !auth.Session.Valid {
+		return piholeServer{}, fmt.Errorf("pi-hole rejected the password")
+	}
+	return piholeServer{header: http.Header{"X-Ftl-Sid": {auth.Session.SID}}}, nil
+}
+
+func (s piholeServer) addresses(fullDomain string) ([]string, error) {
+	var result struct {
+		Config struct {
+			DNS struct {
+				Hosts []string `json:"hosts"`
+			} `json:"dns"`
+		} `json:"config"`
+	}
+	if err := splitHorizonRequest("GET", "/api/config/dns/hosts", s.header, nil, &result); err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, host := range result.Config.DNS.Hosts {
+		fields := strings.Fields(host)
+		if len(fields) >= 2 && slices.Contains(fields[1:], fullDomain) {
+			ips = append(ips, fields[0])
+		}
+	}
+	return ips, nil
+}
+
+func (s piholeServer) add(fullDomain, ip string) error {
+	return splitHorizonRequest("PUT", "/api/config/dns/hosts/"+url.PathEscape(ip+" "+fullDomain), s.header, nil, nil)
+}
+
+func (s piholeServer) remove(fullDomain, ip string) error {
+	return splitHorizonRequest("DELETE", "/api/config/dns/hosts/"+url.PathEscape(ip+" "+fullDomain), s.header, nil, nil)
+}
+
+// adguardServer manages the DNS rewrites of AdGuard Home
+type adguardServer struct{}
+
+type adguardRewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+func (adguardServer) header() http.Header {
+	req := &http.Request{Header: make(http.Header)}
+	req.SetBasicAuth(config.SplitHorizon.Username, config.SplitHorizon.Password)
+	return req.Header
+}
+
+func (s adguardServer) addresses(fullDomain string) ([]string, error) {
+	var rewrites []adguardRewrite
+	if err := splitHorizonRequest("GET", "/control/rewrite/list", s.header(), nil, &rewrites); err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, rewrite := range rewrites {
+		if rewrite.Domain == fullDomain && net.ParseIP(rewrite.Answer) != nil {
+			ips = append(ips, rewrite.Answer)
+		}
+	}
+	return ips, nil
+}
+
+func (s adguardServer) add(fullDomain, ip string) error {
+	return splitHorizonRequest("POST", "/control/rewrite/add", s.header(), adguardRewrite{fullDomain, ip}, nil)
+}
+
+func (s adguardServer) remove(fullDomain, ip string) error {
+	return splitHorizonRequest("POST", "/control/rewrite/delete", s.header(), adguardRewrite{fullDomain, ip}, nil)
+}
+
+func validateSplitHorizon() error {
+	sh := config.SplitHorizon
+	for name, override := range config.Overrides {
+		if override.Internal != "" && override.Internal != "none" {
+			if err := validateSource(override.Internal); err != nil {
+				return fmt.Errorf("override '%s': internal: %w", name, err)
+			}
+		}
+	}
+	if sh.Server == "" {
+		return nil
+	}
+	switch sh.Server {
+	case "rfc2136":
+		if sh.TSIGName != "" && sh.TSIGSecret == "" {
+			return fmt.Errorf("split_horizon: tsig_name without tsig_secret")
+		}
+	case "pihole", "adguard":
+		address, err := url.Parse(sh.Address)
+		if err != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+			return fmt.Errorf("split_horizon: address '%s' is not an http(s) URL", sh.Address)
+		}
+	default:
+		return fmt.Errorf("split_horizon: unknown server '%s' (rfc2136, pihole, adguard)", sh.Server)
+	}
+	if sh.Address == "" {
+		return fmt.Errorf("split_horizon: missing address")
+	}
+	if sh.Source == "" {
+		return fmt.Errorf("split_horizon: missing source, e.g. 'interface:eth0'")
+	}
+	if err := validateSource(sh.Source); err != nil {
+		return fmt.Errorf("split_horizon: %w", err)
+	}
+	if _, err := validateTTL(sh.TTL); err != nil {
+		return fmt.Errorf("split_horizon: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const stateFileName = "hetzner-dns-update.state.json"
+
+// stateStore keeps the run state between runs and, with a shared backend,
+// between the hosts of a fleet
+type stateStore interface {
+	// load returns nil if nothing was stored yet
+	load(key string) ([]byte, error)
+	save(key string, data []byte) error
+}
+
+// savedState is the part of the live state that survives a restart
+type savedState struct {
+	Run       RunStatus                `json:"run"`
+	Records   map[string]*RecordStatus `json:"records"`
+	Frozen    map[string]time.Time     `json:"frozen"`
+	History   []IPChange               `json:"history"`
+	Errors    []ErrorEntry             `json:"errors"`
+	Changes   []RecordDiff             `json:"changes"`
+	Protected map[string]time.Time     `json:"protected"`
+	Ramped    map[string]time.Time     `json:"ramped,omitempty"`
+	Reported  map[string]IPChange      `json:"reported,omitempty"`
+	Signing   *SigningAnchor           `json:"signing,omitempty"`
+	Latency   []LatencySample          `json:"latency,omitempty"`
+	Alerts    map[string][]time.Time   `json:"alerts,omitempty"`
+
+	Digest map[string][]QueuedNotification `json:"digest,omitempty"`
+}
+
+var state stateStore
+
+func openStateStore() (stateStore, error) {
+	switch config.State.Backend {
+	case "", "file":
+		path := config.State.Path
+		if path == "" {
+			path = filepath.Join(dataDir(), stateFileName)
+		}
+		return fileStore{path, stateKey()}, nil
+	case "sqlite":
+		if config.State.Path == "" {
+			return nil, fmt.Errorf("state backend 'sqlite' needs a 'path'")
+		}
+		return openSQLiteStore(config.State.Path)
+	case "redis":
+		if config.State.Address == "" {
+			return nil, fmt.Errorf("state backend 'redis' needs an 'address'")
+		}
+		return redisStore{config.State.Address, config.State.Password}, nil
+	}
+	return nil, fmt.Errorf("unknown state backend '%s'", config.State.Backend)
+}
+
+// stateKey separates the entries of several instances in a shared backend
+func stateKey() string {
+	if config.State.Key != "" {
+		return config.State.Key
+	}
+	return "hetzner-dns-update:" + instanceName()
+}
+
+func loadState() {
+	var err error
+	state, err = openStateStore()
+	if err != nil {
+		log.Println("error opening state store:", err)
+		return
+	}
+	data, err := state.load(stateKey())
+	if err != nil {
+		log.Println("error loading state:", err)
+		return
+	}
+	if data == nil {
+		return
+	}
+	var saved savedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Println("error loading state:", err)
+		return
+	}
+	live.restore(saved)
+}
+
+func saveState() {
+	if state == nil {
+		return
+	}
+	data, err := json.Marshal(live.snapshot())
+	if err == nil {
+		err = state.save(stateKey(), data)
+	}
+	if err != nil {
+		log.Println("error saving state:", err)
+	}
+}
+
+// fileStore keeps the state of a single host in a JSON file, other keys
+// like 'stateKey():disabled' in files next to it
+type fileStore struct {
+	path string
+	key  string
+}
+
+func (f fileStore) file(key string) string {
+	if suffix, found := strings.CutPrefix(key, f.key+":"); found {
+		return f.path + "." + suffix
+	}
+	return f.path
+}
+
+func (f fileStore) load(key string) ([]byte, error) {
+	data, err := os.ReadFile(f.file(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f fileStore) save(key string, data []byte) error {
+	path := f.file(key)
+	tmp_file := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp_file, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp_file, path)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redisTimeout = 10 * time.Second
+
+// redisStore keeps the state as one string value per instance, using just
+// enough of the RESP protocol for AUTH, GET and SET
+type redisStore struct {
+	address  string
+	password string
+}
+
+func (r redisStore) load(key string) ([]byte, error) {
+	return r.command("GET", key)
+}
+
+func (r redisStore) save(key string, data []byte) error {
+	_, err := r.command("SET", key, string(data))
+	return err
+}
+
+func (r redisStore) command(args ...string) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", r.address, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(redisTimeout))
+	reader := bufio.NewReader(conn)
+
+	if r.password != "" {
+		if _, err := redisRoundTrip(conn, reader, "AUTH", r.password); err != nil {
+			return nil, err
+		}
+	}
+	return redisRoundTrip(conn, reader, args...)
+}
+
+func redisRoundTrip(w io.Writer, reader *bufio.Reader, args ...string) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, err
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad reply '%s'", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply '%s'", line)
+}
@@ -0,0 +1,47 @@
+//go:build !minimal
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteStore keeps one row per instance, so several hosts can share a
+// database on a network file system
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(path string) (stateStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS state (key TEXT PRIMARY KEY, value TEXT NOT NULL, updated INTEGER NOT NULL)")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return sqliteStore{db}, nil
+}
+
+func (s sqliteStore) load(key string) ([]byte, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM state WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+func (s sqliteStore) save(key string, data []byte) error {
+	_, err := s.db.Exec("INSERT INTO state (key, value, updated) VALUES (?, ?, strftime('%s', 'now')) "+
+		"ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated = excluded.updated", key, string(data))
+	return err
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// upper limit of a numeric range, against typos like '{1..1000000}'
+const maxRangeSize = 1000
+
+// numeric ranges like '{1..20}', '{01..20}' or '{0..100..10}'
+var braceRange = regexp.MustCompile(`^(\d+)\.\.(\d+)(?:\.\.(\d+))?$`)
+
+// expandBraces expands templates like '{vpn,mail}.example.com' or
+// 'node{01..20}.example.com'
+func expandBraces(template string) []string {
+	open := strings.Index(template, "{")
+	if open < 0 {
+		return []string{template}
+	}
+	length := strings.Index(template[open:], "}")
+	if length < 0 {
+		return []string{template}
+	}
+	prefix, suffix := template[:open], template[open+length+1:]
+
+	inner := template[open+1 : open+length]
+	alternatives := braceAlternatives(inner)
+	var expanded []string
+	if alternatives == nil {
+		// kept as it is, rejected by validateTemplates
+		for _, rest := range expandBraces(suffix) {
+			expanded = append(expanded, prefix+"{"+inner+"}"+rest)
+		}
+		return expanded
+	}
+	for _, alternative := range alternatives {
+		expanded = append(expanded, expandBraces(prefix+alternative+suffix)...)
+	}
+	return expanded
+}
+
+func braceAlternatives(inner string) []string {
+	match := braceRange.FindStringSubmatch(inner)
+	if match == nil {
+		var alternatives []string
+		for _, alternative := range strings.Split(inner, ",") {
+			alternatives = append(alternatives, strings.TrimSpace(alternative))
+		}
+		return alternatives
+	}
+
+	first, _ := strconv.Atoi(match[1])
+	last, _ := strconv.Atoi(match[2])
+	step := 1
+	if match[3] != "" {
+		step, _ = strconv.Atoi(match[3])
+	}
+	if step <= 0 || last < first || (last-first)/step >= maxRangeSize {
+		return nil
+	}
+	// a leading zero pads all numbers to the same width
+	width := 0
+	if (len(match[1]) > 1 && match[1][0] == '0') || (len(match[2]) > 1 && match[2][0] == '0') {
+		width = max(len(match[1]), len(match[2]))
+	}
+	var alternatives []string
+	for i := first; i <= last; i += step {
+		alternatives = append(alternatives, fmt.Sprintf("%0*d", width, i))
+	}
+	return alternatives
+}
+
+// overrideFor returns the override of a record, set for its name or for a
+// template the name is expanded from; of overlapping templates the first
+// in sort order applies
+func overrideFor(fullDomain string) (RecordOverride, bool) {
+	if override, ok := config.Overrides[fullDomain]; ok {
+		return override, true
+	}
+	for _, template := range slices.Sorted(maps.Keys(config.Overrides)) {
+		override := config.Overrides[template]
+		if !strings.Contains(template, "{") {
+			continue
+		}
+		names := expandBraces(template)
+		i := slices.Index(names, fullDomain)
+		if i < 0 {
+			continue
+		}
+		if suffixes := expandBraces(override.IPv6Suffix); len(suffixes) == len(names) {
+			override.IPv6Suffix = suffixes[i]
+		}
+		return override, true
+	}
+	return RecordOverride{}, false
+}
+
+// configuredRecords returns the configured records with all templates
+// expanded, followed by the records of the desired state file; a name of
+// overlapping templates is listed once, its override is the one of
+// overrideFor
+func configuredRecords() []string {
+	var records []string
+	seen := make(map[string]bool)
+	add := func(fullDomain string) {
+		if !seen[fullDomain] {
+			seen[fullDomain] = true
+			records = append(records, fullDomain)
+		}
+	}
+	for _, template := range config.Records {
+		for _, fullDomain := range expandBraces(template) {
+			add(fullDomain)
+		}
+	}
+	for _, fullDomain := range desired.records {
+		add(fullDomain)
+	}
+	return records
+}
+
+func newManagedRecord(fullDomain, name, zone string) ManagedRecord {
+	managed := ManagedRecord{
+		FullDomain: fullDomain,
+		Name:       name,
+		Zone:       zone,
+		TTL:        config.TTL,
+	}
+	override, ok := overrideFor(fullDomain)
+	if !ok {
+		override, ok = desired.overrides[fullDomain]
+	}
+	if ok {
+		if override.TTL > 0 {
+			managed.TTL = override.TTL
+			managed.OverrideTTL = true
+		}
+		managed.Family = override.Family
+		managed.Source = override.Source
+		managed.IPv6Suffix = override.IPv6Suffix
+		managed.Probe, managed.ProbeFailure = override.Probe, override.ProbeFailure
+	}
+	return managed
+}
+
+// manages reports whether the record's family policy includes recType
+func (m ManagedRecord) manages(recType string) bool {
+	switch m.Family {
+	case "ipv4":
+		return recType == "A"
+	case "ipv6":
+		return recType == "AAAA"
+	}
+	return true
+}
+
+func validateOverrides() error {
+	for name, override := range config.Overrides {
+		if _, err := validateTTL(override.TTL); err != nil {
+			return fmt.Errorf("override '%s': %w", name, err)
+		}
+		if err := validateProviderTTL(override.TTL); err != nil {
+			return fmt.Errorf("override '%s': %w", name, err)
+		}
+		switch override.Family {
+		case "", "both", "ipv4", "ipv6":
+		default:
+			return fmt.Errorf("override '%s': unknown family '%s'", name, override.Family)
+		}
+		if err := validateProbe(override.Probe, override.ProbeFailure); err != nil {
+			return fmt.Errorf("override '%s': %w", name, err)
+		}
+		if override.Source != "" {
+			if err := validateSource(override.Source); err != nil {
+				return fmt.Errorf("override '%s': %w", name, err)
+			}
+		}
+		if err := validateSuffixes(name, override.IPv6Suffix); err != nil {
+			return fmt.Errorf("override '%s': %w", name, err)
+		}
+		if err := validateExpect(override.Expect); err != nil {
+			return fmt.Errorf("override '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateSuffixes requires one IPv6 suffix for all names of a template
+// or one for each of them
+func validateSuffixes(template, suffix string) error {
+	if suffix == "" {
+		return nil
+	}
+	suffixes := expandBraces(suffix)
+	if names := expandBraces(template); len(suffixes) > 1 && len(suffixes) != len(names) {
+		return fmt.Errorf("%d IPv6 suffixes for %d names", len(suffixes), len(names))
+	}
+	for _, suffix := range suffixes {
+		if addr, err := netip.ParseAddr(suffix); err != nil || !addr.Is6() || addr.Zone() != "" {
+			return fmt.Errorf("invalid IPv6 suffix '%s'", suffix)
+		}
+	}
+	return nil
+}
+
+// validateTemplates rejects records and override keys with a range that
+// can't be expanded, e.g. '{20..1}'
+func validateTemplates() error {
+	templates := slices.Clone(config.Records)
+	for template := range config.Overrides {
+		templates = append(templates, template)
+	}
+	for _, template := range templates {
+		for _, name := range expandBraces(template) {
+			if strings.ContainsAny(name, "{}") {
+				return fmt.Errorf("record '%s': invalid template", template)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOverrideForOverlappingTemplates(t *testing.T) {
+	useConfig(t, Config{Overrides: map[string]RecordOverride{
+		"{a,b}.example.com":   {TTL: 60},
+		"{b,c}.example.com":   {TTL: 120},
+		"{b,c,d}.example.com": {TTL: 180},
+		"c.example.com":       {TTL: 300},
+	}})
+	for range 20 {
+		if override, _ := overrideFor("b.example.com"); override.TTL != 60 {
+			t.Fatalf("b.example.com got TTL %d, want 60 of the first template", override.TTL)
+		}
+		if override, _ := overrideFor("c.example.com"); override.TTL != 300 {
+			t.Fatalf("c.example.com got TTL %d, want 300 of its own override", override.TTL)
+		}
+	}
+}
+
+func TestConfiguredRecordsOverlappingTemplates(t *testing.T) {
+	useConfig(t, Config{
+		Records: []string{"{a,b}.example.com", "{b,c}.example.com"},
+		Overrides: map[string]RecordOverride{
+			"{a,b}.example.com": {TTL: 60},
+			"{b,c}.example.com": {TTL: 120},
+		},
+	})
+	var names []string
+	for _, managed := range managedRecords("192.0.2.1", "") {
+		names = append(names, managed.FullDomain)
+		if managed.FullDomain == "b.example.com" && managed.TTL != 60 {
+			t.Errorf("b.example.com has TTL %d, want 60 of the first template", managed.TTL)
+		}
+	}
+	if want := []string{"a.example.com", "b.example.com", "c.example.com"}; !slices.Equal(names, want) {
+		t.Errorf("managed %v, want %v", names, want)
+	}
+}
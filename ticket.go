@@ -0,0 +1,102 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// a ticket is opened after 10 failed runs by default, at most one a day
+const defaultTicketAfterRuns = 10
+
+// openFailureTicket opens a Zammad or Jira ticket about persistent
+// update errors
+func openFailureTicket(failed int) {
+	ticket := config.Ticket
+	if ticket.AfterRuns <= 0 {
+		ticket.AfterRuns = defaultTicketAfterRuns
+	}
+	if ticket.System == "" || failed < ticket.AfterRuns {
+		return
+	}
+	if !live.allowAlert("ticket", 24*time.Hour, 1) {
+		return
+	}
+
+	title := fmt.Sprintf("hetzner-dns-update on %s: %d runs failed", instanceName(), failed)
+	var lines []string
+	for _, entry := range live.lastErrors() {
+		line := entry.Time.Format("2006-01-02 15:04:05") + " "
+		if entry.RunID != "" {
+			line += "[" + entry.RunID + "] "
+		}
+		lines = append(lines, line+entry.Message)
+	}
+	body := fmt.Sprintf("The last %d runs of hetzner-dns-update on %s had errors.\n\nLast errors:\n%s\n",
+		failed, instanceName(), strings.Join(lines, "\n"))
+
+	if err := createTicket(title, body); err != nil {
+		log.Printf("error opening %s ticket: %s\n", ticket.System, err)
+		return
+	}
+	log.Printf("opened %s ticket: %s\n", ticket.System, title)
+}
+
+func createTicket(title, body string) error {
+	ticket := config.Ticket
+	base := strings.TrimSuffix(ticket.URL, "/")
+	var req *http.Request
+	switch ticket.System {
+	case "zammad":
+		data, _ := json.Marshal(map[string]any{
+			"title":    title,
+			"group":    ticket.Queue,
+			"customer": ticket.Customer,
+			"article": map[string]any{
+				"subject":  title,
+				"body":     body,
+				"type":     "note",
+				"internal": false,
+			},
+		})
+		req, _ = http.NewRequestWithContext(runContext, "POST", base+"/api/v1/tickets", bytes.NewReader(data))
+		req.Header.Set("Authorization", "Token token="+ticket.Token)
+	case "jira":
+		issue_type := ticket.IssueType
+		if issue_type == "" {
+			issue_type = "Task"
+		}
+		data, _ := json.Marshal(map[string]any{
+			"fields": map[string]any{
+				"project":     map[string]string{"key": ticket.Queue},
+				"summary":     title,
+				"description": body,
+				"issuetype":   map[string]string{"name": issue_type},
+			},
+		})
+		req, _ = http.NewRequestWithContext(runContext, "POST", base+"/rest/api/2/issue", bytes.NewReader(data))
+		req.SetBasicAuth(ticket.User, ticket.Token)
+	default:
+		return fmt.Errorf("unknown ticket system '%s'", ticket.System)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Transport: apiClient().Transport, Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("status %s: %s", resp.Status, reply)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Seconds is a TTL given as a number of seconds or a duration like "5m" or "1d"
+type Seconds int
+
+// TTL bounds, 0 means the zone's default TTL
+const (
+	lowTTL  = 60
+	highTTL = 86400
+	maxTTL  = 1<<31 - 1
+)
+
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		value = string(data)
+	}
+	parsed, err := parseSeconds(value)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+func parseSeconds(value string) (Seconds, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return Seconds(seconds), nil
+	}
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil {
+			return Seconds(n * 86400), nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", value)
+	}
+	if duration%time.Second != 0 {
+		return 0, fmt.Errorf("duration '%s' is not a whole number of seconds", value)
+	}
+	return Seconds(duration / time.Second), nil
+}
+
+// validateTTL rejects values outside the accepted range and warns about
+// values resolvers commonly don't honor
+func validateTTL(ttl Seconds) ([]string, error) {
+	var warnings []string
+	switch {
+	case ttl < 0 || ttl > maxTTL:
+		return nil, fmt.Errorf("ttl %d is out of range (0-%d)", ttl, maxTTL)
+	case ttl == 0:
+		warnings = append(warnings, "ttl is not set, records use the zone's default TTL")
+	case ttl < lowTTL:
+		warnings = append(warnings, fmt.Sprintf("ttl %d is below %d seconds, many resolvers will not honor it", ttl, lowTTL))
+	case ttl > highTTL:
+		warnings = append(warnings, fmt.Sprintf("ttl %d is above one day, IP changes will take long to propagate", ttl))
+	}
+	return warnings, nil
+}
+
+// providerTTLs are the TTLs the providers accept, deSEC raises a TTL to
+// the minimum of the domain and DuckDNS ignores it
+var providerTTLs = map[string][2]Seconds{
+	"hetzner": {60, maxTTL},
+	"rfc2136": {0, maxTTL},
+	"desec":   {0, 86400},
+}
+
+// validateProviderTTL rejects a TTL one of the configured providers
+// doesn't accept, 0 keeps the zone's default TTL
+func validateProviderTTL(ttl Seconds) error {
+	if ttl == 0 {
+		return nil
+	}
+	providers := []string{config.Provider}
+	if config.Provider == "" {
+		providers[0] = "hetzner"
+	}
+	for _, provider := range config.Providers {
+		if !slices.Contains(providers, provider.Type) {
+			providers = append(providers, provider.Type)
+		}
+	}
+	for _, provider := range providers {
+		bounds, ok := providerTTLs[provider]
+		if ok && (ttl < bounds[0] || ttl > bounds[1]) {
+			return fmt.Errorf("ttl %d is not accepted by %s (%d-%d)", ttl, provider, bounds[0], bounds[1])
+		}
+	}
+	return nil
+}
+
+// records stay at the low TTL this long after a change by default
+const defaultRampAfter = 3600
+
+// rampTTL lowers the TTL of a record that is about to change and keeps it
+// low until the address was stable for 'ttl_ramp.after', then the
+// configured TTL is restored; records with the zone's default TTL are
+// not ramped
+func rampTTL(managed ManagedRecord, recType string, record Record, ip string) ManagedRecord {
+	low := config.TTLRamp.Low
+	if low <= 0 {
+		return managed
+	}
+	key := managed.FullDomain + "/" + recType
+	if managed.TTL <= low {
+		live.setRamped(key, time.Time{})
+		return managed
+	}
+	if ip != "" && record.Value != ip {
+		managed.TTL, managed.OverrideTTL = low, true
+		return managed
+	}
+	since, ok := live.rampedSince(key)
+	if !ok {
+		return managed
+	}
+	after := config.TTLRamp.After
+	if after <= 0 {
+		after = defaultRampAfter
+	}
+	if elapsed(since) < time.Duration(after)*time.Second {
+		managed.TTL, managed.OverrideTTL = low, true
+		return managed
+	}
+	if Seconds(record.TTL) == managed.TTL {
+		live.setRamped(key, time.Time{})
+		return managed
+	}
+	managed.OverrideTTL = true
+	return managed
+}
+
+// rampApplied tracks records set to the low TTL of the ramp
+func rampApplied(change Change) {
+	if config.TTLRamp.Low <= 0 {
+		return
+	}
+	key := change.FullDomain + "/" + change.Type
+	if change.Action != "delete" && change.Action != "dedupe" && change.TTL == config.TTLRamp.Low {
+		if change.OldValue != change.NewValue {
+			live.setRamped(key, clock.Now())
+		}
+		return
+	}
+	live.setRamped(key, time.Time{})
+}
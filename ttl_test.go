@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLRamp(t *testing.T) {
+	useConfig(t, Config{TTLRamp: TTLRampConfig{Low: 60, After: 3600}})
+	m := useManualClock(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	managed := ManagedRecord{FullDomain: "home.example.com", Name: "home", Zone: "example.com", TTL: 3600}
+	key := managed.FullDomain + "/A"
+	t.Cleanup(func() { live.setRamped(key, time.Time{}) })
+
+	ramped := rampTTL(managed, "A", Record{Value: "192.0.2.1", TTL: 3600}, "192.0.2.2")
+	if ramped.TTL != 60 {
+		t.Fatalf("changing record gets TTL %d, want the low 60", ramped.TTL)
+	}
+	rampApplied(Change{FullDomain: managed.FullDomain, Type: "A", Action: "update", OldValue: "192.0.2.1", NewValue: "192.0.2.2", TTL: 60})
+
+	current := Record{Value: "192.0.2.2", TTL: 60}
+	m.Advance(59 * time.Minute)
+	if ramped := rampTTL(managed, "A", current, "192.0.2.2"); ramped.TTL != 60 {
+		t.Errorf("TTL %d before 'after' passed, want the low 60", ramped.TTL)
+	}
+	m.Advance(time.Minute)
+	if ramped := rampTTL(managed, "A", current, "192.0.2.2"); ramped.TTL != 3600 || !ramped.OverrideTTL {
+		t.Errorf("TTL %d (override %v) once stable, want 3600 restored", ramped.TTL, ramped.OverrideTTL)
+	}
+
+	rampTTL(managed, "A", Record{Value: "192.0.2.2", TTL: 3600}, "192.0.2.2")
+	if _, ok := live.rampedSince(key); ok {
+		t.Error("ramp is still tracked after the TTL was restored")
+	}
+}
+
+func TestValidateProviderTTL(t *testing.T) {
+	useConfig(t, Config{})
+	if err := validateProviderTTL(30); err == nil {
+		t.Error("ttl 30 accepted for Hetzner")
+	}
+	if err := validateProviderTTL(0); err != nil {
+		t.Errorf("zone default rejected: %v", err)
+	}
+	useConfig(t, Config{Provider: "rfc2136"})
+	if err := validateProviderTTL(30); err != nil {
+		t.Errorf("ttl 30 rejected for rfc2136: %v", err)
+	}
+	useConfig(t, Config{Providers: map[string]ProviderConfig{"example.dedyn.io": {Type: "desec", Token: "t"}}})
+	if err := validateProviderTTL(2 * 86400); err == nil {
+		t.Error("ttl of two days accepted for deSEC")
+	}
+}
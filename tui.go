@@ -0,0 +1,186 @@
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const tuiFreezeDuration = time.Hour
+
+// runTUI runs the reconcile loop in the background and shows the managed
+// records and the log tail until 'q' is pressed
+func runTUI(opts runOptions) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("tui needs a terminal")
+	}
+	old_state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, old_state)
+	defer fmt.Print("\x1b[?25h\x1b[2J\x1b[H")
+
+	opts.verbose = false
+	reconcile := make(chan struct{}, 1)
+	goSafe(func() { reconcileLoop(opts, reconcile) })
+
+	keys := make(chan string)
+	goSafe(func() {
+		buf := make([]byte, 8)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	})
+
+	selected := 0
+	message := ""
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		names := sortedRecordNames()
+		if selected >= len(names) {
+			selected = len(names) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+		drawTUI(names, selected, message)
+
+		select {
+		case <-ticker.C():
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			message = ""
+			switch key {
+			case "q", "\x03":
+				return nil
+			case "\x1b[A", "k":
+				selected--
+			case "\x1b[B", "j":
+				selected++
+			case "r":
+				requestReconcile(reconcile)
+				message = "reconcile requested"
+			case "f", "u":
+				if len(names) == 0 {
+					break
+				}
+				until := time.Time{}
+				if key == "f" {
+					until = clock.Now().Add(tuiFreezeDuration)
+				}
+				live.freeze(names[selected], until)
+				if until.IsZero() {
+					message = "unfroze " + names[selected]
+				} else {
+					message = fmt.Sprintf("froze %s for %s", names[selected], tuiFreezeDuration)
+				}
+			}
+		}
+	}
+}
+
+func sortedRecordNames() []string {
+	var names []string
+	for name := range live.recordList() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func drawTUI(names []string, selected int, message string) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	status := live.status()
+	records := live.recordList()
+
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		text := fmt.Sprintf(format, args...)
+		if len(text) > width {
+			text = text[:width]
+		}
+		b.WriteString(text + "\x1b[K\r\n")
+	}
+
+	b.WriteString("\x1b[?25l\x1b[H")
+	line("hetzner-dns-update  IPv4: %s  IPv6: %s", status.IPv4, status.IPv6)
+	line("last run: %s  errors: %d  changes: %d  next run: %s",
+		tuiTime(status.LastRun), status.Errors, status.Changes, tuiTime(status.NextRun))
+	line("")
+	line("  %-32s %-16s %-40s %-20s %s", "RECORD", "A", "AAAA", "LAST CHANGE", "FROZEN")
+	for i, name := range names {
+		rec := records[name]
+		cursor := " "
+		if i == selected {
+			cursor = ">"
+		}
+		frozen := ""
+		if until, ok := status.FrozenUntil[name]; ok {
+			frozen = "until " + until.Format("15:04")
+		}
+		line("%s %-32s %-16s %-40s %-20s %s", cursor, name, rec.A, rec.AAAA, tuiTime(rec.LastChange), frozen)
+	}
+	line("")
+	line("[r] reconcile  [f] freeze %s  [u] unfreeze  [up/down] select  [q] quit  %s", tuiFreezeDuration, message)
+	line("")
+
+	used := len(names) + 8
+	if height > used {
+		for _, text := range tailLog(height - used) {
+			line("%s", text)
+		}
+	}
+	b.WriteString("\x1b[J")
+	fmt.Print(b.String())
+}
+
+func tuiTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// tailLog returns the last lines of the log file
+func tailLog(lines int) []string {
+	if !logToFile() {
+		return nil
+	}
+	file, err := os.Open(logFileName())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	const tail_size = 16 << 10
+	if info, err := file.Stat(); err == nil && info.Size() > tail_size {
+		file.Seek(-tail_size, io.SeekEnd)
+	}
+	data, _ := io.ReadAll(file)
+	all := strings.Split(string(bytes.TrimRight(data, "\n")), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return all
+}
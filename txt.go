@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+const maxTXTChunk = 255
+
+// encodeTXT quotes a value as one or more character-strings of at most 255 bytes
+func encodeTXT(value string) string {
+	var chunks []string
+	for {
+		chunk := value
+		if len(chunk) > maxTXTChunk {
+			chunk = chunk[:maxTXTChunk]
+		}
+		value = value[len(chunk):]
+		chunk = strings.ReplaceAll(chunk, `\`, `\\`)
+		chunk = strings.ReplaceAll(chunk, `"`, `\"`)
+		chunks = append(chunks, `"`+chunk+`"`)
+		if value == "" {
+			return strings.Join(chunks, " ")
+		}
+	}
+}
+
+// decodeTXT concatenates the character-strings of a TXT value, unquoted
+// values are returned as they are
+func decodeTXT(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, `"`) {
+		return value
+	}
+	var b strings.Builder
+	quoted, escaped := false, false
+	for _, r := range value {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isMailAuth(value string) bool {
+	value = strings.ToLower(value)
+	for _, prefix := range []string{"v=spf1", "v=dkim1", "v=dmarc1", "k=rsa", "k=ed25519"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// planTXT compares the declared TXT values with the zone, the values of the
+// changes are quoted like the records of the zone
+func planTXT(txt TXTRecordConfig, verbose bool) ([]Change, error) {
+	parts := strings.SplitN(txt.Name, ".", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid TXT record name '%s'", txt.Name)
+	}
+	zone, err := findZone(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if reason := zoneProtected(zone); reason != "" {
+		skipProtected(zone.Name, fmt.Sprintf("skipping zone '%s': %s", zone.Name, reason))
+		return nil, nil
+	}
+	zoneID := zone.ID
+
+	var existing []Record
+	err = eachRecord(zoneID, func(rec Record) {
+		if rec.Name == parts[0] && rec.Type == "TXT" {
+			existing = append(existing, rec)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	template := Change{FullDomain: txt.Name, Zone: parts[1], ZoneID: zoneID, Name: parts[0], Type: "TXT", TTL: config.TTL}
+	var changes []Change
+	var present []string
+	for _, rec := range existing {
+		value := decodeTXT(rec.Value)
+		if slices.Contains(txt.Values, value) && !slices.Contains(present, value) {
+			present = append(present, value)
+			continue
+		}
+		if isMailAuth(value) && !txt.Owned {
+			log.Printf("not removing mail authentication TXT value of %s (set \"owned\": true to manage it): %s\n", txt.Name, value)
+			continue
+		}
+		if !allowDelete() {
+			if verbose {
+				fmt.Printf("- TXT value is kept (allow_delete is false) for: %s\n", txt.Name)
+			}
+			continue
+		}
+		change := template
+		change.Action, change.RecordID, change.OldValue, change.OldTTL = "delete", rec.ID, rec.Value, Seconds(rec.TTL)
+		changes = append(changes, change)
+	}
+	for _, value := range txt.Values {
+		if slices.Contains(present, value) {
+			continue
+		}
+		if isMailAuth(value) && !txt.Owned && slices.ContainsFunc(existing, func(rec Record) bool {
+			return isMailAuth(decodeTXT(rec.Value))
+		}) {
+			return nil, fmt.Errorf("refusing to add a second mail authentication value to %s, set \"owned\": true", txt.Name)
+		}
+		change := template
+		change.Action, change.NewValue = "create", encodeTXT(value)
+		changes = append(changes, change)
+	}
+
+	if verbose {
+		if len(changes) == 0 {
+			fmt.Println("- TXT record is current for:", txt.Name)
+		}
+		for _, change := range changes {
+			fmt.Printf("- TXT record needs %s for: %s\n", change.Action, txt.Name)
+		}
+	}
+	return changes, nil
+}
+
+// planTXTRecords plans the changes of the selected TXT records, they are
+// applied together with the address records
+func planTXTRecords(opts runOptions) []Change {
+	var changes []Change
+	for _, txt := range config.TXTRecords {
+		if !opts.selected(txt.Name) {
+			continue
+		}
+		txt_changes, err := planTXT(txt, opts.verbose)
+		if err != nil {
+			logAndMail("error planning TXT record: " + err.Error())
+			continue
+		}
+		changes = append(changes, txt_changes...)
+	}
+	return changes
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// validateConfig checks the loaded config and returns the first error
+func validateConfig() error {
+	_, err := validateTTL(config.TTL)
+	if err == nil {
+		err = validateProviderTTL(config.TTL)
+	}
+	if err == nil {
+		err = validateLogFormat()
+	}
+	if err == nil {
+		err = validateLogTarget()
+	}
+	if err == nil {
+		err = validateProvider()
+	}
+	if err == nil {
+		err = validateProviders()
+	}
+	if err == nil {
+		err = validateTemplates()
+	}
+	if err == nil {
+		err = validateFilters()
+	}
+	if err == nil {
+		err = validateOverrides()
+	}
+	if err == nil {
+		err = validateProxy()
+	}
+	if err == nil {
+		err = validateDoH()
+	}
+	if err == nil {
+		err = validateDetection()
+	}
+	if err == nil {
+		err = validateFallback()
+	}
+	if err == nil {
+		err = validateLabels()
+	}
+	if err == nil {
+		err = validateChangeWindows()
+	}
+	if err == nil {
+		err = validateIPv6Prefix()
+	}
+	if err == nil {
+		err = validateCrossCheck()
+	}
+	if err == nil {
+		err = validateSplitHorizon()
+	}
+	if err == nil {
+		err = validateNotifications()
+	}
+	if err == nil {
+		err = validateSIEM()
+	}
+	if err == nil {
+		err = validateChangeSigning()
+	}
+	return err
+}
+
+// ValidationResult is printed by 'validate' and returned by the control
+// API, a config with warnings is still valid
+type ValidationResult struct {
+	Valid    bool                `json:"valid"`
+	Errors   []string            `json:"errors"`
+	Warnings []ValidationWarning `json:"warnings"`
+}
+
+type ValidationWarning struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// validateCandidate replaces the config with the candidate, so it's run
+// in a process of its own by 'validate'
+func validateCandidate(data []byte) ValidationResult {
+	result := ValidationResult{Errors: []string{}, Warnings: []ValidationWarning{}}
+	config = Config{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+	strict := json.NewDecoder(bytes.NewReader(data))
+	strict.DisallowUnknownFields()
+	if err := strict.Decode(&Config{}); err != nil {
+		result.Warnings = append(result.Warnings, ValidationWarning{"unknown_field", err.Error()})
+	}
+	if err := validateConfig(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	for _, warning := range lintConfig() {
+		result.Warnings = append(result.Warnings, ValidationWarning{warning.key, warning.message})
+	}
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// runValidate checks a config file, or stdin for '-', without loading
+// config.json and prints the result as JSON
+func runValidate(args []string) bool {
+	if len(args) != 1 {
+		fmt.Println("usage: hetzner-dns-update validate <file|->")
+		return false
+	}
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		configFile = args[0]
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		fmt.Println("error reading config:", err)
+		return false
+	}
+	result := validateCandidate(data)
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+	return result.Valid
+}
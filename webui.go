@@ -0,0 +1,161 @@
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+)
+
+var webTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>hetzner-dns-update</title>
+<link rel="alternate" type="application/atom+xml" title="Record changes" href="feed.atom">
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.error { color: #b00; }
+.ok { color: #080; }
+</style>
+</head>
+<body>
+<h1>hetzner-dns-update</h1>
+<table>
+<tr><th>IPv4</th><td>{{.Status.IPv4}}</td></tr>
+<tr><th>IPv6</th><td>{{.Status.IPv6}}</td></tr>
+<tr><th>Last run</th><td>{{.Status.LastRun.Format "2006-01-02 15:04:05"}}</td></tr>
+<tr><th>Next run</th><td>{{.Status.NextRun.Format "2006-01-02 15:04:05"}}</td></tr>
+<tr><th>Result</th><td>{{if .Status.Errors}}<span class="error">{{.Status.Errors}} errors</span>{{else}}<span class="ok">ok</span>{{end}}, {{.Status.Changes}} changes</td></tr>
+</table>
+
+<h2>Records</h2>
+<table>
+<tr><th>Record</th><th>A</th><th>AAAA</th><th>Last change</th></tr>
+{{range .Records}}<tr><td>{{.Name}}</td><td>{{.A}}</td><td>{{.AAAA}}</td><td>{{if not .LastChange.IsZero}}{{.LastChange.Format "2006-01-02 15:04"}}{{end}}</td></tr>
+{{end}}</table>
+
+<h2>IP changes</h2>
+<svg width="100%" height="60" viewBox="0 0 600 60" preserveAspectRatio="none">
+<line x1="10" y1="30" x2="590" y2="30" stroke="#999"/>
+{{range .Chart}}<circle cx="{{.X}}" cy="30" r="5" fill="#36c"><title>{{.Label}}</title></circle>
+{{end}}</svg>
+<table>
+<tr><th>Time</th><th>IPv4</th><th>IPv6</th></tr>
+{{range .History}}<tr><td>{{.Time.Format "2006-01-02 15:04"}}</td><td>{{.IPv4}}</td><td>{{.IPv6}}</td></tr>
+{{end}}</table>
+
+<h2>Last errors</h2>
+<table>
+{{range .Errors}}<tr><td>{{.Time.Format "2006-01-02 15:04"}}</td><td class="error">{{.Message}}</td></tr>
+{{else}}<tr><td>none</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type webRecord struct {
+	Name string
+	RecordStatus
+}
+
+type webChartPoint struct {
+	X     float64
+	Label string
+}
+
+func serveWebUI() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.atom", serveAtomFeed)
+	mux.HandleFunc("GET /approve", serveApproval)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var records []webRecord
+		for name, rec := range live.recordList() {
+			records = append(records, webRecord{name, rec})
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+
+		history := live.ipHistory()
+		var chart []webChartPoint
+		if len(history) > 0 {
+			first := history[0].Time
+			span := elapsed(first).Seconds()
+			for _, change := range history {
+				x := 10.0
+				if span > 0 {
+					x += change.Time.Sub(first).Seconds() / span * 580
+				}
+				label := change.Time.Format("2006-01-02 15:04") + " " + change.IPv4 + " " + change.IPv6
+				chart = append(chart, webChartPoint{x, label})
+			}
+		}
+		// newest first in the table
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := webTemplate.Execute(w, map[string]any{
+			"Status":  live.status(),
+			"Records": records,
+			"Chart":   chart,
+			"History": history,
+			"Errors":  live.lastErrors(),
+		})
+		if err != nil {
+			log.Println("error rendering web UI:", err)
+		}
+	})
+
+	log.Println("web UI listening on", config.WebUI.Listen)
+	err := http.ListenAndServe(config.WebUI.Listen, basicAuth(mux))
+	if err != nil {
+		log.Println("error running web UI:", err)
+	}
+}
+
+// basicAuth protects the page if 'user' and 'password' are set
+func basicAuth(next http.Handler) http.Handler {
+	if config.WebUI.User == "" && config.WebUI.Password == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(config.WebUI.User)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(config.WebUI.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="hetzner-dns-update"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveApproval approves a plan via the signed link of the approval mail
+func serveApproval(w http.ResponseWriter, r *http.Request) {
+	id, sig := r.URL.Query().Get("plan"), r.URL.Query().Get("sig")
+	if config.Approval.Secret == "" || id == "" || !hmac.Equal([]byte(sig), []byte(approvalSignature(id))) {
+		http.Error(w, "invalid approval link", http.StatusForbidden)
+		return
+	}
+	if err := approvePlan(id); err != nil {
+		log.Println("error approving plan:", err)
+		http.Error(w, "error approving plan", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "plan %s approved, it is applied with the next run\n", id)
+}
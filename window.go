@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronExpr is a 'minute hour day-of-month month day-of-week' expression,
+// each field a bit set of the matching values
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+func parseCron(expr string) (cronExpr, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronExpr{}, fmt.Errorf("'%s': expected 5 fields (minute hour day month weekday)", expr)
+	}
+	var c cronExpr
+	var err error
+	for i, field := range []struct {
+		bits     *uint64
+		min, max int
+	}{{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 7}} {
+		*field.bits, err = parseCronField(fields[i], field.min, field.max)
+		if err != nil {
+			return cronExpr{}, fmt.Errorf("'%s': %w", expr, err)
+		}
+	}
+	// 7 is Sunday as well
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny, c.dowAny = fields[2] == "*", fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField supports '*', 'a', 'a-b' and '/step' in comma separated lists
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		spec, step_spec, has_step := strings.Cut(part, "/")
+		step := 1
+		if has_step {
+			var err error
+			step, err = strconv.Atoi(step_spec)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", step_spec)
+			}
+		}
+		low, high := min, max
+		if spec != "*" {
+			from, to, is_range := strings.Cut(spec, "-")
+			var err error
+			low, err = strconv.Atoi(from)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", from)
+			}
+			high = low
+			if is_range {
+				high, err = strconv.Atoi(to)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", to)
+				}
+			} else if has_step {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("'%s' is out of range %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (c cronExpr) matches(t time.Time) bool {
+	if c.minute&(1<<t.Minute()) == 0 || c.hour&(1<<t.Hour()) == 0 || c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom, dow := c.dom&(1<<t.Day()) != 0, c.dow&(1<<int(t.Weekday())) != 0
+	// like cron, a day matches either field if both are restricted
+	switch {
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// inChangeWindow reports whether changes may be applied now, there is no
+// restriction without 'change_windows'
+func inChangeWindow(now time.Time) bool {
+	if len(config.ChangeWindows) == 0 {
+		return true
+	}
+	for _, window := range config.ChangeWindows {
+		// rejected by validateChangeWindows if invalid
+		c, _ := parseCron(window)
+		if c.matches(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateChangeWindows() error {
+	for _, window := range config.ChangeWindows {
+		if _, err := parseCron(window); err != nil {
+			return fmt.Errorf("change_windows: %w", err)
+		}
+	}
+	return nil
+}
+
+// queueChanges keeps the changes planned outside the change windows for
+// the status and notifies once per different set of changes
+func queueChanges(changes []Change) {
+	var diffs []RecordDiff
+	for _, change := range changes {
+		diffs = append(diffs, change.diff())
+	}
+	if !live.setQueued(diffs) || len(diffs) == 0 {
+		return
+	}
+	lines := make([]string, len(diffs))
+	for i, diff := range diffs {
+		lines[i] = "- " + diff.String()
+	}
+	message := fmt.Sprintf("outside the change windows, %d changes are queued until a window opens:\n%s",
+		len(diffs), strings.Join(lines, "\n"))
+	log.Println(message)
+	sendNotification("DNS Update: changes queued", message)
+}
+
+func sameDiffs(a, b []RecordDiff) bool {
+	return slices.EqualFunc(a, b, func(x, y RecordDiff) bool {
+		return x.String() == y.String()
+	})
+}
@@ -0,0 +1,35 @@
+//go:build !minimal
+
+package main
+
+import (
+	"time"
+
+	"github.com/xmppo/go-xmpp"
+)
+
+// sendXMPP delivers a message from the configured account to each JID in
+// 'recipients', the server is looked up via SRV records unless set
+func sendXMPP(subject, body string) error {
+	client, err := xmpp.Options{
+		Host:        config.XMPP.Server,
+		User:        config.XMPP.JID,
+		Password:    config.XMPP.Password,
+		NoTLS:       true,
+		StartTLS:    true,
+		DialTimeout: 10 * time.Second,
+		Resource:    "hetzner-dns-update",
+	}.NewClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, recipient := range config.XMPP.Recipients {
+		_, err = client.Send(xmpp.Chat{Remote: recipient, Type: "chat", Subject: subject, Text: body})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultZoneImportThreshold = 10
+
+func zoneImportThreshold() int {
+	if config.ZoneImport.Threshold > 0 {
+		return config.ZoneImport.Threshold
+	}
+	return defaultZoneImportThreshold
+}
+
+// zoneFileProvider exports and imports whole zone files, like the Hetzner
+// API
+type zoneFileProvider interface {
+	ExportZone(zoneID string) (string, error)
+	ImportZone(zoneID, zoneFile string) error
+	ValidateZoneFile(zoneFile string) error
+}
+
+func canImportZone(zoneID string) bool {
+	_, ok := providerFor(zoneID).(zoneFileProvider)
+	return ok
+}
+
+// importChanges exports the zone, applies the changes to the zone file,
+// validates the result and imports it
+func importChanges(changes []Change) error {
+	if config.ReadOnly {
+		return errReadOnly
+	}
+	zone, zoneID := changes[0].Zone, changes[0].ZoneID
+	provider := providerFor(zoneID).(zoneFileProvider)
+
+	zone_file, err := exportZone(zoneID)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	zone_file = rewriteZoneFile(zone_file, changes)
+
+	err = withRetry("validating zone file of "+zone, func() error {
+		return provider.ValidateZoneFile(zone_file)
+	})
+	if err != nil {
+		return fmt.Errorf("validation: %w", err)
+	}
+	// an import replaces the whole zone, repeating it is harmless
+	return withRetry("importing zone "+zone, func() error {
+		return provider.ImportZone(zoneID, zone_file)
+	})
+}
+
+func exportZone(zoneID string) (zone_file string, err error) {
+	provider, ok := providerFor(zoneID).(zoneFileProvider)
+	if !ok {
+		return "", fmt.Errorf("zone files are not supported by the provider of zone %s", zoneID)
+	}
+	err = withRetry("exporting zone "+zoneID, func() error {
+		zone_file, err = provider.ExportZone(zoneID)
+		return err
+	})
+	return zone_file, err
+}
+
+// rewriteZoneFile replaces, removes or appends the lines of the records
+// affected by the changes, all other lines are kept as they are; a
+// replaced line is written anew, with the TTL of the change
+func rewriteZoneFile(zone_file string, changes []Change) string {
+	lines := strings.Split(strings.TrimRight(zone_file, "\n"), "\n")
+	var out []string
+	for _, line := range lines {
+		change := matchZoneLine(line, changes)
+		switch {
+		case change == nil:
+			out = append(out, line)
+		case change.Action == "update":
+			out = append(out, zoneLine(*change))
+		}
+	}
+	for _, change := range changes {
+		if change.Action == "create" {
+			out = append(out, zoneLine(change))
+		}
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// zoneLine is the zone file line of the record after a change, without a
+// TTL the zone's default applies
+func zoneLine(change Change) string {
+	ttl := ""
+	if change.TTL > 0 {
+		ttl = fmt.Sprintf("%d\t", change.TTL)
+	}
+	return fmt.Sprintf("%s\t%sIN\t%s\t%s", change.Name, ttl, change.Type, change.NewValue)
+}
+
+// matchZoneLine finds the update or delete change for a line like
+// 'name [ttl] [IN] type value'
+func matchZoneLine(line string, changes []Change) *Change {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || strings.HasPrefix(fields[0], ";") || strings.HasPrefix(fields[0], "$") {
+		return nil
+	}
+	for i := range changes {
+		change := &changes[i]
+		if change.Action == "create" || fields[0] != change.Name {
+			continue
+		}
+		// the value of a TXT record may span several fields
+		for j := 1; j < len(fields)-1; j++ {
+			if fields[j] == change.Type && strings.Join(fields[j+1:], " ") == change.OldValue {
+				return change
+			}
+		}
+	}
+	return nil
+}
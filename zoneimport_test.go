@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestRewriteZoneFile(t *testing.T) {
+	zone_file := "$TTL 86400\n" +
+		"@\tIN\tSOA\tns1.example.com. hostmaster.example.com. 1 86400 10800 3600000 3600\n" +
+		"home\t3600\tIN\tA\t192.0.2.1\n" +
+		"home\t3600\tIN\tTXT\t\"old\" \"value\"\n" +
+		"www\t3600\tIN\tA\t192.0.2.1\n"
+	changes := []Change{
+		{Action: "update", Name: "home", Type: "A", OldValue: "192.0.2.1", NewValue: "192.0.2.2", TTL: 60, OldTTL: 3600},
+		{Action: "delete", Name: "home", Type: "TXT", OldValue: "\"old\" \"value\""},
+		{Action: "create", Name: "home", Type: "AAAA", NewValue: "2001:db8::1"},
+	}
+	want := "$TTL 86400\n" +
+		"@\tIN\tSOA\tns1.example.com. hostmaster.example.com. 1 86400 10800 3600000 3600\n" +
+		"home\t60\tIN\tA\t192.0.2.2\n" +
+		"www\t3600\tIN\tA\t192.0.2.1\n" +
+		"home\tIN\tAAAA\t2001:db8::1\n"
+	if got := rewriteZoneFile(zone_file, changes); got != want {
+		t.Errorf("rewritten zone file:\n%s\nwant:\n%s", got, want)
+	}
+}